@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// One arrow in the sequence diagram
+type diagramEntry struct {
+	at      time.Duration
+	from    string
+	to      string
+	packet  Packet
+	dropped bool
+}
+
+// diagram remembers every packet so it can be drawn as a sequence (ladder) diagram
+type diagram struct {
+	entries []diagramEntry
+}
+
+func (d *diagram) record(at time.Duration, from string, to string, p Packet, dropped bool) {
+	d.entries = append(d.entries, diagramEntry{at: at, from: from, to: to, packet: p, dropped: dropped})
+}
+
+// label is the text written on an arrow, e.g. "SYN ACK seq=100 ack=2"
+func (e diagramEntry) label() string {
+	label := fmt.Sprintf("seq=%d ack=%d", e.packet.seq, e.packet.ack)
+	if flags := e.packet.flags(); flags != "" {
+		label = flags + " " + label
+	}
+	if e.packet.message != "" {
+		label += fmt.Sprintf(" '%s'", e.packet.message)
+	}
+	return label + e.packet.options()
+}
+
+// printASCII draws the diagram in the terminal:
+//
+//	       client                              server
+//	 0s      |----- SYN seq=1 ack=0 --------------->|
+//	10ms     |<---- SYN ACK seq=100 ack=2 ----------|
+//	20ms     |----- ACK seq=2 ack=101 -----X        |  (dropped)
+func (d *diagram) printASCII(w io.Writer) {
+	width := 20
+	for _, e := range d.entries {
+		if len(e.label())+12 > width {
+			width = len(e.label()) + 12
+		}
+	}
+
+	fmt.Fprintf(w, "%10s  client%s server\n", "", strings.Repeat(" ", width-6))
+	for _, e := range d.entries {
+		label := " " + e.label() + " "
+		line := "-----" + label + strings.Repeat("-", width-5-len(label))
+
+		if e.from == "client" {
+			if e.dropped {
+				line = line[:len(line)*3/4] + "X" + strings.Repeat(" ", width-len(line)*3/4-1)
+			} else {
+				line = line[:width-1] + ">"
+			}
+		} else {
+			if e.dropped {
+				cut := width - len(line)*3/4
+				line = strings.Repeat(" ", cut-1) + "X" + line[cut:]
+			} else {
+				line = "<" + line[1:]
+			}
+		}
+
+		note := ""
+		if e.dropped {
+			note = "  (dropped)"
+		}
+		fmt.Fprintf(w, "%8v    |%s|%s\n", e.at, line, note)
+	}
+}
+
+// writeMermaid writes the diagram as a Mermaid sequenceDiagram
+func (d *diagram) writeMermaid(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	fmt.Fprintln(file, "sequenceDiagram")
+	fmt.Fprintln(file, "    participant client")
+	fmt.Fprintln(file, "    participant server")
+	for _, e := range d.entries {
+		arrow := "->>"
+		label := e.label()
+		if e.dropped {
+			arrow = "-x"
+			label += " (dropped)"
+		}
+		// ; and # have special meaning in mermaid
+		label = strings.NewReplacer(";", ",", "#", "").Replace(label)
+		fmt.Fprintf(file, "    %s%s%s: %s\n", e.from, arrow, e.to, label)
+	}
+	return nil
+}
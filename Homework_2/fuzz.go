@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"os"
+	"time"
+)
+
+// The fuzz harness runs the client and server over a network with random faults
+// and random data, and checks that TCP still does what it promises:
+//   - the handshake either completes or fails cleanly (nobody is stuck halfway)
+//   - the server gets exactly the bytes the client sent, in order
+//   - no byte is delivered twice
+//   - after both sides closed, both end in CLOSED
+//   - a half-open connection is only left behind by an endpoint without keepalive
+//
+// Everything random comes from the seed, so a failing seed can be replayed with -replay.
+
+// How long (virtual time) a case may run before we call it stuck
+const fuzz_time_limit = 10 * time.Minute
+
+// randomFaults picks how bad the network is for one case
+func randomFaults(rng *rand.Rand) *faults {
+	return &faults{
+		rng:       rng,
+		drop:      rng.Float64() * 0.3,
+		duplicate: rng.Float64() * 0.2,
+		reorder:   rng.Float64() * 0.3,
+		jitter:    time.Duration(rng.Intn(30)) * time.Millisecond,
+	}
+}
+
+// randomPayload makes up to 200 random printable bytes
+func randomPayload(rng *rand.Rand) []byte {
+	payload := make([]byte, rng.Intn(201))
+	for i := range payload {
+		payload[i] = byte('a' + rng.Intn(26))
+	}
+	return payload
+}
+
+// runCase runs one random case and returns what went wrong (nil if nothing)
+func runCase(seed int64, taps ...tap) error {
+	rng := rand.New(rand.NewSource(seed))
+	sim := newSimulator()
+	net := newNetwork(sim)
+	net.faults = randomFaults(rng)
+	for _, t := range taps {
+		net.addTap(t)
+	}
+
+	client := newEndpoint("client", sim, net, 1+rng.Intn(1000))
+	server := newEndpoint("server", sim, net, 1+rng.Intn(1000))
+	for _, e := range []*endpoint{client, server} {
+		e.use_sack = rng.Intn(2) == 0
+		e.use_wscale = rng.Intn(2) == 0
+		e.use_ts = rng.Intn(2) == 0
+		e.mss = 1 + rng.Intn(16)
+		e.rcv_buf = 1 << rng.Intn(21)
+		e.use_keepalive = rng.Intn(2) == 0
+		e.use_nagle = rng.Intn(2) == 0
+		e.use_delack = rng.Intn(2) == 0
+	}
+	payload := randomPayload(rng)
+
+	if verbose {
+		fmt.Printf("seed %d: %v, %d bytes of data\n", seed, net.faults, len(payload))
+		for _, e := range []*endpoint{client, server} {
+			fmt.Printf("  %s: SACK=%v window scaling=%v timestamps=%v keepalive=%v Nagle=%v delayed ACK=%v mss=%d receive buffer=%d\n", e.name, e.use_sack, e.use_wscale, e.use_ts, e.use_keepalive, e.use_nagle, e.use_delack, e.mss, e.rcv_buf)
+		}
+	}
+
+	// check that every byte is only delivered once
+	var problem error
+	seen := make(map[int]bool)
+	server.on_deliver = func(seq int, data []byte) {
+		for i := range data {
+			if seen[seq+i] && problem == nil {
+				problem = fmt.Errorf("byte with seq=%d was delivered twice", seq+i)
+			}
+			seen[seq+i] = true
+		}
+	}
+
+	server.on_close = server.close
+	server.listen()
+	client.connect()
+
+	// the application writes its data in a few pieces at random times
+	rest := payload
+	at := time.Duration(0)
+	for len(rest) > 0 {
+		n := 1 + rng.Intn(len(rest))
+		piece := rest[:n]
+		rest = rest[n:]
+		at += time.Duration(rng.Intn(200)) * time.Millisecond
+		sim.schedule(at, func() {
+			client.write(piece)
+		})
+	}
+	sim.schedule(at, client.close)
+
+	sim.run(fuzz_time_limit)
+
+	if problem != nil {
+		return problem
+	}
+	if sim.next() != nil {
+		return fmt.Errorf("simulation still running after %v", fuzz_time_limit)
+	}
+	for _, e := range []*endpoint{client, server} {
+		if e.state == ESTABLISHED && client.gave_up && !e.use_keepalive {
+			// the client gave up while the server had nothing to send:
+			// without keepalive the server can't know the connection is gone (half-open)
+			continue
+		}
+		if e.state != CLOSED && e.state != LISTEN {
+			return fmt.Errorf("%s is stuck in %s", e.name, e.state)
+		}
+	}
+	if !bytes.HasPrefix(payload, server.delivered) {
+		return fmt.Errorf("server got %q, that is not what the client sent (%q)", server.delivered, payload)
+	}
+	failed := client.gave_up || client.reset || server.gave_up || server.reset
+	if !failed && !client.done() {
+		return fmt.Errorf("client still has %d bytes that were never acknowledged", len(client.send_buf))
+	}
+	if client.done() && !bytes.Equal(server.delivered, payload) {
+		return fmt.Errorf("server got %d bytes but client sent %d", len(server.delivered), len(payload))
+	}
+	return nil
+}
+
+// fuzz runs cases with seeds first_seed, first_seed+1, ... and returns how many failed
+func fuzz(runs int, first_seed int64) int {
+	verbose = false
+	failed := 0
+	for seed := first_seed; seed < first_seed+int64(runs); seed++ {
+		if err := runCase(seed); err != nil {
+			failed++
+			fmt.Printf("FAIL seed %d: %v\n", seed, err)
+			fmt.Printf("  replay with: go run . -replay %d\n", seed)
+		}
+	}
+	fmt.Printf("%d of %d cases failed\n", failed, runs)
+	return failed
+}
+
+// replay runs a single case with all output and a sequence diagram
+func replay(seed int64) {
+	ladder := &diagram{}
+	err := runCase(seed, ladder)
+	fmt.Println("\nSequence diagram:")
+	ladder.printASCII(os.Stdout)
+	if err != nil {
+		fmt.Printf("\nFAIL seed %d: %v\n", seed, err)
+		os.Exit(1)
+	}
+	fmt.Printf("\nseed %d: all checks passed\n", seed)
+}
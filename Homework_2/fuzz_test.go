@@ -0,0 +1,18 @@
+package main
+
+import "testing"
+
+// TestFuzz runs the fault-injection cases of -fuzz with seeds 1..N and checks the invariants.
+// A failing seed can be replayed with: go run . -replay <seed>
+func TestFuzz(t *testing.T) {
+	runs := 500
+	if testing.Short() {
+		runs = 50
+	}
+	verbose = false
+	for seed := int64(1); seed <= int64(runs); seed++ {
+		if err := runCase(seed); err != nil {
+			t.Errorf("seed %d: %v (replay with: go run . -replay %d)", seed, err, seed)
+		}
+	}
+}
@@ -0,0 +1,3 @@
+module Homework_2
+
+go 1.25.0
@@ -0,0 +1,109 @@
+package main
+
+import "time"
+
+// Keepalive: when a connection has been idle for a while the endpoint sends a probe
+// the other side has to answer with an ACK. If the other side crashed without sending
+// a FIN or RST, the connection is half-open: we still think it is ESTABLISHED but
+// nobody is there. A crashed host does not answer at all, and a rebooted host has
+// forgotten the connection and answers with a RST. Either way the probes find out.
+
+// Much shorter than real TCP (2 hours idle, 75s between probes, 9 probes)
+const (
+	keepalive_idle     = 2 * time.Second
+	keepalive_interval = 500 * time.Millisecond
+	keepalive_probes   = 3
+)
+
+// resetKeepalive starts the idle timer again, it is called whenever we hear from the other side
+func (e *endpoint) resetKeepalive() {
+	e.sim.cancel(e.keepalive)
+	e.keepalive = nil
+	e.probes_sent = 0
+	if e.use_keepalive && e.synchronized() && e.state != TIME_WAIT && !e.crashed {
+		e.keepalive = e.sim.schedule(keepalive_idle, e.keepaliveTimeout)
+	}
+}
+
+// keepaliveTimeout sends the next probe, or drops the connection if too many were not answered
+func (e *endpoint) keepaliveTimeout() {
+	e.keepalive = nil
+	if e.probes_sent >= keepalive_probes {
+		e.log("no answer to %d keepalive probes, the other side is gone", e.probes_sent)
+		e.gave_up = true
+		e.stopTimer()
+		e.setState(CLOSED)
+		e.failed()
+		return
+	}
+	if e.retransmit != nil {
+		// we are waiting for an ACK anyway, the retransmissions find out if nobody is there
+		e.keepalive = e.sim.schedule(keepalive_idle, e.keepaliveTimeout)
+		return
+	}
+	e.probes_sent++
+	e.keepalives_sent++
+	e.log("idle, sending keepalive probe %d of %d", e.probes_sent, keepalive_probes)
+	// one garbage byte the receiver already has, so it answers with an ACK
+	e.send(Packet{seq: e.snd_una - 1, ack: e.rcv_nxt, is_ack: true, message: "."})
+	e.keepalive = e.sim.schedule(keepalive_interval, e.keepaliveTimeout)
+}
+
+// crash makes the endpoint disappear without a word, like a machine losing power.
+// It stops answering and all its timers stop.
+func (e *endpoint) crash() {
+	e.log("crashed!")
+	e.crashed = true
+	e.stopTimer()
+	e.sim.cancel(e.time_wait)
+	e.sim.cancel(e.keepalive)
+	e.keepalive = nil
+	e.sim.cancel(e.delack)
+	e.delack = nil
+}
+
+// reboot brings a crashed endpoint back with no memory of its connection
+// and a new initial sequence number
+func (e *endpoint) reboot(my_seq int) {
+	e.log("rebooted")
+	e.crashed = false
+	e.my_seq = my_seq
+	e.setState(CLOSED)
+	e.forget()
+}
+
+// forget throws away the connection, but not what was delivered to the application
+func (e *endpoint) forget() {
+	e.stopTimer()
+	e.sim.cancel(e.time_wait)
+	e.time_wait = nil
+	e.sim.cancel(e.delack)
+	e.delack = nil
+	e.last_ack_sent = 0
+	e.snd_una = e.my_seq + 1
+	e.snd_nxt = e.my_seq + 1
+	e.snd_max = e.my_seq + 1
+	e.send_buf = nil
+	e.dup_acks = 0
+	e.fin_queued = false
+	e.fin_rcvd = false
+	e.sack_ok = false
+	e.sacked = nil
+	e.high_rxt = 0
+	e.wscale_ok = false
+	e.ts_ok = false
+	e.peer_mss = default_mss
+	e.rcv_shift, e.snd_shift = 0, 0
+	e.srtt, e.rttvar, e.rtt_samples = 0, 0, 0
+	e.rcv_nxt = 0
+	e.out_of_order = make(map[int]Packet)
+	e.rto = initial_rto
+	e.retries = 0
+}
+
+// failed tells the application that the connection was reset or timed out
+func (e *endpoint) failed() {
+	if e.on_fail != nil {
+		e.on_fail()
+	}
+}
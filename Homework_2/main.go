@@ -1,7 +1,10 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"os"
+	"strings"
 	"time"
 )
 
@@ -11,138 +14,210 @@ type Packet struct {
 	ack     int
 	is_syn  bool
 	is_ack  bool
+	is_fin  bool
+	is_rst  bool
 	message string
+	window  int // how much more the sender can receive (shifted by the window scale, except on a SYN)
+
+	// TCP options
+	mss            int      // on a SYN: the biggest segment I want to receive (0 = no option)
+	wscale_ok      bool     // on a SYN: I can scale my windows...
+	window_scale   int      // ...by shifting them left this many bits
+	sack_permitted bool     // on a SYN: I can do selective acknowledgements
+	sack_blocks    [][2]int // ranges [start, end) the receiver has beyond ack
+	has_ts         bool     // the packet carries timestamps
+	ts_val         int      // the sender's clock when it sent this
+	ts_ecr         int      // the latest ts_val the sender got from the other side
 }
 
-// function to print packets
-func (p Packet) print() {
-	flags := ""
+// flags of the packet as text, e.g. "SYN ACK"
+func (p Packet) flags() string {
+	flags := []string{}
 	if p.is_syn {
-		flags += "SYN "
+		flags = append(flags, "SYN")
+	}
+	if p.is_fin {
+		flags = append(flags, "FIN")
+	}
+	if p.is_rst {
+		flags = append(flags, "RST")
 	}
 	if p.is_ack {
-		flags += "ACK"
+		flags = append(flags, "ACK")
 	}
-	fmt.Printf("seq=%d ack=%d [%s] '%s'\n", p.seq, p.ack, flags, p.message)
+	return strings.Join(flags, " ")
 }
 
-// Client starts the handshake
-func client(client_to_server chan Packet, server_to_client chan Packet) {
-	fmt.Println("\nCLIENT STARTING")
-
-	// sequence number
-	my_seq := 1
-
-	fmt.Printf("Client starting with seq=%d\n", my_seq)
-
-	// Step 1: Send SYN packet
-	fmt.Println("\nStep 1: Sending SYN...")
-	syn_packet := Packet{
-		seq:     my_seq,
-		ack:     0, // no ack yet
-		is_syn:  true,
-		is_ack:  false,
-		message: "Hello server!",
-	}
-	syn_packet.print()
-	client_to_server <- syn_packet
-
-	// Step 3: Wait for SYN-ACK and send ACK back
-	fmt.Println("\nWaiting for server response...")
-	response := <-server_to_client
-	fmt.Println("Got response:")
-	response.print()
-
-	// Check if it's the right kind of packet
-	if response.is_syn && response.is_ack {
-		fmt.Println("\nStep 3: Server sent SYN-ACK! Sending final ACK...")
-
-		// Final ACK packet
-		ack_packet := Packet{
-			seq:     my_seq + 1,       // my seq + 1
-			ack:     response.seq + 1, // server's seq + 1
-			is_syn:  false,
-			is_ack:  true,
-			message: "Connection OK!",
-		}
-		ack_packet.print()
-		client_to_server <- ack_packet
-
-		fmt.Println("\nHandshake complete!")
+// seqLen is how many sequence numbers the packet uses (SYN and FIN count as one)
+func (p Packet) seqLen() int {
+	n := len(p.message)
+	if p.is_syn {
+		n++
+	}
+	if p.is_fin {
+		n++
 	}
+	return n
 }
 
-// Server - responds to handshake
-func server(client_to_server chan Packet, server_to_client chan Packet) {
-	fmt.Println("\nSERVER STARTING")
-
-	//Sequence number
-	my_seq := 100
-
-	fmt.Printf("Server listening: seq=%d\n", my_seq)
-	fmt.Println("Waiting for client...")
+func (p Packet) String() string {
+	return fmt.Sprintf("seq=%d ack=%d win=%d [%s] '%s'%s", p.seq, p.ack, p.window, p.flags(), p.message, p.options())
+}
 
-	// Step 1: Wait for SYN
-	request := <-client_to_server
-	fmt.Println("\nStep 1: Got packet from client:")
-	request.print()
+// options of the packet as text, e.g. " SACK=10-18,26-34 TS=120/110"
+func (p Packet) options() string {
+	options := ""
+	if p.mss > 0 {
+		options += fmt.Sprintf(" MSS=%d", p.mss)
+	}
+	if p.wscale_ok {
+		options += fmt.Sprintf(" WS=%d", p.window_scale)
+	}
+	if p.sack_permitted {
+		options += " SACK_PERM"
+	}
+	if len(p.sack_blocks) > 0 {
+		blocks := []string{}
+		for _, b := range p.sack_blocks {
+			blocks = append(blocks, fmt.Sprintf("%d-%d", b[0], b[1]))
+		}
+		options += " SACK=" + strings.Join(blocks, ",")
+	}
+	if p.has_ts {
+		options += fmt.Sprintf(" TS=%d/%d", p.ts_val, p.ts_ecr)
+	}
+	return options
+}
 
-	// Check if it's a SYN packet
-	if request.is_syn && !request.is_ack {
-		fmt.Println("\nStep 2: Client sent SYN! Sending SYN-ACK back...")
+// function to print packets
+func (p Packet) print() {
+	fmt.Println(p)
+}
 
-		// Send SYN-ACK response
-		synack_packet := Packet{
-			seq:     my_seq,          // my sequence number
-			ack:     request.seq + 1, // client's seq + 1
-			is_syn:  true,
-			is_ack:  true,
-			message: "Hello client!",
+func main() {
+	pcap_file := flag.String("pcap", "", "write every packet to this pcap file (open it with Wireshark)")
+	show_diagram := flag.Bool("diagram", false, "print a sequence diagram of the packets at the end")
+	mermaid_file := flag.String("mermaid", "", "write a Mermaid sequence diagram of the packets to this file")
+	fuzz_runs := flag.Int("fuzz", 0, "run this many random fault-injection cases and check the invariants")
+	seed := flag.Int64("seed", 1, "first seed for -fuzz")
+	replay_seed := flag.Int64("replay", -1, "replay one fuzz case with this seed and show everything")
+	use_sack := flag.Bool("sack", false, "use selective acknowledgements")
+	use_options := flag.Bool("options", false, "offer window scaling and timestamps in the SYN")
+	use_keepalive := flag.Bool("keepalive", false, "send keepalive probes on idle connections")
+	use_nagle := flag.Bool("nagle", false, "hold back small segments while data is unacknowledged (Nagle's algorithm)")
+	use_delack := flag.Bool("delack", false, "delay ACKs on the receiver")
+	nagle_demo := flag.Bool("nagle-demo", false, "show the latency of a request/response workload with Nagle and delayed ACKs")
+	sack_compare := flag.Bool("sack-compare", false, "compare retransmissions with and without SACK under heavy loss")
+	loss := flag.Float64("loss", 0.2, "packet loss for -sack-compare")
+	step := flag.Bool("step", false, "step through the simulation by hand (interactive)")
+	scenario_name := flag.String("scenario", "", "run a named scenario and check the outcome (\"list\" shows them, \"all\" runs all)")
+	flag.Parse()
+
+	if *fuzz_runs > 0 {
+		if fuzz(*fuzz_runs, *seed) > 0 {
+			os.Exit(1)
 		}
-		synack_packet.print()
-		server_to_client <- synack_packet
-
-		// Step 3: Wait for final ACK
-		fmt.Println("\nWaiting for final ACK...")
-		final_ack := <-client_to_server
-		fmt.Println("Got final packet:")
-		final_ack.print()
-
-		// Check if it's the final ACK
-		if final_ack.is_ack && !final_ack.is_syn {
-			fmt.Println("\nConnection established!")
+		return
+	}
+	if *sack_compare {
+		compareSack(50, *seed, 2000, *loss)
+		return
+	}
+	if *nagle_demo {
+		compareNagle(5)
+		return
+	}
+	if *scenario_name != "" {
+		if !runScenarios(*scenario_name) {
+			os.Exit(1)
 		}
-	} else {
-		fmt.Println("ERROR: Expected SYN packet")
+		return
+	}
+	if *step {
+		repl(os.Stdin, *use_sack)
+		return
+	}
+	if *replay_seed >= 0 {
+		replay(*replay_seed)
+		return
 	}
-}
 
-func main() {
 	fmt.Println("TCP Handshake Simulation")
 	fmt.Println("This shows the 3-way handshake:")
 	fmt.Println("1. Client -> Server: SYN")
 	fmt.Println("2. Server -> Client: SYN-ACK")
 	fmt.Println("3. Client -> Server: ACK")
 
-	// Make channels for communication
-	client_to_server := make(chan Packet)
-	server_to_client := make(chan Packet)
+	// The simulator owns the (virtual) clock, the network delivers packets through it
+	sim := newSimulator()
+	net := newNetwork(sim)
+
+	var pcap *pcapWriter
+	if *pcap_file != "" {
+		var err error
+		if pcap, err = newPcapWriter(*pcap_file); err != nil {
+			fmt.Println("ERROR: could not create pcap file:", err)
+			os.Exit(1)
+		}
+		net.addTap(pcap)
+	}
+
+	ladder := &diagram{}
+	if *show_diagram || *mermaid_file != "" {
+		net.addTap(ladder)
+	}
 
-	// Start server in background (goroutine)
-	go server(client_to_server, server_to_client)
+	client := newEndpoint("client", sim, net, 1)
+	server := newEndpoint("server", sim, net, 100)
+	for _, e := range []*endpoint{client, server} {
+		e.use_sack = *use_sack
+		e.use_wscale = *use_options
+		e.use_ts = *use_options
+		e.use_keepalive = *use_keepalive
+		e.use_nagle = *use_nagle
+		e.use_delack = *use_delack
+	}
 
-	// Wait a bit so server starts first
-	time.Sleep(100 * time.Millisecond)
+	// Server starts listening first, then the client connects
+	fmt.Println()
+	server.listen()
+	client.connect()
+
+	// Once the connection is up the client sends some data and closes,
+	// the server closes too when it sees the client's FIN
+	server.on_close = server.close
+	client.write([]byte("Hello server! Here is some data for you."))
+	client.close()
+
+	// Run until nothing is left to happen (or a minute of virtual time)
+	sim.run(time.Minute)
+
+	fmt.Printf("\nSimulation finished at virtual time %v\n", sim.now)
+	fmt.Printf("client: %s, server: %s\n", client.state, server.state)
+	fmt.Printf("server received: '%s'\n", server.delivered)
+	if client.ts_ok {
+		fmt.Printf("client measured a round trip time of %v (rto %v)\n", client.srtt, client.baseRTO())
+	}
 
-	// Start client in background
-	go client(client_to_server, server_to_client)
+	if pcap != nil {
+		if err := pcap.close(); err != nil {
+			fmt.Println("ERROR: could not write pcap file:", err)
+		}
+	}
 
-	// Wait for everything to finish
-	time.Sleep(2 * time.Second)
+	if *show_diagram {
+		fmt.Println("\nSequence diagram:")
+		ladder.printASCII(os.Stdout)
+	}
+	if *mermaid_file != "" {
+		if err := ladder.writeMermaid(*mermaid_file); err != nil {
+			fmt.Println("ERROR: could not write mermaid file:", err)
+		}
+	}
 
 	fmt.Println("\nDone! The handshake shows:")
 	fmt.Println("- How TCP establishes connections")
 	fmt.Println("- Sequence numbers track packets")
 	fmt.Println("- Both sides confirm the connection")
-	fmt.Println("- Uses goroutines for concurrent processing")
+	fmt.Println("- Uses a discrete-event simulator instead of real sleeps")
 }
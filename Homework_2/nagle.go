@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Two ways to send fewer small packets:
+//   - delayed ACK (receiver): an in-order segment is not acknowledged right away, the ACK waits
+//     for the next segment (every second one is acknowledged), for data going back (piggyback)
+//     or for delayed_ack_timeout
+//   - Nagle's algorithm (sender): a segment smaller than the MSS is only sent when nothing
+//     sent is still unacknowledged, small writes are collected until the ACK comes back
+//
+// Together they can stall: the sender holds back the second part of a request until the first
+// part is acknowledged, and the receiver holds back that ACK because it waits for the rest of
+// the request to answer it. Nothing moves until the delayed ACK timer fires.
+
+// How long a receiver may hold back an ACK (real stacks use 40-500ms).
+// It has to stay well below the RTO, or the sender retransmits while the ACK is being held back.
+const delayed_ack_timeout = 100 * time.Millisecond
+
+// acknowledge sends the ACK for a segment we got, or holds it back if we delay ACKs
+func (e *endpoint) acknowledge(in_order bool) {
+	if in_order && e.last_ack_sent == e.rcv_nxt {
+		// our data already carried the ACK
+		return
+	}
+	if !e.use_delack || !in_order || e.delack != nil || len(e.out_of_order) > 0 || e.fin_rcvd {
+		// duplicates and gaps are acknowledged at once (for fast retransmit), and every second segment too
+		e.send(e.ackPacket())
+		return
+	}
+	e.delack = e.sim.schedule(delayed_ack_timeout, func() {
+		e.delack = nil
+		e.log("delayed ACK timer")
+		e.send(e.ackPacket())
+	})
+}
+
+// nagleHolds is true if Nagle's algorithm keeps this small segment back for now
+func (e *endpoint) nagleHolds(p Packet) bool {
+	return e.use_nagle && !p.is_fin && len(p.message) < e.sendMSS() && e.snd_nxt > e.snd_una
+}
+
+// requestResponse is an application on top of the connection: the client sends a request
+// in two writes (header and body) and waits for the response before sending the next one
+type requestResponse struct {
+	client    *endpoint
+	server    *endpoint
+	requests  int
+	sent_at   time.Duration
+	latencies []time.Duration
+	request   []byte // what the server got of the current request
+	response  []byte // what the client got of the current response
+}
+
+// startRequestResponse sends the first request, the next ones follow the responses
+func startRequestResponse(client, server *endpoint, requests int) *requestResponse {
+	rr := &requestResponse{client: client, server: server, requests: requests}
+	server.on_deliver = func(seq int, data []byte) {
+		rr.request = append(rr.request, data...)
+		for {
+			i := bytes.IndexByte(rr.request, '\n')
+			if i < 0 {
+				break
+			}
+			rr.request = rr.request[i+1:]
+			server.write([]byte("200 OK\n"))
+		}
+	}
+	client.on_deliver = func(seq int, data []byte) {
+		rr.response = append(rr.response, data...)
+		if i := bytes.IndexByte(rr.response, '\n'); i >= 0 {
+			rr.response = rr.response[i+1:]
+			rr.latencies = append(rr.latencies, client.sim.now-rr.sent_at)
+			rr.next()
+		}
+	}
+	rr.next()
+	return rr
+}
+
+func (rr *requestResponse) next() {
+	if len(rr.latencies) == rr.requests {
+		rr.client.close()
+		return
+	}
+	rr.sent_at = rr.client.sim.now
+	rr.client.write([]byte(fmt.Sprintf("GET /%d ", len(rr.latencies))))
+	rr.client.write([]byte("HTTP/1.0\n"))
+}
+
+// average of the latencies
+func (rr *requestResponse) average() time.Duration {
+	if len(rr.latencies) == 0 {
+		return 0
+	}
+	total := time.Duration(0)
+	for _, l := range rr.latencies {
+		total += l
+	}
+	return total / time.Duration(len(rr.latencies))
+}
+
+// runRequestResponse runs the workload on a fresh connection
+func runRequestResponse(use_nagle bool, use_delack bool, requests int) *requestResponse {
+	sim := newSimulator()
+	net := newNetwork(sim)
+	client := newEndpoint("client", sim, net, 1)
+	server := newEndpoint("server", sim, net, 100)
+	for _, e := range []*endpoint{client, server} {
+		e.use_nagle = use_nagle
+		e.use_delack = use_delack
+		// big enough that a whole request fits in one segment
+		e.mss = 64
+	}
+	server.on_close = server.close
+	server.listen()
+	client.connect()
+	// the first request goes out when the handshake is long done
+	var rr *requestResponse
+	sim.schedule(100*time.Millisecond, func() {
+		rr = startRequestResponse(client, server, requests)
+	})
+	sim.run(time.Minute)
+	return rr
+}
+
+// compareNagle runs the request/response workload with every combination and prints the latencies
+func compareNagle(requests int) {
+	verbose = false
+	fmt.Printf("%d requests, each written as a header and a body, the next one is sent after the response\n\n", requests)
+	fmt.Printf("%-7s %-12s %10s %10s  %s\n", "Nagle", "delayed ACK", "average", "max", "latency of every request")
+	for _, use_nagle := range []bool{false, true} {
+		for _, use_delack := range []bool{false, true} {
+			rr := runRequestResponse(use_nagle, use_delack, requests)
+			latencies := []string{}
+			for _, l := range rr.latencies {
+				latencies = append(latencies, l.String())
+			}
+			worst := time.Duration(0)
+			if len(rr.latencies) > 0 {
+				worst = slices.Max(rr.latencies)
+			}
+			fmt.Printf("%-7v %-12v %10v %10v  %s\n", use_nagle, use_delack, rr.average(), worst, strings.Join(latencies, " "))
+		}
+	}
+}
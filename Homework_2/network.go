@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// Something that wants to see every packet crossing the network (pcap file, diagram, ...)
+type tap interface {
+	record(at time.Duration, from string, to string, p Packet, dropped bool)
+}
+
+// faults makes the network unreliable. Every packet rolls the dice with rng,
+// so the same seed always gives the same drops, delays and so on.
+type faults struct {
+	rng       *rand.Rand
+	drop      float64       // chance a packet is lost
+	duplicate float64       // chance a packet arrives twice
+	reorder   float64       // chance a packet is held back so later packets overtake it
+	jitter    time.Duration // every packet gets a random extra delay up to this
+}
+
+func (f *faults) String() string {
+	return fmt.Sprintf("drop=%.2f duplicate=%.2f reorder=%.2f jitter=%v", f.drop, f.duplicate, f.reorder, f.jitter)
+}
+
+// A packet the network holds until someone decides what happens to it (step mode)
+type heldPacket struct {
+	id   int
+	at   time.Duration // when it was sent
+	from string
+	to   string
+	p    Packet
+}
+
+// network carries packets between the client and the server.
+// Sending a packet schedules its delivery on the simulator after the link delay.
+type network struct {
+	sim       *simulator
+	delay     time.Duration
+	endpoints map[string]*endpoint
+	taps      []tap
+	faults    *faults // nil means a perfect network
+	drop_if   func(from string, p Packet) bool
+
+	// in step mode packets wait in held until release or discard is called
+	hold    bool
+	held    []*heldPacket
+	held_id int
+}
+
+func newNetwork(sim *simulator) *network {
+	return &network{
+		sim:       sim,
+		delay:     10 * time.Millisecond,
+		endpoints: make(map[string]*endpoint),
+	}
+}
+
+func (n *network) attach(e *endpoint) {
+	n.endpoints[e.name] = e
+}
+
+func (n *network) addTap(t tap) {
+	n.taps = append(n.taps, t)
+}
+
+// send hands the packet to every tap and then delivers it to the other side
+func (n *network) send(from string, p Packet) {
+	to := "server"
+	if from == "server" {
+		to = "client"
+	}
+
+	if n.hold {
+		n.held_id++
+		n.held = append(n.held, &heldPacket{id: n.held_id, at: n.sim.now, from: from, to: to, p: p})
+		return
+	}
+
+	if n.drop_if != nil && n.drop_if(from, p) {
+		n.record(n.sim.now, from, to, p, true)
+		return
+	}
+
+	copies := 1
+	if f := n.faults; f != nil {
+		if f.rng.Float64() < f.drop {
+			n.record(n.sim.now, from, to, p, true)
+			return
+		}
+		if f.rng.Float64() < f.duplicate {
+			copies = 2
+		}
+	}
+
+	for i := 0; i < copies; i++ {
+		n.record(n.sim.now, from, to, p, false)
+		n.deliver(to, p, n.packetDelay())
+	}
+}
+
+// deliver makes the packet arrive at endpoint to after delay
+func (n *network) deliver(to string, p Packet, delay time.Duration) {
+	n.sim.schedule(delay, func() {
+		if e, ok := n.endpoints[to]; ok {
+			e.receive(p)
+		}
+	})
+}
+
+func (n *network) record(at time.Duration, from string, to string, p Packet, dropped bool) {
+	for _, t := range n.taps {
+		t.record(at, from, to, p, dropped)
+	}
+}
+
+// findHeld returns the held packet with this id
+func (n *network) findHeld(id int) (*heldPacket, bool) {
+	for _, h := range n.held {
+		if h.id == id {
+			return h, true
+		}
+	}
+	return nil, false
+}
+
+func (n *network) removeHeld(h *heldPacket) {
+	for i, other := range n.held {
+		if other == h {
+			n.held = append(n.held[:i], n.held[i+1:]...)
+			return
+		}
+	}
+}
+
+// release lets a held packet go, it arrives after delay
+func (n *network) release(h *heldPacket, delay time.Duration) {
+	n.removeHeld(h)
+	n.record(h.at, h.from, h.to, h.p, false)
+	n.deliver(h.to, h.p, delay)
+}
+
+// discard throws a held packet away
+func (n *network) discard(h *heldPacket) {
+	n.removeHeld(h)
+	n.record(h.at, h.from, h.to, h.p, true)
+}
+
+// packetDelay is the link delay plus whatever the faults add
+func (n *network) packetDelay() time.Duration {
+	delay := n.delay
+	if f := n.faults; f != nil {
+		if f.jitter > 0 {
+			delay += time.Duration(f.rng.Int63n(int64(f.jitter/time.Millisecond)+1)) * time.Millisecond
+		}
+		if f.rng.Float64() < f.reorder {
+			delay += 5 * n.delay
+		}
+	}
+	return delay
+}
@@ -0,0 +1,171 @@
+package main
+
+import "time"
+
+// The options that are agreed on in the SYN and SYN-ACK:
+//   - MSS (RFC 879): the biggest segment each side wants to receive,
+//     the sender cuts its data by the smaller of the two
+//   - window scale (RFC 7323): the window field has only 16 bits, with this option
+//     the windows after the handshake are shifted left by a few bits
+//   - timestamps (RFC 7323): every packet carries the sender's clock and echoes
+//     the other side's clock, which gives the round trip time of every ACK, and
+//     lets the receiver throw away old duplicates even when sequence numbers wrapped (PAWS)
+//
+// Window scaling and timestamps are only used when both sides offer them.
+
+// How many bytes of data an endpoint can keep for the application (its receive window)
+const default_rcv_buf = 64
+
+// Biggest window shift allowed by RFC 7323
+const max_window_shift = 14
+
+// RTO never goes below this, even when the measured round trip time is tiny
+// (it also has to leave room for delayed ACKs)
+const min_rto = 200 * time.Millisecond
+
+// windowShift is the smallest shift that makes buf fit in the 16 bit window field
+func windowShift(buf int) int {
+	shift := 0
+	for buf>>shift > 0xffff && shift < max_window_shift {
+		shift++
+	}
+	return shift
+}
+
+// synOptions adds the options we offer to a SYN, or the ones we agreed on to a SYN-ACK
+func (e *endpoint) synOptions(p Packet) Packet {
+	p.mss = e.mss
+	if p.is_ack {
+		p.wscale_ok = e.wscale_ok
+		p.sack_permitted = e.sack_ok
+	} else {
+		p.wscale_ok = e.use_wscale
+		p.sack_permitted = e.use_sack
+	}
+	if p.wscale_ok {
+		p.window_scale = windowShift(e.rcv_buf)
+	}
+	return p
+}
+
+// negotiate reads the options of the other side's SYN or SYN-ACK
+func (e *endpoint) negotiate(syn Packet) {
+	e.peer_mss = default_mss
+	if syn.mss > 0 {
+		e.peer_mss = syn.mss
+	}
+	e.sack_ok = e.use_sack && syn.sack_permitted
+	e.wscale_ok = e.use_wscale && syn.wscale_ok
+	e.rcv_shift, e.snd_shift = 0, 0
+	if e.wscale_ok {
+		e.rcv_shift = windowShift(e.rcv_buf)
+		e.snd_shift = min(syn.window_scale, max_window_shift)
+	}
+	e.ts_ok = e.use_ts && syn.has_ts
+	if e.ts_ok {
+		e.ts_recent = syn.ts_val
+	}
+	// the window in a SYN is never scaled
+	e.snd_wnd = syn.window
+}
+
+// stamp fills in the window and the timestamps right before a packet is sent
+func (e *endpoint) stamp(p *Packet) {
+	if p.is_rst {
+		return
+	}
+	if p.is_syn {
+		p.window = min(e.receiveWindow(), 0xffff)
+	} else {
+		p.window = min(e.receiveWindow()>>e.rcv_shift, 0xffff)
+	}
+	if e.ts_ok || (p.is_syn && !p.is_ack && e.use_ts) {
+		p.has_ts = true
+		p.ts_val = e.tsClock()
+		p.ts_ecr = e.ts_recent
+	}
+}
+
+// tsClock is our timestamp clock, it ticks every millisecond
+func (e *endpoint) tsClock() int {
+	return int(e.sim.now / time.Millisecond)
+}
+
+// sendMSS is the biggest segment we send
+func (e *endpoint) sendMSS() int {
+	return min(e.mss, e.peer_mss)
+}
+
+// receiveWindow is how many more bytes we can keep: our buffer minus the early segments in it
+func (e *endpoint) receiveWindow() int {
+	kept := 0
+	for _, p := range e.out_of_order {
+		kept += len(p.message)
+	}
+	return max(e.rcv_buf-kept, 0)
+}
+
+// sendWindow is how much may be in flight: our own limit or the receiver's window
+func (e *endpoint) sendWindow() int {
+	return min(e.window, e.snd_wnd)
+}
+
+// updateWindow takes the receiver's window from an ACK
+func (e *endpoint) updateWindow(p Packet) {
+	if p.is_syn || p.ack < e.snd_una {
+		// SYN windows are handled in negotiate, and an old ACK has an old window
+		return
+	}
+	e.snd_wnd = p.window << e.snd_shift
+}
+
+// checkTimestamp is PAWS: a segment with a timestamp older than the latest one
+// is an old duplicate, even if its sequence number looks fine because the numbers wrapped around.
+// It returns false if the segment must be dropped.
+func (e *endpoint) checkTimestamp(p Packet) bool {
+	if !e.ts_ok || !p.has_ts || p.is_syn || !(e.synchronized() || e.state == SYN_RCVD) {
+		return true
+	}
+	if p.ts_val < e.ts_recent {
+		e.log("PAWS: ts_val=%d is older than %d, dropping an old duplicate", p.ts_val, e.ts_recent)
+		e.paws_dropped++
+		if p.seqLen() > 0 {
+			e.send(e.ackPacket())
+		}
+		return false
+	}
+	// only the segment at the left edge of the window may move ts_recent,
+	// so an early segment does not make the ones before it look old
+	if p.seq <= e.rcv_nxt {
+		e.ts_recent = p.ts_val
+	}
+	return true
+}
+
+// measureRTT takes a round trip time sample from the timestamp echoed in an ACK
+// and updates the RTO like RFC 6298
+func (e *endpoint) measureRTT(p Packet) {
+	if !e.ts_ok || !p.has_ts {
+		return
+	}
+	rtt := time.Duration(e.tsClock()-p.ts_ecr) * time.Millisecond
+	if rtt < 0 {
+		return
+	}
+	if e.rtt_samples == 0 {
+		e.srtt = rtt
+		e.rttvar = rtt / 2
+	} else {
+		e.rttvar = (3*e.rttvar + (e.srtt - rtt).Abs()) / 4
+		e.srtt = (7*e.srtt + rtt) / 8
+	}
+	e.rtt_samples++
+}
+
+// baseRTO is the timeout before backing off, from the measured round trip time if we have one
+func (e *endpoint) baseRTO() time.Duration {
+	if e.rtt_samples == 0 {
+		return initial_rto
+	}
+	return min(max(e.srtt+4*e.rttvar, min_rto), max_rto)
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"encoding/binary"
+	"os"
+	"time"
+)
+
+// Fake addresses used in the pcap file so Wireshark sees a normal TCP connection
+var (
+	client_ip   = [4]byte{10, 0, 0, 1}
+	server_ip   = [4]byte{10, 0, 0, 2}
+	client_port = uint16(49152)
+	server_port = uint16(80)
+)
+
+// pcap link type for raw IPv4 packets (no ethernet header)
+const linktype_raw = 101
+
+// pcapWriter writes every packet to a .pcap file with made up IPv4/TCP headers
+type pcapWriter struct {
+	file *os.File
+	err  error // first write that failed, nothing is written after it
+}
+
+func newPcapWriter(path string) (*pcapWriter, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// pcap global header
+	header := make([]byte, 24)
+	binary.LittleEndian.PutUint32(header[0:], 0xa1b2c3d4) // magic number
+	binary.LittleEndian.PutUint16(header[4:], 2)          // version 2.4
+	binary.LittleEndian.PutUint16(header[6:], 4)
+	binary.LittleEndian.PutUint32(header[16:], 65535) // snaplen
+	binary.LittleEndian.PutUint32(header[20:], linktype_raw)
+	if _, err := file.Write(header); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return &pcapWriter{file: file}, nil
+}
+
+// Dropped packets are left out, like a capture taken on the wire after the faults
+func (w *pcapWriter) record(at time.Duration, from string, to string, p Packet, dropped bool) {
+	if dropped || w.err != nil {
+		return
+	}
+	// the virtual clock starts at 0, so the capture starts at 1970-01-01
+	w.err = w.writePacket(time.Unix(0, 0).Add(at), from, p)
+}
+
+func (w *pcapWriter) writePacket(ts time.Time, from string, p Packet) error {
+	data := buildIPv4(from, p)
+
+	// pcap record header
+	header := make([]byte, 16)
+	binary.LittleEndian.PutUint32(header[0:], uint32(ts.Unix()))
+	binary.LittleEndian.PutUint32(header[4:], uint32(ts.Nanosecond()/1000))
+	binary.LittleEndian.PutUint32(header[8:], uint32(len(data)))
+	binary.LittleEndian.PutUint32(header[12:], uint32(len(data)))
+
+	if _, err := w.file.Write(header); err != nil {
+		return err
+	}
+	_, err := w.file.Write(data)
+	return err
+}
+
+// close returns the first write that failed, the file is cut short after it
+func (w *pcapWriter) close() error {
+	if err := w.file.Close(); w.err == nil {
+		w.err = err
+	}
+	return w.err
+}
+
+// buildIPv4 turns a Packet into a real looking IPv4 packet with a TCP header
+func buildIPv4(from string, p Packet) []byte {
+	src_ip, dst_ip := client_ip, server_ip
+	src_port, dst_port := client_port, server_port
+	if from == "server" {
+		src_ip, dst_ip = server_ip, client_ip
+		src_port, dst_port = server_port, client_port
+	}
+
+	tcp := buildTCP(src_ip, dst_ip, src_port, dst_port, p)
+
+	ip := make([]byte, 20)
+	ip[0] = 0x45 // version 4, header length 5*4 bytes
+	binary.BigEndian.PutUint16(ip[2:], uint16(20+len(tcp)))
+	binary.BigEndian.PutUint16(ip[6:], 0x4000) // don't fragment
+	ip[8] = 64                                 // TTL
+	ip[9] = 6                                  // protocol TCP
+	copy(ip[12:16], src_ip[:])
+	copy(ip[16:20], dst_ip[:])
+	binary.BigEndian.PutUint16(ip[10:], checksum(ip))
+
+	return append(ip, tcp...)
+}
+
+func buildTCP(src_ip, dst_ip [4]byte, src_port, dst_port uint16, p Packet) []byte {
+	payload := []byte(p.message)
+	options := buildOptions(p)
+
+	tcp := make([]byte, 20, 20+len(options)+len(payload))
+	binary.BigEndian.PutUint16(tcp[0:], src_port)
+	binary.BigEndian.PutUint16(tcp[2:], dst_port)
+	binary.BigEndian.PutUint32(tcp[4:], uint32(p.seq))
+	binary.BigEndian.PutUint32(tcp[8:], uint32(p.ack))
+	tcp[12] = byte((20+len(options))/4) << 4 // header length in 4 byte words
+
+	flags := byte(0)
+	if p.is_fin {
+		flags |= 0x01
+	}
+	if p.is_rst {
+		flags |= 0x04
+	}
+	if p.is_syn {
+		flags |= 0x02
+	}
+	if p.is_ack {
+		flags |= 0x10
+	}
+	if len(payload) > 0 {
+		flags |= 0x08 // PSH
+	}
+	tcp[13] = flags
+	binary.BigEndian.PutUint16(tcp[14:], uint16(p.window))
+	tcp = append(tcp, options...)
+	tcp = append(tcp, payload...)
+
+	// checksum is calculated over a pseudo header + the tcp segment
+	pseudo := make([]byte, 12, 12+len(tcp))
+	copy(pseudo[0:4], src_ip[:])
+	copy(pseudo[4:8], dst_ip[:])
+	pseudo[9] = 6
+	binary.BigEndian.PutUint16(pseudo[10:], uint16(len(tcp)))
+	pseudo = append(pseudo, tcp...)
+	binary.BigEndian.PutUint16(tcp[16:], checksum(pseudo))
+
+	return tcp
+}
+
+// buildOptions encodes the TCP options, padded with NOPs to a multiple of 4 bytes
+func buildOptions(p Packet) []byte {
+	options := []byte{}
+	if p.mss > 0 {
+		options = binary.BigEndian.AppendUint16(append(options, 2, 4), uint16(p.mss))
+	}
+	if p.wscale_ok {
+		options = append(options, 1, 3, 3, byte(p.window_scale)) // NOP window scale
+	}
+	if p.sack_permitted {
+		options = append(options, 1, 1, 4, 2) // NOP NOP SACK permitted
+	}
+	if len(p.sack_blocks) > 0 {
+		options = append(options, 1, 1, 5, byte(2+8*len(p.sack_blocks)))
+		for _, b := range p.sack_blocks {
+			options = binary.BigEndian.AppendUint32(options, uint32(b[0]))
+			options = binary.BigEndian.AppendUint32(options, uint32(b[1]))
+		}
+	}
+	if p.has_ts {
+		options = append(options, 1, 1, 8, 10) // NOP NOP timestamps
+		options = binary.BigEndian.AppendUint32(options, uint32(p.ts_val))
+		options = binary.BigEndian.AppendUint32(options, uint32(p.ts_ecr))
+	}
+	for len(options)%4 != 0 {
+		options = append(options, 1)
+	}
+	return options
+}
+
+// Internet checksum (RFC 1071)
+func checksum(data []byte) uint16 {
+	sum := uint32(0)
+	for i := 0; i+1 < len(data); i += 2 {
+		sum += uint32(data[i])<<8 | uint32(data[i+1])
+	}
+	if len(data)%2 == 1 {
+		sum += uint32(data[len(data)-1]) << 8
+	}
+	for sum > 0xffff {
+		sum = (sum >> 16) + (sum & 0xffff)
+	}
+	return ^uint16(sum)
+}
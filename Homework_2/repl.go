@@ -0,0 +1,245 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Step mode: the network holds every packet and you decide what happens to it.
+// This makes it easy to build edge cases by hand, e.g. lose only the final ACK
+// of the handshake and watch the server retransmit its SYN-ACK.
+
+const repl_help = `Commands:
+  start                       server listens and client connects
+  listen <client|server>      start listening
+  connect <client|server>     send a SYN
+  write <client|server> text  send data
+  close <client|server>       close the write half (send a FIN)
+  abort <client|server>       throw the connection away (send a RST)
+  keepalive <client|server>   send keepalive probes when the connection is idle
+  crash <client|server>       the endpoint disappears without a word
+  reboot <client|server>      a crashed endpoint comes back without its connection
+  list                        show the packets held in the network
+  deliver [id|all]            deliver a packet now (default: the oldest)
+  drop [id]                   lose a packet
+  dup [id]                    deliver a packet twice
+  delay [id] <duration>       deliver a packet later, e.g. "delay 3 50ms"
+  next                        run the next timer
+  wait <duration>             let virtual time pass, e.g. "wait 1s"
+  state                       show both endpoints and their timers
+  diagram                     show the sequence diagram so far
+  help                        show this help
+  quit                        stop`
+
+// repl runs the step mode, reading commands from in
+func repl(in io.Reader, use_sack bool) {
+	sim := newSimulator()
+	net := newNetwork(sim)
+	net.hold = true
+	ladder := &diagram{}
+	net.addTap(ladder)
+
+	client := newEndpoint("client", sim, net, 1)
+	server := newEndpoint("server", sim, net, 100)
+	client.use_sack = use_sack
+	server.use_sack = use_sack
+	endpoints := map[string]*endpoint{"client": client, "server": server}
+
+	fmt.Println("TCP step-through debugger (type 'help' for commands, 'start' to begin the handshake)")
+	fmt.Print("> ")
+
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		words := strings.Fields(scanner.Text())
+		if len(words) == 0 {
+			fmt.Print("> ")
+			continue
+		}
+		cmd, args := words[0], words[1:]
+		if cmd == "quit" || cmd == "exit" {
+			return
+		}
+		if err := replCommand(sim, net, ladder, endpoints, cmd, args); err != nil {
+			fmt.Println("ERROR:", err)
+		}
+
+		// everything that is due now happens before the next prompt
+		sim.run(sim.now)
+		printHeld(net)
+		fmt.Print("> ")
+	}
+}
+
+func replCommand(sim *simulator, net *network, ladder *diagram, endpoints map[string]*endpoint, cmd string, args []string) error {
+	switch cmd {
+	case "help":
+		fmt.Println(repl_help)
+
+	case "start":
+		endpoints["server"].listen()
+		endpoints["client"].connect()
+
+	case "listen", "connect", "write", "close", "abort", "keepalive", "crash", "reboot":
+		if len(args) == 0 {
+			return fmt.Errorf("usage: %s <client|server>", cmd)
+		}
+		e, ok := endpoints[args[0]]
+		if !ok {
+			return fmt.Errorf("no endpoint called %q", args[0])
+		}
+		switch cmd {
+		case "listen":
+			e.listen()
+		case "connect":
+			e.connect()
+		case "write":
+			e.write([]byte(strings.Join(args[1:], " ")))
+		case "close":
+			e.close()
+		case "abort":
+			e.abort()
+		case "keepalive":
+			e.use_keepalive = true
+			e.resetKeepalive()
+		case "crash":
+			e.crash()
+		case "reboot":
+			e.reboot(e.my_seq + 1000)
+		}
+
+	case "list":
+		if len(net.held) == 0 {
+			fmt.Println("no packets in the network")
+		}
+
+	case "deliver":
+		if len(args) > 0 && args[0] == "all" {
+			for len(net.held) > 0 {
+				net.release(net.held[0], 0)
+			}
+			return nil
+		}
+		h, err := pickHeld(net, args)
+		if err != nil {
+			return err
+		}
+		net.release(h, 0)
+
+	case "drop":
+		h, err := pickHeld(net, args)
+		if err != nil {
+			return err
+		}
+		net.discard(h)
+
+	case "dup":
+		h, err := pickHeld(net, args)
+		if err != nil {
+			return err
+		}
+		net.release(h, 0)
+		net.record(h.at, h.from, h.to, h.p, false)
+		net.deliver(h.to, h.p, 0)
+
+	case "delay":
+		if len(args) == 0 {
+			return fmt.Errorf("usage: delay [id] <duration>")
+		}
+		d, err := time.ParseDuration(args[len(args)-1])
+		if err != nil {
+			return err
+		}
+		h, err := pickHeld(net, args[:len(args)-1])
+		if err != nil {
+			return err
+		}
+		net.release(h, d)
+
+	case "next":
+		if !sim.step() {
+			fmt.Println("nothing is scheduled")
+		}
+
+	case "wait":
+		if len(args) == 0 {
+			return fmt.Errorf("usage: wait <duration>")
+		}
+		d, err := time.ParseDuration(args[0])
+		if err != nil {
+			return err
+		}
+		sim.advance(d)
+
+	case "state":
+		fmt.Printf("virtual time %v\n", sim.now)
+		printEndpoint(endpoints["client"])
+		printEndpoint(endpoints["server"])
+
+	case "diagram":
+		ladder.printASCII(os.Stdout)
+
+	default:
+		return fmt.Errorf("unknown command %q (type 'help')", cmd)
+	}
+	return nil
+}
+
+// pickHeld finds the packet named by args[0], or the oldest one
+func pickHeld(net *network, args []string) (*heldPacket, error) {
+	if len(net.held) == 0 {
+		return nil, fmt.Errorf("no packets in the network")
+	}
+	if len(args) == 0 {
+		return net.held[0], nil
+	}
+	id, err := strconv.Atoi(args[0])
+	if err != nil {
+		return nil, fmt.Errorf("%q is not a packet id", args[0])
+	}
+	h, ok := net.findHeld(id)
+	if !ok {
+		return nil, fmt.Errorf("no packet with id %d", id)
+	}
+	return h, nil
+}
+
+func printHeld(net *network) {
+	for _, h := range net.held {
+		fmt.Printf("  #%d %s -> %s (sent at %v): %v\n", h.id, h.from, h.to, h.at, h.p)
+	}
+}
+
+// printEndpoint shows everything interesting about one side of the connection
+func printEndpoint(e *endpoint) {
+	fmt.Printf("%s: %s\n", e.name, e.state)
+	fmt.Printf("  my_seq=%d peer_seq=%d sack=%v\n", e.my_seq, e.peer_seq, e.sack_ok)
+	fmt.Printf("  send: snd_una=%d snd_nxt=%d snd_max=%d unacked/unsent bytes=%d\n", e.snd_una, e.snd_nxt, e.snd_max, len(e.send_buf))
+	fmt.Printf("  receive: rcv_nxt=%d kept early=%d delivered='%s'\n", e.rcv_nxt, len(e.out_of_order), e.delivered)
+	fmt.Printf("  options: mss=%d (theirs %d) their window=%d shift=%d/%d timestamps=%v srtt=%v\n", e.mss, e.peer_mss, e.snd_wnd, e.rcv_shift, e.snd_shift, e.ts_ok, e.srtt)
+	if e.fin_queued || e.fin_rcvd {
+		fmt.Printf("  closing: our FIN queued=%v (seq=%d), their FIN received=%v\n", e.fin_queued, e.fin_seq, e.fin_rcvd)
+	}
+	if e.crashed {
+		fmt.Println("  crashed")
+	}
+	if e.retransmit == nil && e.time_wait == nil && e.keepalive == nil && e.delack == nil {
+		fmt.Println("  no timers running")
+	}
+	if e.retransmit != nil {
+		fmt.Printf("  retransmit timer fires at %v (rto=%v, retries=%d)\n", e.retransmit.at, e.rto, e.retries)
+	}
+	if e.time_wait != nil {
+		fmt.Printf("  TIME_WAIT timer fires at %v\n", e.time_wait.at)
+	}
+	if e.delack != nil {
+		fmt.Printf("  delayed ACK goes out at %v\n", e.delack.at)
+	}
+	if e.keepalive != nil {
+		fmt.Printf("  keepalive timer fires at %v (%d probes unanswered)\n", e.keepalive.at, e.probes_sent)
+	}
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"time"
+)
+
+// Selective acknowledgements (SACK, RFC 2018).
+// With only cumulative ACKs the sender knows the receiver got everything before ack,
+// but not what it has after that, so after a timeout it has to send everything again.
+// With SACK the receiver also lists the ranges it keeps in out_of_order,
+// and the sender only retransmits the holes between them.
+
+// At most 4 blocks fit in the 40 bytes of TCP options, only 3 next to the timestamps
+const max_sack_blocks = 4
+
+// mergeRanges sorts ranges and joins the ones that overlap or touch
+func mergeRanges(ranges [][2]int) [][2]int {
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i][0] < ranges[j][0] })
+	merged := [][2]int{}
+	for _, r := range ranges {
+		if n := len(merged); n > 0 && r[0] <= merged[n-1][1] {
+			merged[n-1][1] = max(merged[n-1][1], r[1])
+		} else {
+			merged = append(merged, r)
+		}
+	}
+	return merged
+}
+
+// sackBlocks lists the data we keep after rcv_nxt (receiver side)
+func (e *endpoint) sackBlocks() [][2]int {
+	ranges := [][2]int{}
+	for seq, p := range e.out_of_order {
+		ranges = append(ranges, [2]int{seq, seq + p.seqLen()})
+	}
+	blocks := mergeRanges(ranges)
+	limit := max_sack_blocks
+	if e.ts_ok {
+		limit--
+	}
+	if len(blocks) > limit {
+		blocks = blocks[:limit]
+	}
+	return blocks
+}
+
+// addSacked remembers the blocks the receiver reported (sender side).
+// It returns true if the blocks told us something new.
+func (e *endpoint) addSacked(blocks [][2]int) bool {
+	if len(blocks) == 0 {
+		return false
+	}
+	before := sackedBytes(e.sacked)
+	e.sacked = mergeRanges(append(e.sacked, blocks...))
+	e.dropSacked()
+	return sackedBytes(e.sacked) > before
+}
+
+func sackedBytes(ranges [][2]int) int {
+	total := 0
+	for _, r := range ranges {
+		total += r[1] - r[0]
+	}
+	return total
+}
+
+// dropSacked forgets blocks that are now covered by the cumulative ACK
+func (e *endpoint) dropSacked() {
+	kept := [][2]int{}
+	for _, b := range e.sacked {
+		if b[1] > e.snd_una {
+			kept = append(kept, [2]int{max(b[0], e.snd_una), b[1]})
+		}
+	}
+	e.sacked = kept
+}
+
+func (e *endpoint) highestSacked() int {
+	if len(e.sacked) == 0 {
+		return e.snd_una
+	}
+	return e.sacked[len(e.sacked)-1][1]
+}
+
+// holes are the ranges between snd_una and limit the receiver does not have
+func (e *endpoint) holes(limit int) [][2]int {
+	holes := [][2]int{}
+	start := e.snd_una
+	for _, b := range e.sacked {
+		if b[0] >= limit {
+			break
+		}
+		if b[0] > start {
+			holes = append(holes, [2]int{start, b[0]})
+		}
+		start = max(start, b[1])
+	}
+	if start < limit {
+		holes = append(holes, [2]int{start, limit})
+	}
+	return holes
+}
+
+// retransmitHoles sends again only the missing ranges up to limit.
+// Bytes below high_rxt were already retransmitted and are not sent again
+// until the next timeout.
+func (e *endpoint) retransmitHoles(limit int) {
+	for _, hole := range e.holes(limit) {
+		hole[0] = max(hole[0], e.high_rxt)
+		if hole[0] >= hole[1] {
+			continue
+		}
+		e.high_rxt = hole[1]
+		e.log("retransmitting missing range %d-%d", hole[0], hole[1])
+		for seq := hole[0]; seq < hole[1]; {
+			p := e.segment(seq, hole[1])
+			if p.seqLen() == 0 {
+				break
+			}
+			e.sendSegment(p)
+			seq += p.seqLen()
+		}
+	}
+}
+
+// What one transfer in the comparison cost
+type transferResult struct {
+	retransmitted_bytes    int
+	retransmitted_segments int
+	took                   time.Duration
+	completed              bool
+}
+
+// transfer sends size bytes from client to server over a network that loses packets
+func transfer(use_sack bool, seed int64, size int, loss float64) transferResult {
+	sim := newSimulator()
+	net := newNetwork(sim)
+	net.faults = &faults{rng: rand.New(rand.NewSource(seed)), drop: loss}
+
+	client := newEndpoint("client", sim, net, 1)
+	server := newEndpoint("server", sim, net, 100)
+	client.use_sack = use_sack
+	server.use_sack = use_sack
+	client.window = 8 * client.mss
+	server.rcv_buf = client.window
+
+	payload := make([]byte, size)
+	for i := range payload {
+		payload[i] = byte('a' + i%26)
+	}
+
+	server.listen()
+	client.connect()
+	client.write(payload)
+	sim.run(fuzz_time_limit)
+
+	return transferResult{
+		retransmitted_bytes:    client.retransmitted_bytes,
+		retransmitted_segments: client.retransmitted_segments,
+		took:                   client.finished_at,
+		completed:              client.done() && len(server.delivered) == size,
+	}
+}
+
+// compareSack runs the same lossy transfers with and without SACK and prints the averages
+func compareSack(runs int, first_seed int64, size int, loss float64) {
+	verbose = false
+	fmt.Printf("Sending %d bytes %d times with %.0f%% packet loss\n\n", size, runs, loss*100)
+	fmt.Printf("%-18s %12s %12s %14s %10s\n", "mode", "retx bytes", "retx segs", "avg time", "completed")
+
+	for _, use_sack := range []bool{false, true} {
+		mode := "cumulative ACK"
+		if use_sack {
+			mode = "SACK"
+		}
+
+		retx_bytes, segments, completed := 0, 0, 0
+		took := time.Duration(0)
+		for seed := first_seed; seed < first_seed+int64(runs); seed++ {
+			r := transfer(use_sack, seed, size, loss)
+			retx_bytes += r.retransmitted_bytes
+			segments += r.retransmitted_segments
+			if r.completed {
+				completed++
+				took += r.took
+			}
+		}
+
+		avg_time := time.Duration(0)
+		if completed > 0 {
+			avg_time = took / time.Duration(completed)
+		}
+		fmt.Printf("%-18s %12d %12d %14v %7d/%d\n", mode, retx_bytes/runs, segments/runs, avg_time, completed, runs)
+	}
+}
@@ -0,0 +1,436 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Named scenarios for the special cases of TCP. Every scenario sets up the
+// endpoints, runs the simulator and then checks that the expected thing happened.
+
+// Everything a scenario needs
+type scenarioRun struct {
+	sim     *simulator
+	net     *network
+	client  *endpoint
+	server  *endpoint
+	packets *packetCounter
+	rr      *requestResponse // for the scenarios with a request/response workload
+}
+
+type scenario struct {
+	name        string
+	description string
+	setup       func(r *scenarioRun)
+	check       func(r *scenarioRun) error
+}
+
+// packetCounter counts packets by sender and flags, e.g. "server SYN ACK",
+// and remembers the biggest segment each side sent
+type packetCounter struct {
+	counts  map[string]int
+	biggest map[string]int
+}
+
+func (c *packetCounter) record(at time.Duration, from string, to string, p Packet, dropped bool) {
+	c.counts[from+" "+p.flags()]++
+	c.biggest[from] = max(c.biggest[from], len(p.message))
+}
+
+var scenarios = []scenario{
+	{
+		name:        "normal",
+		description: "handshake, the client sends a request and closes, the server closes when it sees the FIN",
+		setup: func(r *scenarioRun) {
+			r.server.on_close = r.server.close
+			r.server.listen()
+			r.client.connect()
+			r.client.write([]byte("GET /index.html"))
+			r.client.close()
+		},
+		check: func(r *scenarioRun) error {
+			if err := expectData(r.server, "GET /index.html"); err != nil {
+				return err
+			}
+			if err := expectHistory(r.client, SYN_SENT, ESTABLISHED, FIN_WAIT_1, FIN_WAIT_2, TIME_WAIT, CLOSED); err != nil {
+				return err
+			}
+			return expectHistory(r.server, LISTEN, SYN_RCVD, ESTABLISHED, CLOSE_WAIT, LAST_ACK, CLOSED)
+		},
+	},
+	{
+		name:        "simultaneous-open",
+		description: "both sides send a SYN at the same time, nobody listens",
+		setup: func(r *scenarioRun) {
+			r.client.connect()
+			r.server.connect()
+			r.client.write([]byte("ping"))
+			r.server.write([]byte("pong"))
+		},
+		check: func(r *scenarioRun) error {
+			for _, e := range []*endpoint{r.client, r.server} {
+				if err := expectHistory(e, SYN_SENT, SYN_RCVD, ESTABLISHED); err != nil {
+					return err
+				}
+				if e.state != ESTABLISHED {
+					return fmt.Errorf("%s ended in %s, expected ESTABLISHED", e.name, e.state)
+				}
+			}
+			if err := expectData(r.server, "ping"); err != nil {
+				return err
+			}
+			return expectData(r.client, "pong")
+		},
+	},
+	{
+		name:        "half-close",
+		description: "the client closes its write half right after the request and still reads the whole response",
+		setup: func(r *scenarioRun) {
+			r.server.on_close = func() {
+				r.server.write([]byte("a long response that arrives after the client closed"))
+				r.server.close()
+			}
+			r.server.listen()
+			r.client.connect()
+			r.client.write([]byte("request"))
+			r.client.close()
+		},
+		check: func(r *scenarioRun) error {
+			if err := expectData(r.server, "request"); err != nil {
+				return err
+			}
+			if err := expectData(r.client, "a long response that arrives after the client closed"); err != nil {
+				return err
+			}
+			if err := expectHistory(r.client, FIN_WAIT_1, FIN_WAIT_2, TIME_WAIT, CLOSED); err != nil {
+				return err
+			}
+			return expectHistory(r.server, CLOSE_WAIT, LAST_ACK, CLOSED)
+		},
+	},
+	{
+		name:        "simultaneous-close",
+		description: "both sides close at the same time and go through CLOSING",
+		setup: func(r *scenarioRun) {
+			r.server.listen()
+			r.client.connect()
+			r.sim.schedule(100*time.Millisecond, func() {
+				r.client.close()
+				r.server.close()
+			})
+		},
+		check: func(r *scenarioRun) error {
+			for _, e := range []*endpoint{r.client, r.server} {
+				if err := expectHistory(e, ESTABLISHED, FIN_WAIT_1, CLOSING, TIME_WAIT, CLOSED); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		name:        "refused",
+		description: "the client connects to a port where nobody listens and gets a RST",
+		setup: func(r *scenarioRun) {
+			r.client.connect()
+		},
+		check: func(r *scenarioRun) error {
+			if !r.client.reset || r.client.state != CLOSED {
+				return fmt.Errorf("client should be CLOSED by a RST, it is %s (reset=%v)", r.client.state, r.client.reset)
+			}
+			if r.packets.counts["client SYN"] != 1 {
+				return fmt.Errorf("client sent %d SYNs, a RST should stop it after 1", r.packets.counts["client SYN"])
+			}
+			if r.packets.counts["server RST ACK"] != 1 {
+				return fmt.Errorf("server sent %d RSTs, expected 1", r.packets.counts["server RST ACK"])
+			}
+			return nil
+		},
+	},
+	{
+		name:        "abort",
+		description: "the client aborts an established connection with a RST",
+		setup: func(r *scenarioRun) {
+			r.server.listen()
+			r.client.connect()
+			r.client.write([]byte("some data"))
+			r.sim.schedule(100*time.Millisecond, r.client.abort)
+		},
+		check: func(r *scenarioRun) error {
+			if err := expectData(r.server, "some data"); err != nil {
+				return err
+			}
+			if r.client.state != CLOSED {
+				return fmt.Errorf("client ended in %s, expected CLOSED", r.client.state)
+			}
+			if !r.server.reset || r.server.state != CLOSED {
+				return fmt.Errorf("server should be CLOSED by a RST, it is %s (reset=%v)", r.server.state, r.server.reset)
+			}
+			return nil
+		},
+	},
+	{
+		name:        "lost-final-ack",
+		description: "the last ACK of the handshake is lost, the server sends its SYN-ACK again",
+		setup: func(r *scenarioRun) {
+			dropped := false
+			r.net.drop_if = func(from string, p Packet) bool {
+				if from == "client" && p.flags() == "ACK" && !dropped {
+					dropped = true
+					return true
+				}
+				return false
+			}
+			r.server.listen()
+			r.client.connect()
+		},
+		check: func(r *scenarioRun) error {
+			if r.packets.counts["server SYN ACK"] != 2 {
+				return fmt.Errorf("server sent %d SYN-ACKs, expected 2", r.packets.counts["server SYN ACK"])
+			}
+			if r.client.state != ESTABLISHED || r.server.state != ESTABLISHED {
+				return fmt.Errorf("client is %s and server is %s, expected both ESTABLISHED", r.client.state, r.server.state)
+			}
+			return nil
+		},
+	},
+	{
+		name:        "options",
+		description: "the server wants 4 byte segments and has a 1MB buffer, so the client scales its windows and measures the RTT",
+		setup: func(r *scenarioRun) {
+			for _, e := range []*endpoint{r.client, r.server} {
+				e.use_wscale = true
+				e.use_ts = true
+			}
+			r.server.mss = 4
+			r.server.rcv_buf = 1 << 20
+			r.server.listen()
+			r.client.connect()
+			r.client.write([]byte("some data in small pieces"))
+		},
+		check: func(r *scenarioRun) error {
+			if err := expectData(r.server, "some data in small pieces"); err != nil {
+				return err
+			}
+			if r.packets.biggest["client"] > 4 {
+				return fmt.Errorf("client sent a %d byte segment, the server's MSS is 4", r.packets.biggest["client"])
+			}
+			if r.client.snd_shift != 5 || r.client.snd_wnd != 1<<20 {
+				return fmt.Errorf("client sees a window of %d (shift %d), expected %d (shift 5)", r.client.snd_wnd, r.client.snd_shift, 1<<20)
+			}
+			if r.client.rtt_samples == 0 || r.client.srtt != 2*r.net.delay {
+				return fmt.Errorf("client measured srtt=%v from %d samples, expected %v", r.client.srtt, r.client.rtt_samples, 2*r.net.delay)
+			}
+			return nil
+		},
+	},
+	{
+		name:        "paws",
+		description: "an old duplicate from before the sequence numbers wrapped arrives with a valid seq, PAWS drops it by its timestamp",
+		setup: func(r *scenarioRun) {
+			r.client.use_ts = true
+			r.server.use_ts = true
+			r.server.listen()
+			r.client.connect()
+			r.client.write([]byte("hello"))
+			r.sim.schedule(100*time.Millisecond, func() {
+				// we can't wait for 4GB to wrap the numbers around, so we make up
+				// a segment that was sent long ago with the seq the server expects now
+				old := Packet{seq: r.server.rcv_nxt, ack: r.server.snd_nxt, is_ack: true, message: "OLD DATA", has_ts: true, ts_val: 1}
+				r.net.record(r.sim.now, "client", "server", old, false)
+				r.net.deliver("server", old, 0)
+			})
+			r.sim.schedule(200*time.Millisecond, func() {
+				r.client.write([]byte(" world"))
+			})
+		},
+		check: func(r *scenarioRun) error {
+			if r.server.paws_dropped != 1 {
+				return fmt.Errorf("server dropped %d segments with PAWS, expected 1", r.server.paws_dropped)
+			}
+			return expectData(r.server, "hello world")
+		},
+	},
+	{
+		name:        "keepalive",
+		description: "the connection is idle for 5 seconds, the client's keepalive probes are answered and it stays up",
+		setup: func(r *scenarioRun) {
+			r.client.use_keepalive = true
+			r.server.on_close = r.server.close
+			r.server.listen()
+			r.client.connect()
+			r.client.write([]byte("hello"))
+			r.sim.schedule(5*time.Second, r.client.close)
+		},
+		check: func(r *scenarioRun) error {
+			if r.client.keepalives_sent < 2 || r.client.gave_up {
+				return fmt.Errorf("client sent %d probes (gave up=%v), expected at least 2 answered ones", r.client.keepalives_sent, r.client.gave_up)
+			}
+			return expectHistory(r.client, ESTABLISHED, FIN_WAIT_1, FIN_WAIT_2, TIME_WAIT, CLOSED)
+		},
+	},
+	{
+		name:        "half-open-crash",
+		description: "the client crashes silently, the server finds out with keepalive probes and listens again",
+		setup: func(r *scenarioRun) {
+			r.server.use_keepalive = true
+			r.server.on_fail = r.server.listen
+			r.server.listen()
+			r.client.connect()
+			r.client.write([]byte("hello"))
+			r.sim.schedule(100*time.Millisecond, r.client.crash)
+		},
+		check: func(r *scenarioRun) error {
+			if err := expectData(r.server, "hello"); err != nil {
+				return err
+			}
+			if !r.server.gave_up || r.server.state != LISTEN {
+				return fmt.Errorf("server should have given up and listen again, it is %s (gave up=%v)", r.server.state, r.server.gave_up)
+			}
+			return expectHistory(r.server, ESTABLISHED, CLOSED, LISTEN)
+		},
+	},
+	{
+		name:        "half-open-reboot",
+		description: "the client crashes and reboots, a keepalive probe gets a RST and the client connects again",
+		setup: func(r *scenarioRun) {
+			r.server.use_keepalive = true
+			r.server.on_fail = r.server.listen
+			r.server.listen()
+			r.client.connect()
+			r.client.write([]byte("hello"))
+			r.sim.schedule(100*time.Millisecond, r.client.crash)
+			r.sim.schedule(time.Second, func() { r.client.reboot(5000) })
+			r.sim.schedule(3*time.Second, func() {
+				r.client.connect()
+				r.client.write([]byte(" again"))
+			})
+		},
+		check: func(r *scenarioRun) error {
+			if err := expectData(r.server, "hello again"); err != nil {
+				return err
+			}
+			if !r.server.reset || r.packets.counts["client RST"] != 1 {
+				return fmt.Errorf("server should have been reset by the rebooted client (reset=%v)", r.server.reset)
+			}
+			return expectHistory(r.server, ESTABLISHED, CLOSED, LISTEN, SYN_RCVD, ESTABLISHED)
+		},
+	},
+	{
+		name:        "nagle-stall",
+		description: "Nagle holds back the second half of each request, the delayed ACK holds back the ACK it waits for",
+		setup: func(r *scenarioRun) {
+			for _, e := range []*endpoint{r.client, r.server} {
+				e.use_nagle = true
+				e.use_delack = true
+				e.mss = 64
+			}
+			r.server.on_close = r.server.close
+			r.server.listen()
+			r.client.connect()
+			r.sim.schedule(100*time.Millisecond, func() {
+				r.rr = startRequestResponse(r.client, r.server, 3)
+			})
+		},
+		check: func(r *scenarioRun) error {
+			if len(r.rr.latencies) != 3 {
+				return fmt.Errorf("client got %d responses, expected 3", len(r.rr.latencies))
+			}
+			for i, l := range r.rr.latencies {
+				if l < delayed_ack_timeout {
+					return fmt.Errorf("request %d took %v, it should have stalled for the delayed ACK (%v)", i, l, delayed_ack_timeout)
+				}
+			}
+			return nil
+		},
+	},
+}
+
+// expectHistory checks that e went through these states in this order (other states may come in between)
+func expectHistory(e *endpoint, states ...string) error {
+	i := 0
+	for _, state := range e.history {
+		if i < len(states) && state == states[i] {
+			i++
+		}
+	}
+	if i < len(states) {
+		return fmt.Errorf("%s went through %s, expected %s", e.name, strings.Join(e.history, " -> "), strings.Join(states, " -> "))
+	}
+	return nil
+}
+
+func expectData(e *endpoint, data string) error {
+	if !bytes.Equal(e.delivered, []byte(data)) {
+		return fmt.Errorf("%s received '%s', expected '%s'", e.name, e.delivered, data)
+	}
+	return nil
+}
+
+// runScenario runs one scenario and checks the outcome
+func runScenario(sc scenario, taps ...tap) error {
+	sim := newSimulator()
+	net := newNetwork(sim)
+	r := &scenarioRun{
+		sim:     sim,
+		net:     net,
+		client:  newEndpoint("client", sim, net, 1),
+		server:  newEndpoint("server", sim, net, 100),
+		packets: &packetCounter{counts: make(map[string]int), biggest: make(map[string]int)},
+	}
+	net.addTap(r.packets)
+	for _, t := range taps {
+		net.addTap(t)
+	}
+
+	sc.setup(r)
+	sim.run(time.Minute)
+	return sc.check(r)
+}
+
+// runScenarios runs the scenario called name ("all" runs every one, "list" shows them).
+// It returns false if a check failed.
+func runScenarios(name string) bool {
+	if name == "list" {
+		for _, sc := range scenarios {
+			fmt.Printf("%-20s %s\n", sc.name, sc.description)
+		}
+		return true
+	}
+
+	if name != "all" {
+		i := slices.IndexFunc(scenarios, func(sc scenario) bool { return sc.name == name })
+		if i < 0 {
+			fmt.Printf("ERROR: no scenario called %q (try -scenario list)\n", name)
+			return false
+		}
+		sc := scenarios[i]
+		fmt.Printf("Scenario %s: %s\n\n", sc.name, sc.description)
+		ladder := &diagram{}
+		err := runScenario(sc, ladder)
+		fmt.Println("\nSequence diagram:")
+		ladder.printASCII(os.Stdout)
+		if err != nil {
+			fmt.Printf("\nFAIL %s: %v\n", sc.name, err)
+			return false
+		}
+		fmt.Printf("\nPASS %s\n", sc.name)
+		return true
+	}
+
+	verbose = false
+	ok := true
+	for _, sc := range scenarios {
+		if err := runScenario(sc); err != nil {
+			fmt.Printf("FAIL %-20s %v\n", sc.name, err)
+			ok = false
+		} else {
+			fmt.Printf("PASS %-20s %s\n", sc.name, sc.description)
+		}
+	}
+	return ok
+}
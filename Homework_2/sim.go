@@ -0,0 +1,101 @@
+package main
+
+import (
+	"container/heap"
+	"time"
+)
+
+// Something that should happen at a point in (virtual) time
+type event struct {
+	at        time.Duration
+	order     int // events at the same time run in the order they were scheduled
+	what      func()
+	cancelled bool
+}
+
+// eventQueue is a min-heap of events sorted by time
+type eventQueue []*event
+
+func (q eventQueue) Len() int { return len(q) }
+func (q eventQueue) Less(i, j int) bool {
+	if q[i].at != q[j].at {
+		return q[i].at < q[j].at
+	}
+	return q[i].order < q[j].order
+}
+func (q eventQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+func (q *eventQueue) Push(x any)   { *q = append(*q, x.(*event)) }
+func (q *eventQueue) Pop() any {
+	old := *q
+	e := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return e
+}
+
+// simulator is a discrete-event simulator with a virtual clock.
+// Nothing sleeps: the clock jumps straight to the next event, so a scenario
+// runs instantly and does exactly the same thing every time.
+type simulator struct {
+	now    time.Duration
+	queue  eventQueue
+	events int
+}
+
+func newSimulator() *simulator {
+	return &simulator{}
+}
+
+// schedule runs what after delay (virtual time). The returned event can be cancelled.
+func (s *simulator) schedule(delay time.Duration, what func()) *event {
+	s.events++
+	e := &event{at: s.now + delay, order: s.events, what: what}
+	heap.Push(&s.queue, e)
+	return e
+}
+
+// cancel stops an event from running (used for timers). nil is allowed.
+func (s *simulator) cancel(e *event) {
+	if e != nil {
+		e.cancelled = true
+	}
+}
+
+// next throws away cancelled events and returns the next real one (nil if none)
+func (s *simulator) next() *event {
+	for s.queue.Len() > 0 && s.queue[0].cancelled {
+		heap.Pop(&s.queue)
+	}
+	if s.queue.Len() == 0 {
+		return nil
+	}
+	return s.queue[0]
+}
+
+// step runs the next event, it returns false when there is nothing left to do
+func (s *simulator) step() bool {
+	if s.next() == nil {
+		return false
+	}
+	e := heap.Pop(&s.queue).(*event)
+	s.now = e.at
+	e.what()
+	return true
+}
+
+// run runs events until the queue is empty or the clock passes limit
+func (s *simulator) run(limit time.Duration) {
+	for {
+		e := s.next()
+		if e == nil || e.at > limit {
+			return
+		}
+		s.step()
+	}
+}
+
+// advance lets d of virtual time pass, running every event on the way
+func (s *simulator) advance(d time.Duration) {
+	until := s.now + d
+	s.run(until)
+	s.now = until
+}
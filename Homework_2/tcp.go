@@ -0,0 +1,667 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// Connection states
+const (
+	CLOSED      = "CLOSED"
+	LISTEN      = "LISTEN"
+	SYN_SENT    = "SYN_SENT"
+	SYN_RCVD    = "SYN_RCVD"
+	ESTABLISHED = "ESTABLISHED"
+	FIN_WAIT_1  = "FIN_WAIT_1" // we closed, our FIN is not acknowledged yet
+	FIN_WAIT_2  = "FIN_WAIT_2" // our FIN is acknowledged, the other side can still send
+	CLOSE_WAIT  = "CLOSE_WAIT" // the other side closed, we can still send
+	CLOSING     = "CLOSING"    // both closed at the same time
+	LAST_ACK    = "LAST_ACK"   // both closed, waiting for the ACK of our FIN
+	TIME_WAIT   = "TIME_WAIT"  // waiting for old packets to die before CLOSED
+)
+
+// How long TIME_WAIT lasts (2*MSL, much shorter than real TCP to keep runs short)
+const time_wait_duration = 1 * time.Second
+
+// How long to wait for an answer before sending again, doubled after every try
+// (but never more than max_rto)
+const (
+	initial_rto = 200 * time.Millisecond
+	max_rto     = 3 * time.Second
+	max_retries = 8
+)
+
+// Data is cut into segments of at most mss bytes (or less if the other side
+// wants smaller ones) and at most window bytes can be sent without being acknowledged
+const (
+	default_mss    = 8
+	default_window = 32
+)
+
+// Print what the endpoints do (switched off when running many fuzz cases)
+var verbose = true
+
+// endpoint is one side of the connection (client or server).
+// It does not run in its own goroutine, the simulator calls receive
+// when a packet arrives and fires its timers when they expire.
+type endpoint struct {
+	name  string
+	sim   *simulator
+	net   *network
+	state string
+	// every state we have been in, in order
+	history []string
+	passive bool // we got here by listen, after a RST we listen again
+
+	my_seq   int // my initial sequence number
+	peer_seq int // the other side's initial sequence number
+
+	// sending side
+	snd_una  int    // oldest byte that is not acknowledged yet
+	snd_nxt  int    // next byte to send
+	snd_max  int    // highest byte sent so far (snd_nxt goes back after a timeout)
+	send_buf []byte // bytes from snd_una on which the application wrote
+	mss      int    // biggest segment we send and receive
+	window   int    // our own limit for bytes in flight
+	snd_wnd  int    // the other side's receive window
+	dup_acks int    // ACKs in a row that did not acknowledge anything new
+
+	// closing
+	fin_queued bool // the application closed its write half
+	fin_seq    int  // sequence number of our FIN (right after the last byte)
+	fin_rcvd   bool // the other side closed its write half
+	time_wait  *event
+
+	// selective acknowledgements
+	use_sack bool     // we want to use SACK
+	sack_ok  bool     // both sides agreed on SACK in the handshake
+	sacked   [][2]int // ranges [start, end) the receiver told us it has
+	high_rxt int      // holes below this were already retransmitted
+
+	// window scaling and timestamps (see options.go)
+	use_wscale   bool // we want to scale our windows
+	use_ts       bool // we want to use timestamps
+	wscale_ok    bool // both sides agreed on window scaling
+	ts_ok        bool // both sides agreed on timestamps
+	peer_mss     int  // biggest segment the other side wants
+	rcv_shift    int  // the windows we send are shifted right by this
+	snd_shift    int  // the windows we get are shifted left by this
+	ts_recent    int  // the latest ts_val from the other side, echoed back
+	paws_dropped int  // old duplicates thrown away by PAWS
+	srtt         time.Duration
+	rttvar       time.Duration
+	rtt_samples  int
+
+	// statistics
+	retransmitted_bytes    int
+	retransmitted_segments int
+	keepalives_sent        int
+	finished_at            time.Duration // when everything written was acknowledged
+
+	// receiving side
+	rcv_nxt      int            // next byte we expect
+	rcv_buf      int            // how many bytes we can keep (our receive window)
+	out_of_order map[int]Packet // segments that arrived too early, by seq
+	delivered    []byte         // bytes handed to the application, in order
+	on_deliver   func(seq int, data []byte)
+	on_close     func() // the other side closed its write half
+	on_fail      func() // the connection was reset or the other side stopped answering
+
+	// retransmission timer for the oldest packet we are waiting an answer for
+	retransmit *event
+	rto        time.Duration
+	retries    int
+	last_sent  Packet // handshake packet to send again
+
+	// keepalive probes on an idle connection (see keepalive.go)
+	use_keepalive bool
+	keepalive     *event
+	probes_sent   int
+	crashed       bool // the endpoint is gone and ignores everything
+
+	// delayed ACKs and Nagle's algorithm (see nagle.go)
+	use_delack    bool
+	use_nagle     bool
+	delack        *event // sends the ACK we are holding back
+	last_ack_sent int
+
+	gave_up bool // the connection failed because the other side stopped answering
+	reset   bool // the connection was reset (RST) by the other side
+}
+
+func newEndpoint(name string, sim *simulator, net *network, my_seq int) *endpoint {
+	e := &endpoint{
+		name:         name,
+		sim:          sim,
+		net:          net,
+		state:        CLOSED,
+		history:      []string{CLOSED},
+		my_seq:       my_seq,
+		snd_una:      my_seq + 1,
+		snd_nxt:      my_seq + 1,
+		snd_max:      my_seq + 1,
+		mss:          default_mss,
+		window:       default_window,
+		peer_mss:     default_mss,
+		rcv_buf:      default_rcv_buf,
+		out_of_order: make(map[int]Packet),
+		rto:          initial_rto,
+	}
+	net.attach(e)
+	return e
+}
+
+// log prints a line prefixed with the virtual time and the endpoint name
+func (e *endpoint) log(format string, args ...any) {
+	if verbose {
+		fmt.Printf("[%8v] %s: %s\n", e.sim.now, e.name, fmt.Sprintf(format, args...))
+	}
+}
+
+func (e *endpoint) setState(state string) {
+	e.log("%s -> %s", e.state, state)
+	e.state = state
+	e.history = append(e.history, state)
+	e.resetKeepalive()
+}
+
+// synchronized is true in the states where both sides know each other's sequence numbers
+func (e *endpoint) synchronized() bool {
+	switch e.state {
+	case CLOSED, LISTEN, SYN_SENT, SYN_RCVD:
+		return false
+	}
+	return true
+}
+
+// send puts a packet on the network
+func (e *endpoint) send(p Packet) {
+	if e.crashed {
+		return
+	}
+	e.stamp(&p)
+	if p.is_ack && !p.is_rst {
+		// every packet carries the ACK, so a delayed one is not needed anymore
+		e.last_ack_sent = p.ack
+		e.sim.cancel(e.delack)
+		e.delack = nil
+	}
+	e.log("sending %v", p)
+	e.net.send(e.name, p)
+}
+
+// sendReliable sends a handshake packet and keeps sending it again until stopTimer is called
+func (e *endpoint) sendReliable(p Packet) {
+	e.last_sent = p
+	e.retries = 0
+	e.rto = initial_rto
+	e.send(p)
+	e.startTimer()
+}
+
+func (e *endpoint) startTimer() {
+	e.sim.cancel(e.retransmit)
+	e.retransmit = e.sim.schedule(e.rto, e.timeout)
+}
+
+func (e *endpoint) stopTimer() {
+	e.sim.cancel(e.retransmit)
+	e.retransmit = nil
+}
+
+// timeout is called when no answer came back in time
+func (e *endpoint) timeout() {
+	e.retransmit = nil
+	if e.retries >= max_retries {
+		e.log("no answer after %d retries, giving up", e.retries)
+		e.gave_up = true
+		e.setState(CLOSED)
+		e.failed()
+		return
+	}
+	if e.state == CLOSED {
+		return
+	}
+	e.retries++
+	e.rto = min(2*e.rto, max_rto)
+	e.log("timeout! retransmitting (try %d, next timeout %v)", e.retries, e.rto)
+	if e.state == SYN_SENT || e.state == SYN_RCVD {
+		e.send(e.last_sent)
+	} else if e.sack_ok {
+		// only send what the receiver does not have
+		e.high_rxt = e.snd_una
+		e.retransmitHoles(e.snd_max)
+	} else {
+		// go back N: send everything again from the oldest unacknowledged byte
+		e.snd_nxt = e.snd_una
+		e.output()
+	}
+	e.startTimer()
+}
+
+// listen makes the server wait for a SYN
+func (e *endpoint) listen() {
+	if e.state == CLOSED && len(e.history) > 1 {
+		// start over after an earlier connection
+		e.forget()
+	}
+	e.log("listening with seq=%d", e.my_seq)
+	e.passive = true
+	e.setState(LISTEN)
+}
+
+// connect makes the client start the handshake
+func (e *endpoint) connect() {
+	if e.state == CLOSED && len(e.history) > 1 {
+		// start over after an earlier connection
+		e.forget()
+	}
+	e.log("Step 1: sending SYN with seq=%d", e.my_seq)
+	e.setState(SYN_SENT)
+	e.sendReliable(e.synOptions(Packet{
+		seq:    e.my_seq,
+		ack:    0, // no ack yet
+		is_syn: true,
+		is_ack: false,
+	}))
+}
+
+// write queues data for sending, it goes out as soon as the connection is established
+func (e *endpoint) write(data []byte) {
+	if e.fin_queued {
+		e.log("ERROR: can't write after close")
+		return
+	}
+	e.send_buf = append(e.send_buf, data...)
+	e.output()
+}
+
+// close closes our write half: a FIN is sent after the last byte.
+// We can still receive until the other side closes too.
+func (e *endpoint) close() {
+	switch e.state {
+	case CLOSED, LISTEN:
+		e.setState(CLOSED)
+		return
+	case ESTABLISHED:
+		e.setState(FIN_WAIT_1)
+	case CLOSE_WAIT:
+		e.setState(LAST_ACK)
+	case SYN_SENT, SYN_RCVD:
+		// the FIN goes out after the data, once the handshake is done
+	default:
+		e.log("already closing")
+		return
+	}
+	e.fin_queued = true
+	e.fin_seq = e.snd_una + len(e.send_buf)
+	e.output()
+}
+
+// abort throws the connection away and tells the other side with a RST
+func (e *endpoint) abort() {
+	if e.synchronized() || e.state == SYN_RCVD {
+		e.log("aborting the connection")
+		e.send(Packet{seq: e.snd_nxt, ack: e.rcv_nxt, is_ack: true, is_rst: true})
+	}
+	e.send_buf = nil
+	e.stopTimer()
+	e.sim.cancel(e.time_wait)
+	e.setState(CLOSED)
+}
+
+// done is true when everything that was written has been acknowledged
+func (e *endpoint) done() bool {
+	return len(e.send_buf) == 0
+}
+
+// segment builds the data packet starting at byte seq, not going past byte limit
+// (or the FIN if seq is right after the last byte)
+func (e *endpoint) segment(seq int, limit int) Packet {
+	if e.fin_queued && seq == e.fin_seq {
+		return Packet{seq: seq, ack: e.rcv_nxt, is_ack: true, is_fin: true}
+	}
+	start := seq - e.snd_una
+	end := min(start+e.sendMSS(), limit-e.snd_una, len(e.send_buf))
+	return Packet{
+		seq:     seq,
+		ack:     e.rcv_nxt,
+		is_ack:  true,
+		message: string(e.send_buf[start:end]),
+	}
+}
+
+// inFlight is how many bytes are sent but not yet at the receiver as far as we know
+func (e *endpoint) inFlight() int {
+	return e.snd_nxt - e.snd_una - sackedBytes(e.sacked)
+}
+
+// output sends new segments as long as the window allows it, and the FIN after the last one.
+// When nothing is in flight one segment always goes out, so a closed window gets probed.
+func (e *endpoint) output() {
+	if !e.synchronized() || e.state == TIME_WAIT {
+		return
+	}
+	for e.inFlight() < e.sendWindow() || e.inFlight() <= 0 {
+		data_left := e.snd_nxt < e.snd_una+len(e.send_buf)
+		fin_left := e.fin_queued && e.snd_nxt == e.fin_seq
+		if !data_left && !fin_left {
+			break
+		}
+		p := e.segment(e.snd_nxt, e.snd_una+len(e.send_buf))
+		if e.nagleHolds(p) {
+			break
+		}
+		e.sendSegment(p)
+		e.snd_nxt += p.seqLen()
+		e.snd_max = max(e.snd_max, e.snd_nxt)
+		if e.retransmit == nil {
+			e.startTimer()
+		}
+	}
+}
+
+// sendSegment sends a data segment and counts it if it is a retransmission
+func (e *endpoint) sendSegment(p Packet) {
+	if p.seq < e.snd_max {
+		e.retransmitted_segments++
+		e.retransmitted_bytes += min(len(p.message), e.snd_max-p.seq)
+	}
+	e.send(p)
+}
+
+// ackPacket is a packet without data that tells the other side what we expect next
+func (e *endpoint) ackPacket() Packet {
+	p := Packet{seq: e.snd_nxt, ack: e.rcv_nxt, is_ack: true}
+	if e.sack_ok {
+		p.sack_blocks = e.sackBlocks()
+	}
+	return p
+}
+
+// established is called once when the handshake is done
+func (e *endpoint) established() {
+	e.stopTimer()
+	e.retries = 0
+	e.rto = e.baseRTO()
+	e.setState(ESTABLISHED)
+	if e.fin_queued {
+		// close was called during the handshake
+		e.setState(FIN_WAIT_1)
+	}
+	e.output()
+}
+
+// receive is called by the network when a packet arrives
+func (e *endpoint) receive(p Packet) {
+	if e.crashed {
+		return
+	}
+	e.log("got %v", p)
+
+	if p.is_rst {
+		e.handleReset(p)
+		return
+	}
+	if !e.checkTimestamp(p) {
+		return
+	}
+	e.resetKeepalive()
+
+	switch e.state {
+	case CLOSED:
+		// nobody is here, tell the sender
+		e.sendReset(p)
+
+	case LISTEN:
+		if p.is_syn && !p.is_ack {
+			e.log("Step 2: client sent SYN! Sending SYN-ACK back...")
+			e.setState(SYN_RCVD)
+			e.sendSynAck(p)
+		} else if p.is_ack {
+			e.log("ERROR: expected SYN packet")
+			e.sendReset(p)
+		}
+
+	case SYN_RCVD:
+		if p.is_syn && !p.is_ack {
+			// a SYN again means our SYN-ACK got lost
+			e.sendSynAck(p)
+		} else if p.is_ack && p.ack == e.my_seq+1 {
+			if p.is_syn {
+				// simultaneous open: this is the other side's SYN-ACK
+				e.send(e.ackPacket())
+			}
+			// the final ACK, or data from a client whose final ACK got lost
+			e.log("Connection established!")
+			e.established()
+			e.handleAck(p)
+			e.handleData(p)
+		} else {
+			e.log("unexpected packet, ignoring")
+		}
+
+	case SYN_SENT:
+		if p.is_syn && p.is_ack && p.ack == e.my_seq+1 {
+			e.log("Step 3: server sent SYN-ACK! Sending final ACK...")
+			e.peer_seq = p.seq
+			e.rcv_nxt = p.seq + 1
+			e.negotiate(p)
+			e.send(e.ackPacket())
+			e.established()
+		} else if p.is_syn && !p.is_ack {
+			// the other side is connecting to us at the same time
+			e.log("got a SYN while connecting: simultaneous open, sending SYN-ACK")
+			e.setState(SYN_RCVD)
+			e.sendSynAck(p)
+		} else if p.is_ack {
+			e.sendReset(p)
+		}
+
+	default:
+		if p.is_syn {
+			// our final ACK got lost and the server sent its SYN-ACK again
+			e.send(e.ackPacket())
+			return
+		}
+		e.handleAck(p)
+		e.handleData(p)
+	}
+}
+
+// sendReset answers a packet that does not belong to any connection with a RST
+func (e *endpoint) sendReset(p Packet) {
+	if p.is_ack {
+		e.send(Packet{seq: p.ack, is_rst: true})
+	} else {
+		e.send(Packet{seq: 0, ack: p.seq + p.seqLen(), is_ack: true, is_rst: true})
+	}
+}
+
+// handleReset checks that a RST is really for us and drops the connection
+func (e *endpoint) handleReset(p Packet) {
+	switch e.state {
+	case CLOSED, LISTEN:
+		return
+	case SYN_SENT:
+		if !p.is_ack || p.ack != e.my_seq+1 {
+			return
+		}
+		e.log("connection refused")
+	default:
+		// an old RST from some other connection must not kill this one
+		if p.seq < e.rcv_nxt || p.seq >= e.rcv_nxt+max(e.rcv_buf, 1) {
+			e.log("RST with seq=%d is outside the window, ignoring", p.seq)
+			return
+		}
+		e.log("connection reset by peer")
+	}
+	e.reset = true
+	e.stopTimer()
+	e.sim.cancel(e.time_wait)
+	if e.state == SYN_RCVD && e.passive {
+		e.setState(LISTEN)
+	} else {
+		e.setState(CLOSED)
+	}
+	e.failed()
+}
+
+func (e *endpoint) sendSynAck(syn Packet) {
+	e.peer_seq = syn.seq
+	e.rcv_nxt = syn.seq + 1
+	e.negotiate(syn)
+	e.sendReliable(e.synOptions(Packet{
+		seq:    e.my_seq,  // my sequence number
+		ack:    e.rcv_nxt, // client's seq + 1
+		is_syn: true,
+		is_ack: true,
+	}))
+}
+
+// handleAck removes acknowledged bytes from the send buffer
+func (e *endpoint) handleAck(p Packet) {
+	if !p.is_ack {
+		return
+	}
+	e.updateWindow(p)
+	if e.sack_ok && e.addSacked(p.sack_blocks) {
+		// the receiver got new data, so it is still there
+		e.retries = 0
+		e.rto = e.baseRTO()
+	}
+
+	if p.ack > e.snd_una && p.ack <= e.snd_max {
+		e.send_buf = e.send_buf[min(p.ack-e.snd_una, len(e.send_buf)):]
+		e.snd_una = p.ack
+		e.snd_nxt = max(e.snd_nxt, p.ack)
+		e.dup_acks = 0
+		e.retries = 0
+		e.measureRTT(p)
+		e.rto = e.baseRTO()
+		e.dropSacked()
+		if len(e.sacked) > 0 {
+			// the receiver still misses something before the blocks it has
+			e.retransmitHoles(e.highestSacked())
+		}
+		if e.snd_una == e.snd_max {
+			e.stopTimer()
+			if e.done() {
+				e.finished_at = e.sim.now
+			}
+		} else {
+			e.startTimer()
+		}
+		if e.fin_queued && e.snd_una == e.fin_seq+1 {
+			e.finAcked()
+		}
+		e.output()
+	} else if p.ack == e.snd_una && p.seqLen() == 0 && e.snd_una < e.snd_max {
+		// the receiver is still waiting for snd_una, after 3 of these
+		// we don't wait for the timeout and retransmit right away
+		e.dup_acks++
+		if e.dup_acks == 3 {
+			e.log("3 duplicate ACKs for %d, fast retransmit", p.ack)
+			if e.sack_ok {
+				e.retransmitHoles(e.highestSacked())
+			} else {
+				e.sendSegment(e.segment(e.snd_una, e.snd_max))
+			}
+		} else if e.dup_acks > 3 && e.sack_ok {
+			// new SACK blocks may show new holes
+			e.retransmitHoles(e.highestSacked())
+		}
+	}
+}
+
+// handleData delivers data in order, keeps early segments and acknowledges
+func (e *endpoint) handleData(p Packet) {
+	if p.seqLen() == 0 {
+		return
+	}
+
+	in_order := false
+	if p.seq > e.rcv_nxt {
+		e.log("segment seq=%d arrived early (expected %d), keeping it", p.seq, e.rcv_nxt)
+		if p.seqLen() > e.out_of_order[p.seq].seqLen() {
+			e.out_of_order[p.seq] = p
+		}
+	} else if p.seq+p.seqLen() > e.rcv_nxt {
+		in_order = true
+		e.deliver(p)
+		// maybe the segments we kept can go now
+		for progress := true; progress; {
+			progress = false
+			for seq, kept := range e.out_of_order {
+				if seq > e.rcv_nxt {
+					continue
+				}
+				delete(e.out_of_order, seq)
+				if seq+kept.seqLen() > e.rcv_nxt {
+					e.deliver(kept)
+					progress = true
+				}
+			}
+		}
+	}
+
+	// tell the sender what we expect next (duplicates get acked again right away)
+	e.acknowledge(in_order)
+}
+
+// deliver hands the new part of a segment to the application
+func (e *endpoint) deliver(p Packet) {
+	if from := e.rcv_nxt - p.seq; from < len(p.message) {
+		data := []byte(p.message)[from:]
+		seq := e.rcv_nxt
+		e.delivered = append(e.delivered, data...)
+		e.rcv_nxt += len(data)
+		// the application may answer right away, its data then carries the new ACK
+		if e.on_deliver != nil {
+			e.on_deliver(seq, data)
+		}
+	}
+	if p.is_fin && !e.fin_rcvd {
+		e.rcv_nxt++
+		e.peerClosed()
+	}
+}
+
+// peerClosed is called when the FIN arrives, after all the data before it
+func (e *endpoint) peerClosed() {
+	e.log("the other side closed its write half")
+	e.fin_rcvd = true
+	switch e.state {
+	case ESTABLISHED:
+		e.setState(CLOSE_WAIT)
+	case FIN_WAIT_1:
+		e.setState(CLOSING)
+	case FIN_WAIT_2:
+		e.enterTimeWait()
+	}
+	if e.on_close != nil {
+		e.on_close()
+	}
+}
+
+// finAcked is called when the other side acknowledged our FIN
+func (e *endpoint) finAcked() {
+	switch e.state {
+	case FIN_WAIT_1:
+		e.setState(FIN_WAIT_2)
+	case CLOSING:
+		e.enterTimeWait()
+	case LAST_ACK:
+		e.setState(CLOSED)
+	}
+}
+
+// enterTimeWait waits a while before CLOSED, so a lost last ACK can still be sent again
+func (e *endpoint) enterTimeWait() {
+	e.stopTimer()
+	e.setState(TIME_WAIT)
+	e.time_wait = e.sim.schedule(time_wait_duration, func() {
+		e.time_wait = nil
+		e.setState(CLOSED)
+	})
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	proto "ITUserver/grpc"
+	"fmt"
+	"log"
+
+	"context"
+	"sync"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// The server wants the session token in this metadata key on every call
+const tokenKey = "session-token"
+
+// sessionToken puts the token of our session in the metadata of every call
+type sessionToken struct {
+	mu    sync.Mutex
+	token string
+}
+
+func (t *sessionToken) set(token string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.token = token
+}
+
+func (t *sessionToken) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.token == "" {
+		return nil, nil
+	}
+	return map[string]string{tokenKey: t.token}, nil
+}
+
+func (t *sessionToken) RequireTransportSecurity() bool {
+	return false
+}
+
+// login logs in, or registers the name if nobody has it yet and register is set. Every call after it
+// carries the new session token. Logging in again after a reconnect never registers: a server that does
+// not know the name is not one we should make a new account on.
+func (c *userInfo) login(password string, register bool) error {
+	creds := &proto.Credentials{ParticipantName: c.name, Password: password}
+	resp, err := c.api().Login(c.ctx, creds)
+	if status.Code(err) == codes.NotFound && register {
+		resp, err = c.api().Register(c.ctx, creds)
+		if err == nil {
+			fmt.Printf("Registered %s\n", c.name)
+			log.Printf("[Client %s] Registered", c.name)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	c.session.set(resp.Token)
+	c.password = password
+	log.Printf("[Client %s] Logged in", c.name)
+	return nil
+}
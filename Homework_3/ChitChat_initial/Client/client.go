@@ -4,151 +4,389 @@ import (
 	proto "ITUserver/grpc"
 	"bufio"
 	"context"
+	"flag"
 	"fmt"
 	"log"
 	"os"
+	"strconv"
 	"strings"
 	"sync"
 
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
 )
 
 type userInfo struct {
 	name         string
+	servers      []string // replicas of the server, we use one at a time
+	server       int      // index of the one we use
 	conn         *grpc.ClientConn
 	client       proto.ITUDatabaseClient
 	ctx          context.Context
 	cancel       context.CancelFunc
 	mu           sync.Mutex
-	lamportClock int64
+	lamportClock map[string]int64 // every room has its own clock
+	room         string           // messages typed go to this room
+	causal       map[string]*causalRoom
+
+	session   *sessionToken
+	password  string // to log in again when the server forgot the session
+	creds     credentials.TransportCredentials
+	certName  string // with a client certificate we are this participant, without a password
+	lastSeq   uint64 // sequence number of the last message we got
+	connected bool
+	leaving   bool
+
+	slowPolicy proto.SlowConsumerPolicy // asked for when joining
+	useSession bool                     // join, chat and leave on a Session stream
+	sess       *session                 // the last Session stream we opened
+
+	typing   bool                            // sent with the heartbeats
+	presence map[string]*proto.PresenceEvent // what we know of the others
 }
 
-func createUser(name string) (*userInfo, error) {
+// openLog sends the log to client_<name>.log
+func openLog(name string) error {
 	logFile, err := os.OpenFile(fmt.Sprintf("client_%s.log", name), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0666)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	log.SetOutput(logFile)
+	return nil
+}
 
-	conn, err := grpc.Dial("localhost:5000", grpc.WithTransportCredentials(insecure.NewCredentials()))
-	if err != nil {
+func createUser(name string, servers []string, creds credentials.TransportCredentials) (*userInfo, error) {
+	if err := openLog(name); err != nil {
 		return nil, err
 	}
 
 	ctx, cancel := context.WithCancel(context.Background())
-	return &userInfo{
-		name:   name,
-		conn:   conn,
-		client: proto.NewITUDatabaseClient(conn),
-		ctx:    ctx,
-		cancel: cancel,
-	}, nil
+	c := &userInfo{
+		name:         name,
+		servers:      servers,
+		ctx:          ctx,
+		cancel:       cancel,
+		lamportClock: make(map[string]int64),
+		room:         generalRoom,
+		causal:       make(map[string]*causalRoom),
+		session:      &sessionToken{},
+		creds:        creds,
+		presence:     make(map[string]*proto.PresenceEvent),
+	}
+	if err := c.dial(servers[0]); err != nil {
+		return nil, err
+	}
+	return c, nil
 }
 
-func (c *userInfo) join() error {
-	log.Printf("[Client %s] Connecting to server %s", c.name, c.conn.Target())
+// join opens the message stream. If since is not nil the server first replays
+// the history after that Lamport time.
+func (c *userInfo) join(since *int64) error {
+	log.Printf("[Client %s] Connecting to server %s", c.name, c.currentServer())
 
-	stream, err := c.client.JoinChat(c.ctx, &proto.JoinRequest{
+	stream, _, err := c.openStream(&proto.JoinRequest{
 		ParticipantName: c.name,
+		SinceLamport:    since,
+		SlowPolicy:      c.slowPolicy,
 	})
 	if err != nil {
 		return err
 	}
+	c.setConnected(true)
 
 	go c.receiveMessages(stream)
 	return nil
 }
 
-func (c *userInfo) receiveMessages(stream proto.ITUDatabase_JoinChatClient) {
+// receiveMessages shows the messages of the stream, and opens a new one when it breaks
+func (c *userInfo) receiveMessages(stream messageStream) {
+	for {
+		err := c.readStream(stream)
+		if c.isLeaving() {
+			return
+		}
+		if status.Code(err) == codes.PermissionDenied {
+			// kicked or banned, coming back is not up to us
+			log.Printf("[Client %s] Removed from the chat: %v", c.name, err)
+			fmt.Println(status.Convert(err).Message())
+			c.cancel()
+			os.Exit(0)
+		}
+		log.Printf("[Client %s] Disconnected: %v", c.name, err)
+		fmt.Println("Lost the connection to the server, reconnecting...")
+		c.setConnected(false)
+
+		if stream = c.reconnect(); stream == nil {
+			return
+		}
+		c.setConnected(true)
+	}
+}
+
+func (c *userInfo) readStream(stream messageStream) error {
 	for {
 		msg, err := stream.Recv()
 		if err != nil {
-			log.Printf("[Client %s] Disconnected", c.name)
-			return
+			return err
+		}
+		if c.seen(msg) {
+			continue
 		}
 
-		newTime := c.updateClock(msg.LamportTimestamp)
+		if msg.Type == proto.MessageType_NOTICE {
+			fmt.Printf("[notice] %s\n", msg.Content)
+			log.Printf("[Client: %s] Notice: %s", c.name, msg.Content)
+			// what the held back messages wait for may be among the missed ones
+			c.showMessages(c.flushPending())
+			continue
+		}
+
+		if msg.Type == proto.MessageType_PRIVATE {
+			newTime := c.updateClock(privateClock, msg.LamportTimestamp)
+			fmt.Printf("[private Lamport: %d] %s \n", newTime, msg.Content)
+			log.Printf("[Client: %s] Private message: %s (Lamport: %d)", c.name, msg.Content, newTime)
+			continue
+		}
 
-		fmt.Printf("[Lamport: %d] %s \n", newTime, msg.Content)
-		log.Printf("[Client: %s] Recieved: %s (Lamport: %d)", c.name, msg.Content, newTime)
+		room := msg.Room
+		if room == "" {
+			room = generalRoom
+		}
+
+		if msg.Replayed {
+			c.updateClock(room, msg.LamportTimestamp)
+			c.replayed(room, msg)
+			fmt.Printf("[%s Lamport: %d] (history) %s \n", room, msg.LamportTimestamp, msg.Content)
+			log.Printf("[Client: %s] Replayed in %s: %s (Lamport: %d)", c.name, room, msg.Content, msg.LamportTimestamp)
+			continue
+		}
+
+		ready := c.receiveCausal(room, msg)
+		if len(ready) == 0 {
+			log.Printf("[Client: %s] Holding back %s in %s until the messages it depends on arrive (vector: %v)", c.name, msg.Content, room, vectorClock(msg.VectorClock))
+		}
+		c.showMessages(ready)
+	}
+}
+
+func (c *userInfo) showMessages(ready []delivery) {
+	for _, d := range ready {
+		room := d.msg.Room
+		if room == "" {
+			room = generalRoom
+		}
+		newTime := c.updateClock(room, d.msg.LamportTimestamp)
+		note := ""
+		if len(d.concurrent) > 0 {
+			note = fmt.Sprintf(" (concurrent with %s)", strings.Join(d.concurrent, ", "))
+		}
+		fmt.Printf("[%s Lamport: %d] %s%s \n", room, newTime, d.msg.Content, note)
+		log.Printf("[Client: %s] Recieved in %s: %s (Lamport: %d, seq: %d, vector: %v)%s", c.name, room, d.msg.Content, newTime, d.msg.Seq, vectorClock(d.msg.VectorClock), note)
 	}
 }
 
 func (c *userInfo) sendMessage(msg string) error {
 	if len(msg) > 128 {
-		fmt.Errorf("Message too long - Max is 128 characters")
+		return fmt.Errorf("Message too long - Max is 128 characters")
 	}
 
 	if len(msg) == 0 {
-		fmt.Errorf("Message is empty")
+		return fmt.Errorf("Message is empty")
 	}
 
-	lamportTime := c.incrementClock()
+	room := c.currentRoom()
+	lamportTime := c.incrementClock(room)
+	vector := c.nextVector(room)
+	// logged before the call, the echo of the message may be logged before the call returns
+	log.Printf("[Client %s] Publishing message in %s: %d (vector: %v)", c.name, room, lamportTime, vector)
 
-	_, err := c.client.PublishMessage(c.ctx, &proto.ChatMessage{
+	resp, err := c.publish(&proto.ChatMessage{
 		ParticipantName: c.name,
 		Content:         msg,
 		Lamport:         lamportTime,
+		Room:            room,
+		VectorClock:     vector,
 	})
 	if err != nil {
+		log.Printf("[Client %s] Message not published: %v", c.name, err)
 		return err
 	}
-
-	log.Printf("[Client %s] Published message: %s", c.name, lamportTime)
+	if !resp.Success {
+		log.Printf("[Client %s] Message refused: %s", c.name, resp.Reason)
+		return fmt.Errorf("message refused, %s", resp.Reason)
+	}
+	c.sent(room, vector)
+	c.mu.Lock()
+	// the server stops showing us as typing when the message arrives
+	c.typing = false
+	c.mu.Unlock()
 	return nil
 }
 
 func (c *userInfo) leave() {
 	log.Printf("[Client %s] Leaving", c.name)
-	c.client.LeaveChat(c.ctx, &proto.LeaveRequest{
-		ParticipantName: c.name,
-	})
+	c.mu.Lock()
+	c.leaving = true
+	c.mu.Unlock()
+	c.sendLeave()
 
 	c.cancel()
+	c.mu.Lock()
 	c.conn.Close()
+	c.mu.Unlock()
 }
 
-func (c *userInfo) incrementClock() int64 {
+func (c *userInfo) incrementClock(room string) int64 {
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	c.lamportClock++
-	return c.lamportClock
+	c.lamportClock[room]++
+	return c.lamportClock[room]
 }
 
-func (c *userInfo) updateClock(received int64) int64 {
+func (c *userInfo) updateClock(room string, received int64) int64 {
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	if received > c.lamportClock {
-		c.lamportClock = received
+	if received > c.lamportClock[room] {
+		c.lamportClock[room] = received
+	}
+	c.lamportClock[room]++
+	return c.lamportClock[room]
+}
+
+// command runs a line that starts with a slash
+func (c *userInfo) command(input string) error {
+	fields := strings.Fields(input)
+	arg := ""
+	if len(fields) > 1 {
+		arg = fields[1]
+	}
+	switch fields[0] {
+	case "/rooms":
+		return c.listRooms()
+	case "/create":
+		return c.createRoom(arg)
+	case "/join":
+		return c.joinRoom(arg)
+	case "/leave":
+		return c.leaveRoom(arg)
+	case "/msg":
+		return c.sendPrivate(input)
+	case "/kick", "/mute", "/unmute", "/ban", "/unban":
+		return c.moderate(fields)
+	case "/who":
+		return c.who()
+	case "/typing":
+		c.setTyping(true)
+		fmt.Println("The others see that you are typing")
+		return nil
+	case "/help":
+		fmt.Println(commandHelp)
+		return nil
 	}
-	c.lamportClock++
-	return c.lamportClock
+	return fmt.Errorf("unknown command %s (type /help)", fields[0])
 }
 
 func main() {
-	if len(os.Args) < 2 {
-		fmt.Println("Please enter your clientname")
+	addr := flag.String("server", "localhost:5000", "address of the server, or comma separated addresses of its replicas")
+	p2p := flag.String("p2p", "", "run without a server: listen for the other nodes on this address, e.g. localhost:6001")
+	peers := flag.String("peers", "", "with -p2p: comma separated addresses of nodes to start with")
+	slowPolicy := flag.String("slow-policy", "", "what the server does when we can't keep up: drop-oldest or disconnect (default: the server's choice)")
+	useSession := flag.Bool("session", true, "join, chat and leave on one Session stream (false: JoinChat, PublishMessage and LeaveChat)")
+	caFile := flag.String("ca", "", "CA to check the certificate of the server with, the connection uses TLS")
+	certFile := flag.String("cert", "", "client certificate, the server lets us in as the participant it is for (uses TLS)")
+	keyFile := flag.String("key", "", "private key of the client certificate")
+	flag.Parse()
+	args := flag.Args()
+	if len(args) < 1 {
+		fmt.Println("Please enter your clientname (and optionally a Lamport time to see the history after it)")
 		os.Exit(1)
 	}
-	c, err := createUser(os.Args[1])
-	if err != nil {
-		log.Fatalf("Client not created: %e", err)
+	var since *int64
+	if len(args) > 1 {
+		t, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			fmt.Printf("%q is not a Lamport time\n", args[1])
+			os.Exit(1)
+		}
+		since = &t
 	}
-	if err := c.join(); err != nil {
-		log.Fatalf("Failed to join: %e", err)
+	if *p2p != "" {
+		if err := runGossip(args[0], *p2p, strings.Split(*peers, ","), bufio.NewScanner(os.Stdin)); err != nil {
+			log.Fatalf("Node failed: %v", err)
+		}
+		fmt.Println("Bye!")
+		return
 	}
 
-	fmt.Printf("Client %s joined succesfully (type /leave to leave)\n", c.name)
+	creds, certName, err := transportCredentials(*caFile, *certFile, *keyFile)
+	if err != nil {
+		fmt.Printf("Can't use the certificates: %v\n", err)
+		os.Exit(1)
+	}
+	if certName != "" && certName != args[0] {
+		fmt.Printf("The certificate is for %s, not %s\n", certName, args[0])
+		os.Exit(1)
+	}
+	c, err := createUser(args[0], strings.Split(*addr, ","), creds)
+	if err != nil {
+		log.Fatalf("Client not created: %v", err)
+	}
+	c.useSession = *useSession
+	c.certName = certName
+	if *slowPolicy != "" {
+		value, ok := proto.SlowConsumerPolicy_value[strings.ToUpper(strings.ReplaceAll(*slowPolicy, "-", "_"))]
+		if value == int32(proto.SlowConsumerPolicy_BLOCK) {
+			fmt.Println("only the server can use the block policy (-slow-policy on the server)")
+			os.Exit(1)
+		}
+		if !ok {
+			fmt.Printf("unknown slow consumer policy %q\n", *slowPolicy)
+			os.Exit(1)
+		}
+		c.slowPolicy = proto.SlowConsumerPolicy(value)
+	}
 
 	scanner := bufio.NewScanner(os.Stdin)
+	if c.certName == "" {
+		fmt.Print("Password (a new name is registered with it): ")
+		if !scanner.Scan() {
+			os.Exit(1)
+		}
+		password := scanner.Text()
+		if err := c.findLeader(func() error { return c.login(password, true) }); err != nil {
+			fmt.Printf("Login failed: %v\n", status.Convert(err).Message())
+			os.Exit(1)
+		}
+	} else {
+		log.Printf("[Client %s] Logging in with a certificate", c.name)
+	}
+	if err := c.findLeader(func() error { return c.join(since) }); err != nil {
+		fmt.Printf("Could not join: %v\n", status.Convert(err).Message())
+		os.Exit(1)
+	}
+
+	c.startPresence()
+	fmt.Printf("Client %s joined succesfully (type /leave to leave, /help for more)\n", c.name)
+
 	for scanner.Scan() {
 		input := strings.TrimSpace(scanner.Text())
 		if input == "/leave" {
 			break
 		}
+		if !c.isConnected() {
+			fmt.Println("Not connected, still trying to reconnect...")
+			continue
+		}
+		if strings.HasPrefix(input, "/") {
+			if err := c.command(input); err != nil {
+				fmt.Printf("Error: %v\n", err)
+			}
+			continue
+		}
 		if err := c.sendMessage(input); err != nil {
-			fmt.Printf("Error: %e\n", err)
+			fmt.Printf("Error: %v\n", err)
 		}
 	}
 
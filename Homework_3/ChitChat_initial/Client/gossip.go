@@ -0,0 +1,425 @@
+package main
+
+import (
+	proto "ITUserver/grpc"
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"maps"
+	mrand "math/rand/v2"
+	"net"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// Serverless mode: every participant runs a node and there is no server at all.
+// A new message is pushed to a few random peers, and every node that gets it for the
+// first time pushes it on the same way, so it spreads through the group like an epidemic.
+// Pushes get lost when nodes are down, so every node also asks a random peer now and then
+// for the messages it is missing (anti-entropy). Messages wait a moment before they are
+// shown, and are shown in the order of their Lamport timestamps.
+
+const (
+	gossipFanout    = 3 // how many peers a new message is pushed to
+	syncInterval    = time.Second
+	displayDelay    = 500 * time.Millisecond // how long a message waits for earlier ones
+	maxSyncMessages = 200                    // per sync response, the rest comes with the next
+	maxPeerFailures = 3                      // a peer that fails this often in a row is forgotten
+)
+
+type gossipNode struct {
+	proto.UnimplementedGossipServer
+
+	name   string
+	addr   string // where the other nodes reach us
+	origin string // our messages are known by it and their sequence number
+
+	mu       sync.Mutex
+	lamport  int64
+	seq      uint64
+	peers    map[string]*gossipPeer
+	messages map[string]map[uint64]*proto.BroadcastMessage // origin -> sequence number -> message
+	have     map[string]uint64                             // origin -> we have every message up to here
+	waiting  []arrival                                     // not shown yet
+	shown    int64                                         // Lamport time of the last message shown
+}
+
+type gossipPeer struct {
+	conn      *grpc.ClientConn
+	client    proto.GossipClient
+	failures  int
+	bootstrap bool // given on the command line, never forgotten
+}
+
+type arrival struct {
+	msg *proto.BroadcastMessage
+	at  time.Time
+}
+
+func newGossipNode(name, addr string, bootstrap []string) *gossipNode {
+	// a node that restarts starts counting its messages at 1 again, so it needs a new origin
+	incarnation := make([]byte, 4)
+	rand.Read(incarnation)
+
+	n := &gossipNode{
+		name:     name,
+		addr:     addr,
+		origin:   name + "/" + hex.EncodeToString(incarnation),
+		peers:    make(map[string]*gossipPeer),
+		messages: make(map[string]map[uint64]*proto.BroadcastMessage),
+		have:     make(map[string]uint64),
+	}
+	n.mu.Lock()
+	for _, addr := range bootstrap {
+		n.addPeerLocked(strings.TrimSpace(addr))
+		if p, ok := n.peers[strings.TrimSpace(addr)]; ok {
+			p.bootstrap = true
+		}
+	}
+	n.mu.Unlock()
+	return n
+}
+
+// addPeerLocked starts talking to a node we did not know (n.mu must be held)
+func (n *gossipNode) addPeerLocked(addr string) {
+	if addr == "" || addr == n.addr {
+		return
+	}
+	if _, known := n.peers[addr]; known {
+		return
+	}
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		log.Printf("[Node %s] Can't use peer %s: %v", n.name, addr, err)
+		return
+	}
+	n.peers[addr] = &gossipPeer{conn: conn, client: proto.NewGossipClient(conn)}
+	log.Printf("[Node %s] New peer %s", n.name, addr)
+}
+
+// peerFailed forgets a peer that keeps failing, it is added again when we hear from it
+func (n *gossipNode) peerFailed(addr string, err error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	p, ok := n.peers[addr]
+	if !ok {
+		return
+	}
+	p.failures++
+	log.Printf("[Node %s] Peer %s failed (%d in a row): %v", n.name, addr, p.failures, err)
+	if p.failures >= maxPeerFailures && !p.bootstrap {
+		p.conn.Close()
+		delete(n.peers, addr)
+		log.Printf("[Node %s] Forgot peer %s", n.name, addr)
+	}
+}
+
+func (n *gossipNode) peerWorked(addr string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if p, ok := n.peers[addr]; ok {
+		p.failures = 0
+	}
+}
+
+func (n *gossipNode) peerList() []string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return slices.Sorted(maps.Keys(n.peers))
+}
+
+// randomPeers picks up to k of the known peers
+func (n *gossipNode) randomPeers(k int) map[string]proto.GossipClient {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	addrs := slices.Collect(maps.Keys(n.peers))
+	mrand.Shuffle(len(addrs), func(i, j int) { addrs[i], addrs[j] = addrs[j], addrs[i] })
+	picked := make(map[string]proto.GossipClient)
+	for _, addr := range addrs[:min(k, len(addrs))] {
+		picked[addr] = n.peers[addr].client
+	}
+	return picked
+}
+
+// storeLocked keeps a message and queues it to be shown. It returns false if we had it already (n.mu must be held).
+func (n *gossipNode) storeLocked(msg *proto.BroadcastMessage) bool {
+	seqs, ok := n.messages[msg.Origin]
+	if !ok {
+		seqs = make(map[uint64]*proto.BroadcastMessage)
+		n.messages[msg.Origin] = seqs
+	}
+	if _, dup := seqs[msg.OriginSeq]; dup {
+		return false
+	}
+	seqs[msg.OriginSeq] = msg
+	for seqs[n.have[msg.Origin]+1] != nil {
+		n.have[msg.Origin]++
+	}
+	// the Lamport receive rule
+	n.lamport = max(n.lamport, msg.LamportTimestamp) + 1
+	n.waiting = append(n.waiting, arrival{msg: msg, at: time.Now()})
+	return true
+}
+
+// receive stores messages from a peer and returns the new ones
+func (n *gossipNode) receive(msgs []*proto.BroadcastMessage) []*proto.BroadcastMessage {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	var fresh []*proto.BroadcastMessage
+	for _, msg := range msgs {
+		if msg.Origin == "" || msg.OriginSeq == 0 {
+			continue
+		}
+		if n.storeLocked(msg) {
+			fresh = append(fresh, msg)
+		}
+	}
+	return fresh
+}
+
+// newMessageLocked ticks our clock and turns a chat message of ours into a broadcast message (n.mu must be held)
+func (n *gossipNode) newMessageLocked(chat *proto.ChatMessage, msgType proto.MessageType) *proto.BroadcastMessage {
+	n.lamport++
+	n.seq++
+	chat.Lamport = n.lamport
+	msg := &proto.BroadcastMessage{
+		Content:          chat.Content,
+		LamportTimestamp: chat.Lamport,
+		Type:             msgType,
+		Room:             generalRoom,
+		Sender:           chat.ParticipantName,
+		Origin:           n.origin,
+		OriginSeq:        n.seq,
+	}
+	if msgType == proto.MessageType_CHAT {
+		msg.Content = fmt.Sprintf("%s: %s", chat.ParticipantName, chat.Content)
+	}
+	seqs, ok := n.messages[n.origin]
+	if !ok {
+		seqs = make(map[uint64]*proto.BroadcastMessage)
+		n.messages[n.origin] = seqs
+	}
+	seqs[msg.OriginSeq] = msg
+	n.have[n.origin] = msg.OriginSeq
+	n.waiting = append(n.waiting, arrival{msg: msg, at: time.Now()})
+	return msg
+}
+
+// publish sends a chat message and waits until the first peers have it
+func (n *gossipNode) publish(chat *proto.ChatMessage) {
+	n.mu.Lock()
+	msg := n.newMessageLocked(chat, proto.MessageType_CHAT)
+	n.mu.Unlock()
+	n.send(msg)
+}
+
+// announce publishes a join or leave, the content gets the Lamport time it is sent at
+func (n *gossipNode) announce(msgType proto.MessageType, content func(lamport int64) string) {
+	n.mu.Lock()
+	msg := n.newMessageLocked(&proto.ChatMessage{ParticipantName: n.name, Content: content(n.lamport + 1)}, msgType)
+	n.mu.Unlock()
+	n.send(msg)
+}
+
+func (n *gossipNode) send(msg *proto.BroadcastMessage) {
+	log.Printf("[Node %s] Published %s (Lamport: %d, seq: %d)", n.name, msg.Content, msg.LamportTimestamp, msg.OriginSeq)
+	n.spread([]*proto.BroadcastMessage{msg}).Wait()
+}
+
+// spread pushes messages to a few random peers. The returned group is done when they answered.
+func (n *gossipNode) spread(msgs []*proto.BroadcastMessage) *sync.WaitGroup {
+	var wg sync.WaitGroup
+	if len(msgs) == 0 {
+		return &wg
+	}
+	req := &proto.GossipRequest{From: n.addr, Messages: msgs, Peers: n.peerList()}
+	for addr, client := range n.randomPeers(gossipFanout) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+			resp, err := client.Push(ctx, req)
+			if err != nil {
+				n.peerFailed(addr, err)
+				return
+			}
+			n.peerWorked(addr)
+			n.learnPeers(resp.Peers)
+		}()
+	}
+	return &wg
+}
+
+func (n *gossipNode) learnPeers(addrs []string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for _, addr := range addrs {
+		n.addPeerLocked(addr)
+	}
+}
+
+// Push takes messages from a peer. The ones that are new to us are pushed on.
+func (n *gossipNode) Push(ctx context.Context, req *proto.GossipRequest) (*proto.GossipResponse, error) {
+	n.learnPeers(append(req.Peers, req.From))
+	fresh := n.receive(req.Messages)
+	if len(fresh) > 0 {
+		log.Printf("[Node %s] Got %d new messages from %s", n.name, len(fresh), req.From)
+		n.spread(fresh)
+	}
+	return &proto.GossipResponse{Peers: n.peerList()}, nil
+}
+
+// Sync sends a peer the messages it does not have, oldest first
+func (n *gossipNode) Sync(ctx context.Context, req *proto.SyncRequest) (*proto.SyncResponse, error) {
+	n.learnPeers([]string{req.From})
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	var missing []*proto.BroadcastMessage
+	for origin, seqs := range n.messages {
+		for seq, msg := range seqs {
+			if seq > req.Have[origin] {
+				missing = append(missing, msg)
+			}
+		}
+	}
+	sort.Slice(missing, func(i, j int) bool { return missing[i].LamportTimestamp < missing[j].LamportTimestamp })
+	return &proto.SyncResponse{Messages: missing[:min(len(missing), maxSyncMessages)]}, nil
+}
+
+// antiEntropy asks a random peer for what we missed, every syncInterval
+func (n *gossipNode) antiEntropy(ctx context.Context) {
+	ticker := time.NewTicker(syncInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			n.sync(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (n *gossipNode) sync(ctx context.Context) {
+	n.mu.Lock()
+	req := &proto.SyncRequest{From: n.addr, Have: maps.Clone(n.have)}
+	n.mu.Unlock()
+
+	for addr, client := range n.randomPeers(1) {
+		callCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+		resp, err := client.Sync(callCtx, req)
+		cancel()
+		if err != nil {
+			n.peerFailed(addr, err)
+			continue
+		}
+		n.peerWorked(addr)
+		if fresh := n.receive(resp.Messages); len(fresh) > 0 {
+			log.Printf("[Node %s] Anti-entropy with %s filled in %d messages", n.name, addr, len(fresh))
+		}
+	}
+}
+
+// display shows the messages that waited long enough, in Lamport order (ties by origin)
+func (n *gossipNode) display(ctx context.Context) {
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+		n.mu.Lock()
+		sort.SliceStable(n.waiting, func(i, j int) bool {
+			a, b := n.waiting[i].msg, n.waiting[j].msg
+			if a.LamportTimestamp != b.LamportTimestamp {
+				return a.LamportTimestamp < b.LamportTimestamp
+			}
+			return a.Origin < b.Origin
+		})
+		shown := 0
+		for _, w := range n.waiting {
+			if time.Since(w.at) < displayDelay {
+				// an earlier message may still be on its way
+				break
+			}
+			note := ""
+			if w.msg.LamportTimestamp < n.shown {
+				// came in after later messages were shown, e.g. through anti-entropy
+				note = " (late)"
+			}
+			n.shown = max(n.shown, w.msg.LamportTimestamp)
+			fmt.Printf("[%s Lamport: %d] %s%s \n", generalRoom, w.msg.LamportTimestamp, w.msg.Content, note)
+			log.Printf("[Node %s] Recieved: %s (Lamport: %d, from %s seq %d)%s", n.name, w.msg.Content, w.msg.LamportTimestamp, w.msg.Origin, w.msg.OriginSeq, note)
+			shown++
+		}
+		n.waiting = n.waiting[shown:]
+		n.mu.Unlock()
+	}
+}
+
+// runGossip is the chat without a server: it listens on addr for the other nodes,
+// knows the ones in bootstrap to start with and reads the messages from input
+func runGossip(name, addr string, bootstrap []string, input *bufio.Scanner) error {
+	if err := openLog(name); err != nil {
+		return err
+	}
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	n := newGossipNode(name, addr, bootstrap)
+	grpcServer := grpc.NewServer()
+	proto.RegisterGossipServer(grpcServer, n)
+	go grpcServer.Serve(lis)
+	defer grpcServer.Stop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go n.antiEntropy(ctx)
+	go n.display(ctx)
+
+	log.Printf("[Node %s] Listening on %s as %s, peers: %v", name, addr, n.origin, n.peerList())
+	// catch up first, so our join comes after what was said so far
+	n.sync(ctx)
+	n.announce(proto.MessageType_JOIN, func(lamport int64) string {
+		return fmt.Sprintf("Participant %s joined Chit Chat at Lamport time %d", name, lamport)
+	})
+	fmt.Printf("Node %s is listening on %s (type /peers to see the other nodes, /leave to leave)\n", name, addr)
+
+	for input.Scan() {
+		text := strings.TrimSpace(input.Text())
+		switch {
+		case text == "/leave":
+			n.announce(proto.MessageType_LEAVE, func(lamport int64) string {
+				return fmt.Sprintf("Participant %s left Chit Chat at Lamport time %d", name, lamport)
+			})
+			// show what is still waiting
+			time.Sleep(displayDelay + 100*time.Millisecond)
+			return nil
+		case text == "/peers":
+			fmt.Printf("Peers: %s\n", strings.Join(n.peerList(), ", "))
+		case strings.HasPrefix(text, "/"):
+			fmt.Println("Error: only /peers and /leave work without a server")
+		case len(text) == 0:
+			fmt.Println("Error: Message is empty")
+		case len(text) > 128:
+			fmt.Println("Error: Message too long - Max is 128 characters")
+		default:
+			n.publish(&proto.ChatMessage{ParticipantName: name, Content: text})
+		}
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	proto "ITUserver/grpc"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// moderate runs /kick, /mute, /unmute, /ban or /unban, the server checks that we are an admin
+func (c *userInfo) moderate(fields []string) error {
+	action := strings.ToUpper(strings.TrimPrefix(fields[0], "/"))
+	if len(fields) < 2 {
+		return fmt.Errorf("usage: %s name", fields[0])
+	}
+	req := &proto.ModerationRequest{
+		ParticipantName: c.name,
+		Action:          proto.ModerationAction(proto.ModerationAction_value[action]),
+		Target:          fields[1],
+	}
+	rest := fields[2:]
+	if req.Action == proto.ModerationAction_MUTE {
+		if len(rest) == 0 {
+			return fmt.Errorf("usage: /mute name duration [reason], e.g. /mute Bob 5m spamming")
+		}
+		duration, err := time.ParseDuration(rest[0])
+		if err != nil || duration < time.Second {
+			return fmt.Errorf("%q is not a duration like 30s or 5m", rest[0])
+		}
+		req.DurationSeconds = int64(duration / time.Second)
+		rest = rest[1:]
+	}
+	req.Reason = strings.Join(rest, " ")
+
+	if _, err := c.api().Moderate(c.ctx, req); err != nil {
+		return err
+	}
+	fmt.Printf("%s %s: done\n", strings.ToLower(action), req.Target)
+	return nil
+}
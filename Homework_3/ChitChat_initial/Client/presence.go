@@ -0,0 +1,115 @@
+package main
+
+import (
+	proto "ITUserver/grpc"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// The server counts us as idle when it did not get a heartbeat for a while
+const heartbeatInterval = 5 * time.Second
+
+// startPresence sends the heartbeats and shows what the server tells about the others
+func (c *userInfo) startPresence() {
+	go c.heartbeats()
+	go c.watchPresence()
+}
+
+func (c *userInfo) heartbeats() {
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if c.isConnected() {
+				c.sendHeartbeat()
+			}
+		case <-c.ctx.Done():
+			return
+		}
+	}
+}
+
+func (c *userInfo) sendHeartbeat() {
+	c.mu.Lock()
+	req := &proto.HeartbeatRequest{ParticipantName: c.name, Typing: c.typing, Room: c.room}
+	c.mu.Unlock()
+	if _, err := c.api().Heartbeat(c.ctx, req); err != nil {
+		log.Printf("[Client %s] Heartbeat failed: %v", c.name, err)
+	}
+}
+
+// setTyping tells the others right away that we are typing, or stopped
+func (c *userInfo) setTyping(typing bool) {
+	c.mu.Lock()
+	c.typing = typing
+	c.mu.Unlock()
+	c.sendHeartbeat()
+}
+
+// watchPresence follows the presence stream, and opens it again when it breaks
+func (c *userInfo) watchPresence() {
+	for !c.isLeaving() {
+		stream, err := c.api().Presence(c.ctx, &proto.PresenceRequest{ParticipantName: c.name})
+		for err == nil {
+			var ev *proto.PresenceEvent
+			if ev, err = stream.Recv(); err == nil {
+				c.showPresence(ev)
+			}
+		}
+		log.Printf("[Client %s] Presence stream ended: %v", c.name, err)
+		select {
+		case <-time.After(minBackoff):
+		case <-c.ctx.Done():
+			return
+		}
+	}
+}
+
+// showPresence prints a change of someone else's presence. Joining and leaving are
+// already in the chat, so only idle, back and typing are shown.
+func (c *userInfo) showPresence(ev *proto.PresenceEvent) {
+	if ev.ParticipantName == c.name {
+		return
+	}
+	c.mu.Lock()
+	old, known := c.presence[ev.ParticipantName]
+	if ev.Status == proto.PresenceStatus_OFFLINE {
+		delete(c.presence, ev.ParticipantName)
+	} else {
+		c.presence[ev.ParticipantName] = ev
+	}
+	c.mu.Unlock()
+
+	switch {
+	case ev.Status == proto.PresenceStatus_IDLE && (!known || old.Status != proto.PresenceStatus_IDLE):
+		fmt.Printf("[presence] %s is idle\n", ev.ParticipantName)
+	case ev.Status == proto.PresenceStatus_ONLINE && known && old.Status == proto.PresenceStatus_IDLE:
+		fmt.Printf("[presence] %s is back\n", ev.ParticipantName)
+	}
+	if ev.Typing && (!known || !old.Typing || old.Room != ev.Room) {
+		fmt.Printf("[presence] %s is typing in %s...\n", ev.ParticipantName, ev.Room)
+	}
+	log.Printf("[Client %s] Presence of %s: %v (typing: %v)", c.name, ev.ParticipantName, ev.Status, ev.Typing)
+}
+
+// who lists the participants that are there
+func (c *userInfo) who() error {
+	resp, err := c.api().ListParticipants(c.ctx, &proto.ListParticipantsRequest{})
+	if err != nil {
+		return err
+	}
+	for _, p := range resp.Participants {
+		notes := ""
+		if p.Typing {
+			notes += " (typing)"
+		}
+		if p.Name == c.name {
+			notes += " (you)"
+		}
+		fmt.Printf("  %-16s %-7s %s%s\n", p.Name, strings.ToLower(p.Status.String()), strings.Join(p.Rooms, ", "), notes)
+	}
+	return nil
+}
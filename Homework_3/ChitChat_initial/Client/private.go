@@ -0,0 +1,39 @@
+package main
+
+import (
+	proto "ITUserver/grpc"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// Private messages are not in a room, they have a clock of their own
+const privateClock = "private"
+
+// sendPrivate sends "/msg <name> <text>" to one participant and waits until the server delivered it
+func (c *userInfo) sendPrivate(input string) error {
+	parts := strings.SplitN(input, " ", 3)
+	if len(parts) < 3 || strings.TrimSpace(parts[2]) == "" {
+		return fmt.Errorf("usage: /msg <name> <text>")
+	}
+	recipient, text := parts[1], strings.TrimSpace(parts[2])
+	if len(text) > 128 {
+		return fmt.Errorf("Message too long - Max is 128 characters")
+	}
+
+	lamportTime := c.incrementClock(privateClock)
+	resp, err := c.api().SendPrivate(c.ctx, &proto.PrivateMessage{
+		ParticipantName: c.name,
+		Recipient:       recipient,
+		Content:         text,
+		Lamport:         lamportTime,
+	})
+	if err != nil {
+		return err
+	}
+
+	c.updateClock(privateClock, resp.LamportTimestamp)
+	fmt.Printf("[private Lamport: %d] delivered to %s\n", resp.LamportTimestamp, resp.Recipient)
+	log.Printf("[Client %s] Private message to %s delivered (Lamport: %d)", c.name, resp.Recipient, resp.LamportTimestamp)
+	return nil
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	proto "ITUserver/grpc"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Waiting time before the first reconnect attempt, it doubles after every failed one
+const (
+	minBackoff = 500 * time.Millisecond
+	maxBackoff = 10 * time.Second
+)
+
+// The header of the JoinChat stream tells if the server resumed our session
+const resumedKey = "resumed"
+
+// joinChat starts JoinChat and waits until the server accepted or refused it
+func (c *userInfo) joinChat(req *proto.JoinRequest) (proto.ITUDatabase_JoinChatClient, bool, error) {
+	stream, err := c.api().JoinChat(c.ctx, req)
+	if err != nil {
+		return nil, false, err
+	}
+	header, err := stream.Header()
+	if err != nil {
+		return nil, false, err
+	}
+	resumed := header.Get(resumedKey)
+	if len(resumed) == 0 {
+		// refused streams end without our header, the reason is in the status
+		if _, err := stream.Recv(); err != nil {
+			return nil, false, err
+		}
+		return nil, false, fmt.Errorf("the server did not accept the join")
+	}
+	return stream, resumed[0] == "true", nil
+}
+
+// reconnect tries to resume the session until it works or we leave (then it returns nil).
+// The server sends every message after the last one we got.
+func (c *userInfo) reconnect() messageStream {
+	backoff := minBackoff
+	for attempt := 1; ; attempt++ {
+		// a bit of jitter, so clients that lost the same server do not all come back at once
+		wait := backoff/2 + rand.N(backoff/2+1)
+		select {
+		case <-time.After(wait):
+		case <-c.ctx.Done():
+			return nil
+		}
+
+		stream, resumed, err := c.resume()
+		if status.Code(err) == codes.Unavailable && len(c.servers) > 1 {
+			// the server is gone or no longer the leader, try the next replica
+			c.nextServer()
+			stream, resumed, err = c.resume()
+		}
+		if status.Code(err) == codes.Unauthenticated {
+			// the server does not know our session anymore, it probably restarted
+			if err = c.login(c.password, false); err == nil {
+				stream, resumed, err = c.resume()
+			}
+		}
+		if err == nil {
+			log.Printf("[Client %s] Reconnected after %d attempts (resumed: %v)", c.name, attempt, resumed)
+			if resumed {
+				fmt.Printf("Reconnected to %s\n", c.currentServer())
+			} else {
+				// the server ended our session, we are only in #general again
+				fmt.Printf("Reconnected to %s with a new session, your messages go to %s\n", c.currentServer(), generalRoom)
+				c.switchRoom(generalRoom)
+			}
+			return stream
+		}
+		log.Printf("[Client %s] Reconnect attempt %d failed: %v", c.name, attempt, err)
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
+
+func (c *userInfo) resume() (messageStream, bool, error) {
+	c.mu.Lock()
+	since := c.lastSeq
+	c.mu.Unlock()
+	return c.openStream(&proto.JoinRequest{
+		ParticipantName: c.name,
+		SinceSeq:        &since,
+		Resume:          true,
+		SlowPolicy:      c.slowPolicy,
+	})
+}
+
+// seen is true for a message we already got, and remembers the sequence number of a new one
+func (c *userInfo) seen(msg *proto.BroadcastMessage) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if msg.Seq == 0 {
+		return false
+	}
+	if msg.Seq <= c.lastSeq {
+		return true
+	}
+	c.lastSeq = msg.Seq
+	return false
+}
+
+func (c *userInfo) setConnected(connected bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.connected = connected
+}
+
+func (c *userInfo) isLeaving() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.leaving
+}
+
+func (c *userInfo) isConnected() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.connected
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	proto "ITUserver/grpc"
+	"log"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// A new leader is usually elected within a second, this is how often we go
+// round the replicas before giving up
+const leaderRounds = 5
+
+// dial connects to a server, the session token goes along with every call
+func (c *userInfo) dial(addr string) error {
+	conn, err := grpc.NewClient(addr,
+		grpc.WithTransportCredentials(c.creds),
+		grpc.WithPerRPCCredentials(c.session),
+	)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn != nil {
+		c.conn.Close()
+	}
+	c.conn = conn
+	c.client = proto.NewITUDatabaseClient(conn)
+	return nil
+}
+
+// api is the server we use right now
+func (c *userInfo) api() proto.ITUDatabaseClient {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.client
+}
+
+func (c *userInfo) currentServer() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.servers[c.server]
+}
+
+// nextServer moves on to the next replica
+func (c *userInfo) nextServer() {
+	c.mu.Lock()
+	c.server = (c.server + 1) % len(c.servers)
+	addr := c.servers[c.server]
+	c.mu.Unlock()
+
+	log.Printf("[Client %s] Trying replica %s", c.name, addr)
+	if err := c.dial(addr); err != nil {
+		log.Printf("[Client %s] Can't use %s: %v", c.name, addr, err)
+	}
+}
+
+// findLeader calls f until a replica takes it. Only the leader does, the others are Unavailable.
+func (c *userInfo) findLeader(f func() error) error {
+	err := f()
+	for tries := 1; status.Code(err) == codes.Unavailable && tries < leaderRounds*len(c.servers); tries++ {
+		if len(c.servers) == 1 {
+			return err
+		}
+		if tries%len(c.servers) == 0 {
+			// went round all of them, maybe they are still electing a leader
+			time.Sleep(minBackoff)
+		}
+		c.nextServer()
+		err = f()
+	}
+	return err
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	proto "ITUserver/grpc"
+	"fmt"
+	"log"
+)
+
+// Everybody is in this room, it can't be left
+const generalRoom = "#general"
+
+const commandHelp = `Commands:
+  /rooms          list the rooms
+  /create #room   create a room and join it
+  /join #room     join a room, your messages go there from now on
+  /leave #room    leave a room
+  /msg name text  send a message only to name
+  /who            list who is there, and who is idle or typing
+  /typing         show the others that you are typing, until you send your message
+  /kick name [reason], /mute name duration [reason], /unmute name,
+  /ban name [reason], /unban name
+                  for admins only, duration is like 30s or 5m
+  /leave          leave the chat`
+
+func (c *userInfo) currentRoom() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.room
+}
+
+func (c *userInfo) switchRoom(room string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.room = room
+}
+
+func (c *userInfo) listRooms() error {
+	resp, err := c.api().ListRooms(c.ctx, &proto.ListRoomsRequest{})
+	if err != nil {
+		return err
+	}
+	for _, r := range resp.Rooms {
+		marker := " "
+		if r.Name == c.currentRoom() {
+			marker = "*"
+		}
+		fmt.Printf("%s %-20s %d participants (Lamport: %d)\n", marker, r.Name, r.Members, r.LamportTimestamp)
+	}
+	return nil
+}
+
+func (c *userInfo) createRoom(room string) error {
+	if room == "" {
+		return fmt.Errorf("usage: /create #room")
+	}
+	resp, err := c.api().CreateRoom(c.ctx, &proto.RoomRequest{ParticipantName: c.name, Room: room})
+	if err != nil {
+		return err
+	}
+	log.Printf("[Client %s] Created room %s", c.name, resp.Room)
+	return c.joinRoom(resp.Room)
+}
+
+func (c *userInfo) joinRoom(room string) error {
+	if room == "" {
+		return fmt.Errorf("usage: /join #room")
+	}
+	resp, err := c.api().JoinRoom(c.ctx, &proto.RoomRequest{ParticipantName: c.name, Room: room})
+	if err != nil {
+		return err
+	}
+	c.switchRoom(resp.Room)
+	log.Printf("[Client %s] Joined room %s (Lamport: %d)", c.name, resp.Room, resp.LamportTimestamp)
+	fmt.Printf("Your messages now go to %s\n", resp.Room)
+	return nil
+}
+
+func (c *userInfo) leaveRoom(room string) error {
+	resp, err := c.api().LeaveRoom(c.ctx, &proto.RoomRequest{ParticipantName: c.name, Room: room})
+	if err != nil {
+		return err
+	}
+	log.Printf("[Client %s] Left room %s (Lamport: %d)", c.name, resp.Room, resp.LamportTimestamp)
+	if c.currentRoom() == resp.Room {
+		c.switchRoom(generalRoom)
+		fmt.Printf("Your messages now go to %s\n", generalRoom)
+	}
+	return nil
+}
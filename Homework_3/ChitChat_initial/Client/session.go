@@ -0,0 +1,184 @@
+package main
+
+import (
+	proto "ITUserver/grpc"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var errSessionEnded = errors.New("the session stream ended")
+
+// messageStream is where the messages of the server come from: a JoinChat stream or a session
+type messageStream interface {
+	Recv() (*proto.BroadcastMessage, error)
+}
+
+// session is a Session stream. Recv acks the messages it returns to the server,
+// and hands the acks of the server to the frames that wait for them.
+type session struct {
+	stream proto.ITUDatabase_SessionClient
+	sendMu sync.Mutex // one Send at a time
+
+	mu      sync.Mutex
+	nextID  uint64
+	waiting map[uint64]chan *proto.SessionAck
+	ended   bool
+}
+
+// openSession starts a Session stream with a join frame and waits until the server accepted or refused it
+func (c *userInfo) openSession(req *proto.JoinRequest) (*session, bool, error) {
+	stream, err := c.api().Session(c.ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	sess := &session{stream: stream, nextID: 1, waiting: make(map[uint64]chan *proto.SessionAck)}
+	if err := sess.send(&proto.SessionFrame{Id: 1, Frame: &proto.SessionFrame_Join{Join: req}}); err != nil {
+		return nil, false, err
+	}
+	// a refused join ends the stream, the reason is in the status
+	frame, err := stream.Recv()
+	if err != nil {
+		return nil, false, err
+	}
+	ack := frame.GetAck()
+	if ack == nil || ack.Id != 1 {
+		return nil, false, fmt.Errorf("the server did not answer the join")
+	}
+	if !ack.Success {
+		return nil, false, fmt.Errorf("join refused, %s", ack.Reason)
+	}
+	return sess, ack.Resumed, nil
+}
+
+func (s *session) send(frame *proto.SessionFrame) error {
+	s.sendMu.Lock()
+	defer s.sendMu.Unlock()
+	return s.stream.Send(frame)
+}
+
+func (s *session) Recv() (*proto.BroadcastMessage, error) {
+	for {
+		frame, err := s.stream.Recv()
+		if err != nil {
+			s.end()
+			return nil, err
+		}
+		switch f := frame.Frame.(type) {
+		case *proto.SessionFrame_Message:
+			if f.Message.Seq != 0 {
+				// a resumed session continues after the last message we acked
+				s.send(&proto.SessionFrame{Frame: &proto.SessionFrame_Ack{Ack: &proto.SessionAck{Seq: f.Message.Seq}}})
+			}
+			return f.Message, nil
+		case *proto.SessionFrame_Ack:
+			s.mu.Lock()
+			waiting := s.waiting[f.Ack.Id]
+			delete(s.waiting, f.Ack.Id)
+			s.mu.Unlock()
+			if waiting != nil {
+				waiting <- f.Ack
+			}
+		}
+	}
+}
+
+// end lets the frames that still wait for their ack know they will not get it
+func (s *session) end() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ended = true
+	for id, waiting := range s.waiting {
+		close(waiting)
+		delete(s.waiting, id)
+	}
+}
+
+// request sends a frame and waits for its ack. Somebody has to call Recv meanwhile.
+func (s *session) request(frame *proto.SessionFrame) (*proto.SessionAck, error) {
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return nil, errSessionEnded
+	}
+	s.nextID++
+	frame.Id = s.nextID
+	waiting := make(chan *proto.SessionAck, 1)
+	s.waiting[frame.Id] = waiting
+	s.mu.Unlock()
+
+	if err := s.send(frame); err != nil {
+		s.mu.Lock()
+		delete(s.waiting, frame.Id)
+		s.mu.Unlock()
+		return nil, err
+	}
+	ack, ok := <-waiting
+	if !ok {
+		return nil, errSessionEnded
+	}
+	return ack, nil
+}
+
+// openStream joins on a session, or with JoinChat if we don't use sessions or the server has none
+func (c *userInfo) openStream(req *proto.JoinRequest) (messageStream, bool, error) {
+	c.mu.Lock()
+	useSession := c.useSession
+	c.mu.Unlock()
+	if useSession {
+		sess, resumed, err := c.openSession(req)
+		if err == nil {
+			c.mu.Lock()
+			c.sess = sess
+			c.mu.Unlock()
+			return sess, resumed, nil
+		}
+		if status.Code(err) != codes.Unimplemented {
+			return nil, false, err
+		}
+		log.Printf("[Client %s] The server has no sessions, using JoinChat", c.name)
+		c.mu.Lock()
+		c.useSession = false
+		c.mu.Unlock()
+	}
+	return c.joinChat(req)
+}
+
+func (c *userInfo) currentSession() *session {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.useSession {
+		return nil
+	}
+	return c.sess
+}
+
+// publish sends a chat message on the session, or with PublishMessage without one
+func (c *userInfo) publish(msg *proto.ChatMessage) (*proto.PublishResponse, error) {
+	sess := c.currentSession()
+	if sess == nil {
+		return c.api().PublishMessage(c.ctx, msg)
+	}
+	ack, err := sess.request(&proto.SessionFrame{Frame: &proto.SessionFrame_Chat{Chat: msg}})
+	if err != nil {
+		return nil, err
+	}
+	return &proto.PublishResponse{Success: ack.Success, LamportTimestamp: ack.LamportTimestamp, Reason: ack.Reason}, nil
+}
+
+// sendLeave leaves on the session, or with LeaveChat without one (or when the session is broken)
+func (c *userInfo) sendLeave() {
+	leave := &proto.LeaveRequest{ParticipantName: c.name}
+	if sess := c.currentSession(); sess != nil {
+		_, err := sess.request(&proto.SessionFrame{Frame: &proto.SessionFrame_Leave{Leave: leave}})
+		sess.stream.CloseSend()
+		if err != errSessionEnded {
+			return
+		}
+	}
+	c.api().LeaveChat(c.ctx, leave)
+}
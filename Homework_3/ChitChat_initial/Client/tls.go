@@ -0,0 +1,43 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// transportCredentials is TLS if we have a CA to check the server with or a certificate of our own,
+// otherwise the connection is not encrypted. certName is who our certificate is for ("" without one).
+func transportCredentials(caFile, certFile, keyFile string) (creds credentials.TransportCredentials, certName string, err error) {
+	if caFile == "" && certFile == "" && keyFile == "" {
+		return insecure.NewCredentials(), "", nil
+	}
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if caFile != "" {
+		data, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, "", err
+		}
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(data) {
+			return nil, "", fmt.Errorf("no certificates in %s", caFile)
+		}
+	}
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, "", err
+		}
+		leaf, err := x509.ParseCertificate(cert.Certificate[0])
+		if err != nil {
+			return nil, "", err
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+		certName = leaf.Subject.CommonName
+	}
+	return credentials.NewTLS(cfg), certName, nil
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	proto "ITUserver/grpc"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// Vector clocks: in every room a participant counts the chat messages it sent, and a message
+// carries the counts of everything its sender had seen. A message is only shown after all the
+// messages its sender had seen (causal delivery). When neither of two messages was seen by
+// the sender of the other one they are concurrent, and their order on the screen means nothing.
+
+// How many of the last shown messages a new one is compared with to find concurrent ones
+const concurrencyWindow = 10
+
+type vectorClock map[string]int64
+
+// leq is true if every entry of v is at most the one in w: v happened before w (or is w)
+func (v vectorClock) leq(w vectorClock) bool {
+	for name, n := range v {
+		if n > w[name] {
+			return false
+		}
+	}
+	return true
+}
+
+func concurrent(v, w vectorClock) bool {
+	return !v.leq(w) && !w.leq(v)
+}
+
+func (v vectorClock) merge(w vectorClock) {
+	for name, n := range w {
+		v[name] = max(v[name], n)
+	}
+}
+
+func (v vectorClock) String() string {
+	var entries []string
+	for _, name := range slices.Sorted(maps.Keys(v)) {
+		entries = append(entries, fmt.Sprintf("%s:%d", name, v[name]))
+	}
+	return "{" + strings.Join(entries, " ") + "}"
+}
+
+// causalRoom is what a client knows about the chat messages of one room
+type causalRoom struct {
+	delivered vectorClock               // what has been shown of every sender
+	pending   []*proto.BroadcastMessage // messages that wait for one they depend on
+	recent    []*proto.BroadcastMessage // the last chat messages shown
+}
+
+// delivery is a message that can be shown now, with the shown messages it is concurrent with
+type delivery struct {
+	msg        *proto.BroadcastMessage
+	concurrent []string
+}
+
+// causalRoomLocked returns the state of a room (c.mu must be held)
+func (c *userInfo) causalRoomLocked(room string) *causalRoom {
+	cr, ok := c.causal[room]
+	if !ok {
+		cr = &causalRoom{delivered: make(vectorClock)}
+		c.causal[room] = cr
+	}
+	return cr
+}
+
+// nextVector is the vector timestamp of a message we are about to send to a room
+func (c *userInfo) nextVector(room string) vectorClock {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	v := maps.Clone(c.causalRoomLocked(room).delivered)
+	v[c.name]++
+	return v
+}
+
+// sent counts a message the server accepted
+func (c *userInfo) sent(room string, v vectorClock) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cr := c.causalRoomLocked(room)
+	cr.delivered[c.name] = max(cr.delivered[c.name], v[c.name])
+}
+
+// replayed counts a message from the history, it is older than everything live
+func (c *userInfo) replayed(room string, msg *proto.BroadcastMessage) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.causalRoomLocked(room).delivered.merge(msg.VectorClock)
+}
+
+// receiveCausal takes a live message and returns the messages that can be shown now, in order
+func (c *userInfo) receiveCausal(room string, msg *proto.BroadcastMessage) []delivery {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cr := c.causalRoomLocked(room)
+
+	var ready []delivery
+	if msg.Sender == "" {
+		// the server's join and leave messages come after every chat message in their
+		// vector, the ones we are still waiting for are never sent to us
+		var waiting []*proto.BroadcastMessage
+		for _, p := range cr.pending {
+			if vectorClock(p.VectorClock).leq(msg.VectorClock) {
+				ready = append(ready, cr.deliverLocked(p))
+			} else {
+				waiting = append(waiting, p)
+			}
+		}
+		cr.pending = waiting
+		cr.delivered.merge(msg.VectorClock)
+		ready = append(ready, delivery{msg: msg})
+	} else {
+		cr.pending = append(cr.pending, msg)
+	}
+
+	for {
+		i := slices.IndexFunc(cr.pending, func(p *proto.BroadcastMessage) bool { return cr.deliverable(p, c.name) })
+		if i < 0 {
+			break
+		}
+		p := cr.pending[i]
+		cr.pending = slices.Delete(cr.pending, i, i+1)
+		ready = append(ready, cr.deliverLocked(p))
+	}
+	return ready
+}
+
+// flushPending gives up waiting: it returns every held back message, in the order they came
+func (c *userInfo) flushPending() []delivery {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var ready []delivery
+	for _, cr := range c.causal {
+		for _, p := range cr.pending {
+			ready = append(ready, cr.deliverLocked(p))
+		}
+		cr.pending = nil
+	}
+	return ready
+}
+
+// deliverable is true if everything the sender had seen before the message has been shown
+func (cr *causalRoom) deliverable(msg *proto.BroadcastMessage, me string) bool {
+	if msg.Sender == me {
+		// our own messages were counted when we sent them
+		return true
+	}
+	for name, n := range msg.VectorClock {
+		if name == msg.Sender {
+			if n > cr.delivered[name]+1 {
+				return false
+			}
+		} else if n > cr.delivered[name] {
+			return false
+		}
+	}
+	return true
+}
+
+func (cr *causalRoom) deliverLocked(msg *proto.BroadcastMessage) delivery {
+	d := delivery{msg: msg}
+	v := vectorClock(msg.VectorClock)
+	for _, r := range cr.recent {
+		if concurrent(v, r.VectorClock) && !slices.Contains(d.concurrent, r.Sender) {
+			d.concurrent = append(d.concurrent, r.Sender)
+		}
+	}
+	cr.delivered.merge(v)
+	cr.recent = append(cr.recent, msg)
+	if len(cr.recent) > concurrencyWindow {
+		cr.recent = cr.recent[1:]
+	}
+	return d
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"ITUserver/participant"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"flag"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// certgen makes a CA and certificates signed by it, to try TLS on one machine:
+// a certificate for the servers, and one for every participant named on the command line
+// (its common name is the participant). A CA that is already in the directory is used again,
+// so more participants can be added later.
+
+// serverName is the common name of the server certificate, it has a space so it is never a participant
+const serverName = "ChitChat server"
+
+func main() {
+	out := flag.String("out", "certs", "directory for the certificates and keys")
+	hosts := flag.String("hosts", "localhost,127.0.0.1", "comma separated names and addresses the servers are reached at")
+	days := flag.Int("days", 365, "how long the certificates are valid")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: certgen [-out dir] [-hosts localhost,127.0.0.1] [participant ...]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	// check every name before anything is written
+	for _, name := range flag.Args() {
+		if err := participant.ValidName(name); err != nil {
+			fail(err)
+		}
+	}
+	if err := os.MkdirAll(*out, 0755); err != nil {
+		fail(err)
+	}
+	validity := time.Duration(*days) * 24 * time.Hour
+
+	ca, caKey, err := loadCA(*out)
+	if os.IsNotExist(err) {
+		ca, caKey, err = newCA(*out, validity)
+	}
+	if err != nil {
+		fail(err)
+	}
+
+	if _, err := os.Stat(filepath.Join(*out, "server.pem")); os.IsNotExist(err) {
+		template := newTemplate(serverName, validity)
+		// servers show it to their peers too
+		template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth}
+		for _, host := range strings.Split(*hosts, ",") {
+			if ip := net.ParseIP(host); ip != nil {
+				template.IPAddresses = append(template.IPAddresses, ip)
+			} else if host != "" {
+				template.DNSNames = append(template.DNSNames, host)
+			}
+		}
+		if err := issue(*out, "server", template, ca, caKey); err != nil {
+			fail(err)
+		}
+	}
+
+	for _, name := range flag.Args() {
+		template := newTemplate(name, validity)
+		template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+		if err := issue(*out, name, template, ca, caKey); err != nil {
+			fail(err)
+		}
+	}
+}
+
+func fail(err error) {
+	fmt.Fprintf(os.Stderr, "%v\n", err)
+	os.Exit(1)
+}
+
+func newTemplate(commonName string, validity time.Duration) *x509.Certificate {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		fail(err)
+	}
+	return &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(validity),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+}
+
+func loadCA(dir string) (*x509.Certificate, *ecdsa.PrivateKey, error) {
+	certPEM, err := os.ReadFile(filepath.Join(dir, "ca.pem"))
+	if err != nil {
+		return nil, nil, err
+	}
+	keyPEM, err := os.ReadFile(filepath.Join(dir, "ca-key.pem"))
+	if err != nil {
+		return nil, nil, err
+	}
+	certBlock, _ := pem.Decode(certPEM)
+	keyBlock, _ := pem.Decode(keyPEM)
+	if certBlock == nil || keyBlock == nil {
+		return nil, nil, fmt.Errorf("%s: the CA files are not PEM", dir)
+	}
+	cert, err := x509.ParseCertificate(certBlock.Bytes)
+	if err != nil {
+		return nil, nil, err
+	}
+	key, err := x509.ParsePKCS8PrivateKey(keyBlock.Bytes)
+	if err != nil {
+		return nil, nil, err
+	}
+	ecKey, ok := key.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, nil, fmt.Errorf("%s: the CA key is not an ECDSA key", dir)
+	}
+	fmt.Printf("Using the CA in %s\n", filepath.Join(dir, "ca.pem"))
+	return cert, ecKey, nil
+}
+
+func newCA(dir string, validity time.Duration) (*x509.Certificate, *ecdsa.PrivateKey, error) {
+	template := newTemplate("ChitChat test CA", validity)
+	template.IsCA = true
+	template.BasicConstraintsValid = true
+	template.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageCRLSign
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := write(dir, "ca", der, key); err != nil {
+		return nil, nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	return cert, key, err
+}
+
+// issue makes a key and a certificate signed by the CA, in <name>.pem and <name>-key.pem
+func issue(dir, name string, template, ca *x509.Certificate, caKey *ecdsa.PrivateKey) error {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+	if err != nil {
+		return err
+	}
+	return write(dir, name, der, key)
+}
+
+func write(dir, name string, der []byte, key *ecdsa.PrivateKey) error {
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return err
+	}
+	certPath := filepath.Join(dir, name+".pem")
+	if err := os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, name+"-key.pem"), pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		return err
+	}
+	fmt.Printf("Wrote %s\n", certPath)
+	return nil
+}
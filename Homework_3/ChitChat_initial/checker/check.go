@@ -0,0 +1,193 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// violation is a clock condition that does not hold, reported at the line of ev
+type violation struct {
+	ev  *event
+	msg string
+}
+
+func ref(ev *event) string {
+	return fmt.Sprintf("%s:%d", ev.file, ev.line)
+}
+
+// clockKey is one clock: every room of every run of a process has its own
+type clockKey struct {
+	process string
+	run     int
+	room    string
+}
+
+// checkMonotonic: every event of a process has a later Lamport time than the one before, on the same clock.
+// The server only logs its receives with the time of the broadcast that follows, so only its sends count.
+func checkMonotonic(events []*event) []violation {
+	var found []violation
+	last := make(map[clockKey]*event)
+	for _, ev := range events {
+		if ev.server && ev.kind == receive {
+			continue
+		}
+		key := clockKey{ev.process, ev.run, ev.room}
+		if prev, ok := last[key]; ok && ev.lamport <= prev.lamport {
+			found = append(found, violation{ev, fmt.Sprintf("Lamport %d is not after %d (line %d), the clock of %s in %s went back",
+				ev.lamport, prev.lamport, prev.line, processName(ev), ev.room)})
+		}
+		last[key] = ev
+	}
+	return found
+}
+
+func processName(ev *event) string {
+	if ev.server {
+		return "the server"
+	}
+	return ev.process
+}
+
+// closest picks the candidate nearest in time to ev that is not used yet. A candidate that
+// comes first in the causal order (before says which) can't be logged much later than ev.
+func closest(ev *event, candidates []*event, used map[*event]bool, before bool) *event {
+	var best *event
+	var bestGap time.Duration
+	for _, c := range candidates {
+		if used[c] {
+			continue
+		}
+		// the logs have whole seconds
+		if before && c.time.After(ev.time.Add(time.Second)) || !before && c.time.Before(ev.time.Add(-time.Second)) {
+			continue
+		}
+		gap := c.time.Sub(ev.time).Abs()
+		if best == nil || gap < bestGap {
+			best, bestGap = c, gap
+		}
+	}
+	return best
+}
+
+// matching is which server event every client event belongs to
+type matching struct {
+	broadcastOf map[*event]*event // client receive -> server broadcast
+	receiveOf   map[*event]*event // client publish -> server receive
+	unmatched   []*event
+}
+
+// match finds the broadcast of every message a client got (by its sequence number, or by room
+// and content in older logs) and the server's receive of every message a client published
+func match(events []*event) *matching {
+	bySeq := make(map[uint64][]*event)
+	byContent := make(map[string][]*event)
+	byPublisher := make(map[string][]*event)
+	for _, ev := range events {
+		switch {
+		case ev.server && ev.kind == send:
+			if ev.seq != 0 {
+				bySeq[ev.seq] = append(bySeq[ev.seq], ev)
+			}
+			byContent[ev.room+"\x00"+ev.content] = append(byContent[ev.room+"\x00"+ev.content], ev)
+		case ev.server && ev.kind == receive:
+			byPublisher[ev.from+"\x00"+ev.room] = append(byPublisher[ev.from+"\x00"+ev.room], ev)
+		}
+	}
+
+	m := &matching{broadcastOf: make(map[*event]*event), receiveOf: make(map[*event]*event)}
+	usedBy := make(map[string]map[*event]bool) // a client gets every broadcast once
+	usedReceives := make(map[*event]bool)
+	for _, ev := range events {
+		if ev.server {
+			continue
+		}
+		if ev.kind == send {
+			if r := closest(ev, byPublisher[ev.from+"\x00"+ev.room], usedReceives, false); r != nil {
+				usedReceives[r] = true
+				m.receiveOf[ev] = r
+			} else {
+				m.unmatched = append(m.unmatched, ev)
+			}
+			continue
+		}
+
+		if usedBy[ev.process] == nil {
+			usedBy[ev.process] = make(map[*event]bool)
+		}
+		candidates := byContent[ev.room+"\x00"+ev.content]
+		if ev.seq != 0 && len(bySeq[ev.seq]) > 0 {
+			candidates = bySeq[ev.seq]
+		}
+		if b := closest(ev, candidates, usedBy[ev.process], true); b != nil {
+			usedBy[ev.process][b] = true
+			m.broadcastOf[ev] = b
+		} else {
+			m.unmatched = append(m.unmatched, ev)
+		}
+	}
+	return m
+}
+
+// checkSendReceive: a message is received at a later Lamport time than it was sent
+func checkSendReceive(m *matching) []violation {
+	var found []violation
+	for recv, b := range m.broadcastOf {
+		if recv.lamport <= b.lamport {
+			found = append(found, violation{recv, fmt.Sprintf("%s got %q at Lamport %d, but the server sent it at Lamport %d (%s)",
+				recv.process, recv.content, recv.lamport, b.lamport, ref(b))})
+		}
+	}
+	for pub, r := range m.receiveOf {
+		if r.lamport <= pub.lamport {
+			found = append(found, violation{r, fmt.Sprintf("the server got the message of %s at Lamport %d, but it was sent at Lamport %d (%s)",
+				pub.from, r.lamport, pub.lamport, ref(pub))})
+		}
+	}
+	return found
+}
+
+// checkOrder: every two clients got the messages they both got in the same order.
+// It returns the violations and how many messages more than one client got.
+func checkOrder(events []*event, m *matching) ([]violation, int) {
+	var clients []string
+	got := make(map[string][]*event) // client -> its receives, in order
+	for _, ev := range events {
+		if b, ok := m.broadcastOf[ev]; ok && b != nil {
+			if len(got[ev.process]) == 0 {
+				clients = append(clients, ev.process)
+			}
+			got[ev.process] = append(got[ev.process], ev)
+		}
+	}
+	sort.Strings(clients)
+
+	var found []violation
+	shared := make(map[*event]bool)
+	for i, a := range clients {
+		for _, b := range clients[i+1:] {
+			// where b got every broadcast
+			position := make(map[*event]int)
+			receiveOf := make(map[*event]*event)
+			for n, ev := range got[b] {
+				position[m.broadcastOf[ev]] = n
+				receiveOf[m.broadcastOf[ev]] = ev
+			}
+			var prev *event
+			for _, ev := range got[a] {
+				msg := m.broadcastOf[ev]
+				if _, both := position[msg]; !both {
+					continue
+				}
+				shared[msg] = true
+				if prev != nil && position[msg] < position[m.broadcastOf[prev]] {
+					other, otherPrev := receiveOf[msg], receiveOf[m.broadcastOf[prev]]
+					found = append(found, violation{ev, fmt.Sprintf("%s got %q after %q (line %d), but %s got them the other way round (%s, %s)",
+						a, ev.content, prev.content, prev.line, b, ref(other), ref(otherPrev))})
+				}
+				prev = ev
+			}
+		}
+	}
+	return found, len(shared)
+}
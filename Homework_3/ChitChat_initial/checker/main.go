@@ -0,0 +1,81 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// The checker reads the logs of a chat (server.log and the client_<name>.log files) and checks
+// the Lamport clocks: every process' clock only goes forward, a message is received at a later
+// time than it was sent, and all clients got the messages in the same order.
+// It exits with status 1 if one of them does not hold.
+
+func main() {
+	verbose := flag.Bool("v", false, "also list the log lines that could not be matched")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: checker [-v] [server.log client_<name>.log ...]\n")
+		fmt.Fprintf(os.Stderr, "Without files it reads server.log and client_*.log in the current directory.\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	paths := flag.Args()
+	if len(paths) == 0 {
+		clients, _ := filepath.Glob("client_*.log")
+		paths = append([]string{"server.log"}, clients...)
+	}
+
+	var events []*event
+	processes := make(map[string]bool)
+	for _, path := range paths {
+		evs, err := parseLog(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(2)
+		}
+		for _, ev := range evs {
+			processes[ev.process] = true
+		}
+		events = append(events, evs...)
+	}
+
+	m := match(events)
+	violations := checkMonotonic(events)
+	violations = append(violations, checkSendReceive(m)...)
+	order, shared := checkOrder(events, m)
+	violations = append(violations, order...)
+
+	sort.SliceStable(violations, func(i, j int) bool {
+		a, b := violations[i].ev, violations[j].ev
+		if a.file != b.file {
+			return a.file < b.file
+		}
+		return a.line < b.line
+	})
+	for _, v := range violations {
+		fmt.Printf("%s: %s\n", ref(v.ev), v.msg)
+	}
+	if *verbose {
+		for _, ev := range m.unmatched {
+			if ev.kind == send {
+				fmt.Printf("%s: (not found in the server log) %s published at Lamport %d\n", ref(ev), ev.from, ev.lamport)
+			} else {
+				fmt.Printf("%s: (not found in the server log) %s got %q\n", ref(ev), ev.process, ev.content)
+			}
+		}
+	}
+
+	fmt.Printf("%d events of %d processes in %d logs, %d sends matched with their receives, %d log lines not matched\n",
+		len(events), len(processes), len(paths), len(m.broadcastOf)+len(m.receiveOf), len(m.unmatched))
+	if len(order) == 0 {
+		fmt.Printf("%d messages were got by more than one client, all in the same order\n", shared)
+	}
+	if len(violations) > 0 {
+		fmt.Printf("%d violations\n", len(violations))
+		os.Exit(1)
+	}
+	fmt.Println("The Lamport clocks are consistent")
+}
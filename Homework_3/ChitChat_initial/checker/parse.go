@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type eventKind int
+
+const (
+	send    eventKind = iota // the server broadcasts, a client publishes
+	receive                  // the server gets a published message, a client gets a broadcast
+)
+
+// event is a send or receive found in a log
+type event struct {
+	file    string
+	line    int
+	time    time.Time
+	process string
+	run     int // the process was started this many times before, its clocks start over
+	kind    eventKind
+	server  bool
+
+	room    string
+	content string // of broadcasts and the messages clients got
+	from    string // who published, for the server's receives and the clients' sends
+	lamport int64
+	seq     uint64 // 0 in logs written before there were sequence numbers
+}
+
+// The formats of the log lines, the ones of older versions too (without rooms and sequence numbers,
+// and with the clock of a published message logged after the call returned)
+var (
+	logTime = regexp.MustCompile(`^(\d{4}/\d\d/\d\d \d\d:\d\d:\d\d) (.*)$`)
+
+	serverStart     = regexp.MustCompile(`^\[Server\] Starting up`)
+	serverBroadcast = regexp.MustCompile(`^\[Server\] Broadcasting(?: in (\S+))?: (.*) \(Lamport: (\d+)(?:, seq: (\d+))?\)$`)
+	serverReceive   = regexp.MustCompile(`^\[Server\] Message from (\S+)(?: in (\S+))? \(Lamport: (\d+)\)$`)
+
+	clientStart   = regexp.MustCompile(`^\[Client (\S+)\] Connecting to server`)
+	clientPublish = regexp.MustCompile(`^\[Client (\S+)\] (Publish(?:ed|ing)) message(?: in (\S+))?: (?:%!s\(int64=)?(\d+)`)
+	clientReceive = regexp.MustCompile(`^\[Client: (\S+)\] Recieved(?: in (\S+))?: (.*) \(Lamport: (\d+)(?:, seq: (\d+))?(?:, vector: [^)]*)?\)(?: \(concurrent with [^)]*\))?$`)
+)
+
+const defaultRoom = "#general"
+
+func orDefault(room string) string {
+	if room == "" {
+		return defaultRoom
+	}
+	return room
+}
+
+// parseLog reads the send and receive events of a server or client log
+func parseLog(path string) ([]*event, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var events []*event
+	var echo *event // the last receive put after its send
+	run := 0
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		m := logTime.FindStringSubmatch(scanner.Text())
+		if m == nil {
+			continue
+		}
+		t, _ := time.ParseInLocation("2006/01/02 15:04:05", m[1], time.Local)
+		text := m[2]
+		ev := &event{file: path, line: line, time: t}
+
+		switch {
+		case serverStart.MatchString(text), clientStart.MatchString(text):
+			run++
+			continue
+
+		case serverBroadcast.MatchString(text):
+			g := serverBroadcast.FindStringSubmatch(text)
+			ev.process, ev.server, ev.kind = path, true, send
+			ev.room, ev.content, ev.lamport, ev.seq = orDefault(g[1]), g[2], number(g[3]), uint64(number(g[4]))
+
+		case serverReceive.MatchString(text):
+			g := serverReceive.FindStringSubmatch(text)
+			ev.process, ev.server, ev.kind = path, true, receive
+			ev.from, ev.room, ev.lamport = g[1], orDefault(g[2]), number(g[3])
+
+		case clientPublish.MatchString(text):
+			g := clientPublish.FindStringSubmatch(text)
+			ev.process, ev.kind = g[1], send
+			ev.from, ev.room, ev.lamport = g[1], orDefault(g[3]), number(g[4])
+			if g[2] == "Published" && len(events) > 0 {
+				// older clients logged this after the call returned, when the echo of the
+				// message could already be logged, so put the send back before its echo
+				last := events[len(events)-1]
+				if last != echo && last.kind == receive && last.room == ev.room && last.run == run && strings.HasPrefix(last.content, ev.from+": ") {
+					ev.run = run
+					echo = last
+					events = append(events[:len(events)-1], ev, last)
+					continue
+				}
+			}
+
+		case clientReceive.MatchString(text):
+			g := clientReceive.FindStringSubmatch(text)
+			ev.process, ev.kind = g[1], receive
+			ev.room, ev.content, ev.lamport, ev.seq = orDefault(g[2]), g[3], number(g[4]), uint64(number(g[5]))
+
+		default:
+			continue
+		}
+		ev.run = run
+		events = append(events, ev)
+	}
+	return events, scanner.Err()
+}
+
+func number(s string) int64 {
+	n, _ := strconv.ParseInt(s, 10, 64)
+	return n
+}
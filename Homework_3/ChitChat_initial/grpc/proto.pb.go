@@ -21,13 +21,285 @@ const (
 	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
 )
 
+type RoomChangeType int32
+
+const (
+	RoomChangeType_CREATED RoomChangeType = 0
+	RoomChangeType_JOINED  RoomChangeType = 1
+	RoomChangeType_LEFT    RoomChangeType = 2
+	// the participant joined the chat, it is in #general only
+	RoomChangeType_JOINED_CHAT RoomChangeType = 3
+	// the participant left the chat, it is in no room
+	RoomChangeType_LEFT_CHAT RoomChangeType = 4
+)
+
+// Enum value maps for RoomChangeType.
+var (
+	RoomChangeType_name = map[int32]string{
+		0: "CREATED",
+		1: "JOINED",
+		2: "LEFT",
+		3: "JOINED_CHAT",
+		4: "LEFT_CHAT",
+	}
+	RoomChangeType_value = map[string]int32{
+		"CREATED":     0,
+		"JOINED":      1,
+		"LEFT":        2,
+		"JOINED_CHAT": 3,
+		"LEFT_CHAT":   4,
+	}
+)
+
+func (x RoomChangeType) Enum() *RoomChangeType {
+	p := new(RoomChangeType)
+	*p = x
+	return p
+}
+
+func (x RoomChangeType) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (RoomChangeType) Descriptor() protoreflect.EnumDescriptor {
+	return file_proto_proto_enumTypes[0].Descriptor()
+}
+
+func (RoomChangeType) Type() protoreflect.EnumType {
+	return &file_proto_proto_enumTypes[0]
+}
+
+func (x RoomChangeType) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use RoomChangeType.Descriptor instead.
+func (RoomChangeType) EnumDescriptor() ([]byte, []int) {
+	return file_proto_proto_rawDescGZIP(), []int{0}
+}
+
+type SessionChangeType int32
+
+const (
+	SessionChangeType_STARTED SessionChangeType = 0
+	// every session of the participant ended
+	SessionChangeType_ENDED SessionChangeType = 1
+)
+
+// Enum value maps for SessionChangeType.
+var (
+	SessionChangeType_name = map[int32]string{
+		0: "STARTED",
+		1: "ENDED",
+	}
+	SessionChangeType_value = map[string]int32{
+		"STARTED": 0,
+		"ENDED":   1,
+	}
+)
+
+func (x SessionChangeType) Enum() *SessionChangeType {
+	p := new(SessionChangeType)
+	*p = x
+	return p
+}
+
+func (x SessionChangeType) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (SessionChangeType) Descriptor() protoreflect.EnumDescriptor {
+	return file_proto_proto_enumTypes[1].Descriptor()
+}
+
+func (SessionChangeType) Type() protoreflect.EnumType {
+	return &file_proto_proto_enumTypes[1]
+}
+
+func (x SessionChangeType) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use SessionChangeType.Descriptor instead.
+func (SessionChangeType) EnumDescriptor() ([]byte, []int) {
+	return file_proto_proto_rawDescGZIP(), []int{1}
+}
+
+type AccountChangeType int32
+
+const (
+	AccountChangeType_REGISTER AccountChangeType = 0
+	AccountChangeType_BANNED   AccountChangeType = 1
+	AccountChangeType_UNBANNED AccountChangeType = 2
+	AccountChangeType_MUTED    AccountChangeType = 3
+	AccountChangeType_UNMUTED  AccountChangeType = 4
+)
+
+// Enum value maps for AccountChangeType.
+var (
+	AccountChangeType_name = map[int32]string{
+		0: "REGISTER",
+		1: "BANNED",
+		2: "UNBANNED",
+		3: "MUTED",
+		4: "UNMUTED",
+	}
+	AccountChangeType_value = map[string]int32{
+		"REGISTER": 0,
+		"BANNED":   1,
+		"UNBANNED": 2,
+		"MUTED":    3,
+		"UNMUTED":  4,
+	}
+)
+
+func (x AccountChangeType) Enum() *AccountChangeType {
+	p := new(AccountChangeType)
+	*p = x
+	return p
+}
+
+func (x AccountChangeType) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (AccountChangeType) Descriptor() protoreflect.EnumDescriptor {
+	return file_proto_proto_enumTypes[2].Descriptor()
+}
+
+func (AccountChangeType) Type() protoreflect.EnumType {
+	return &file_proto_proto_enumTypes[2]
+}
+
+func (x AccountChangeType) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use AccountChangeType.Descriptor instead.
+func (AccountChangeType) EnumDescriptor() ([]byte, []int) {
+	return file_proto_proto_rawDescGZIP(), []int{2}
+}
+
+// What an admin does to a participant
+type ModerationAction int32
+
+const (
+	// end the session, the participant can log in again
+	ModerationAction_KICK ModerationAction = 0
+	// no messages for duration_seconds
+	ModerationAction_MUTE   ModerationAction = 1
+	ModerationAction_UNMUTE ModerationAction = 2
+	// kick, and refuse every login until unbanned
+	ModerationAction_BAN   ModerationAction = 3
+	ModerationAction_UNBAN ModerationAction = 4
+)
+
+// Enum value maps for ModerationAction.
+var (
+	ModerationAction_name = map[int32]string{
+		0: "KICK",
+		1: "MUTE",
+		2: "UNMUTE",
+		3: "BAN",
+		4: "UNBAN",
+	}
+	ModerationAction_value = map[string]int32{
+		"KICK":   0,
+		"MUTE":   1,
+		"UNMUTE": 2,
+		"BAN":    3,
+		"UNBAN":  4,
+	}
+)
+
+func (x ModerationAction) Enum() *ModerationAction {
+	p := new(ModerationAction)
+	*p = x
+	return p
+}
+
+func (x ModerationAction) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (ModerationAction) Descriptor() protoreflect.EnumDescriptor {
+	return file_proto_proto_enumTypes[3].Descriptor()
+}
+
+func (ModerationAction) Type() protoreflect.EnumType {
+	return &file_proto_proto_enumTypes[3]
+}
+
+func (x ModerationAction) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use ModerationAction.Descriptor instead.
+func (ModerationAction) EnumDescriptor() ([]byte, []int) {
+	return file_proto_proto_rawDescGZIP(), []int{3}
+}
+
+// Whether a participant is there
+type PresenceStatus int32
+
+const (
+	PresenceStatus_OFFLINE PresenceStatus = 0
+	PresenceStatus_ONLINE  PresenceStatus = 1
+	// still connected, but no heartbeat for a while, or the stream broke and may be resumed
+	PresenceStatus_IDLE PresenceStatus = 2
+)
+
+// Enum value maps for PresenceStatus.
+var (
+	PresenceStatus_name = map[int32]string{
+		0: "OFFLINE",
+		1: "ONLINE",
+		2: "IDLE",
+	}
+	PresenceStatus_value = map[string]int32{
+		"OFFLINE": 0,
+		"ONLINE":  1,
+		"IDLE":    2,
+	}
+)
+
+func (x PresenceStatus) Enum() *PresenceStatus {
+	p := new(PresenceStatus)
+	*p = x
+	return p
+}
+
+func (x PresenceStatus) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (PresenceStatus) Descriptor() protoreflect.EnumDescriptor {
+	return file_proto_proto_enumTypes[4].Descriptor()
+}
+
+func (PresenceStatus) Type() protoreflect.EnumType {
+	return &file_proto_proto_enumTypes[4]
+}
+
+func (x PresenceStatus) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use PresenceStatus.Descriptor instead.
+func (PresenceStatus) EnumDescriptor() ([]byte, []int) {
+	return file_proto_proto_rawDescGZIP(), []int{4}
+}
+
 // Type of broadcast message
 type MessageType int32
 
 const (
-	MessageType_CHAT  MessageType = 0
-	MessageType_JOIN  MessageType = 1
-	MessageType_LEAVE MessageType = 2
+	MessageType_CHAT    MessageType = 0
+	MessageType_JOIN    MessageType = 1
+	MessageType_LEAVE   MessageType = 2
+	MessageType_PRIVATE MessageType = 3
+	// from the server to one client, e.g. that it missed messages
+	MessageType_NOTICE MessageType = 4
 )
 
 // Enum value maps for MessageType.
@@ -36,11 +308,15 @@ var (
 		0: "CHAT",
 		1: "JOIN",
 		2: "LEAVE",
+		3: "PRIVATE",
+		4: "NOTICE",
 	}
 	MessageType_value = map[string]int32{
-		"CHAT":  0,
-		"JOIN":  1,
-		"LEAVE": 2,
+		"CHAT":    0,
+		"JOIN":    1,
+		"LEAVE":   2,
+		"PRIVATE": 3,
+		"NOTICE":  4,
 	}
 )
 
@@ -55,45 +331,2178 @@ func (x MessageType) String() string {
 }
 
 func (MessageType) Descriptor() protoreflect.EnumDescriptor {
-	return file_proto_proto_enumTypes[0].Descriptor()
+	return file_proto_proto_enumTypes[5].Descriptor()
 }
 
 func (MessageType) Type() protoreflect.EnumType {
-	return &file_proto_proto_enumTypes[0]
+	return &file_proto_proto_enumTypes[5]
+}
+
+func (x MessageType) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use MessageType.Descriptor instead.
+func (MessageType) EnumDescriptor() ([]byte, []int) {
+	return file_proto_proto_rawDescGZIP(), []int{5}
+}
+
+// What happens to a message for a client whose queue is full
+type SlowConsumerPolicy int32
+
+const (
+	SlowConsumerPolicy_SERVER_DEFAULT SlowConsumerPolicy = 0
+	// throw away the oldest message in the queue
+	SlowConsumerPolicy_DROP_OLDEST SlowConsumerPolicy = 1
+	// end the client's stream, it can resume and get the messages from the history
+	SlowConsumerPolicy_DISCONNECT SlowConsumerPolicy = 2
+	// wait a while for room in the queue, then throw the message away.
+	// Only the server can choose it, the messages for everybody wait too.
+	SlowConsumerPolicy_BLOCK SlowConsumerPolicy = 3
+)
+
+// Enum value maps for SlowConsumerPolicy.
+var (
+	SlowConsumerPolicy_name = map[int32]string{
+		0: "SERVER_DEFAULT",
+		1: "DROP_OLDEST",
+		2: "DISCONNECT",
+		3: "BLOCK",
+	}
+	SlowConsumerPolicy_value = map[string]int32{
+		"SERVER_DEFAULT": 0,
+		"DROP_OLDEST":    1,
+		"DISCONNECT":     2,
+		"BLOCK":          3,
+	}
+)
+
+func (x SlowConsumerPolicy) Enum() *SlowConsumerPolicy {
+	p := new(SlowConsumerPolicy)
+	*p = x
+	return p
+}
+
+func (x SlowConsumerPolicy) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (SlowConsumerPolicy) Descriptor() protoreflect.EnumDescriptor {
+	return file_proto_proto_enumTypes[6].Descriptor()
+}
+
+func (SlowConsumerPolicy) Type() protoreflect.EnumType {
+	return &file_proto_proto_enumTypes[6]
+}
+
+func (x SlowConsumerPolicy) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use SlowConsumerPolicy.Descriptor instead.
+func (SlowConsumerPolicy) EnumDescriptor() ([]byte, []int) {
+	return file_proto_proto_rawDescGZIP(), []int{6}
+}
+
+// Name and password of a participant
+type Credentials struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	ParticipantName string                 `protobuf:"bytes,1,opt,name=participant_name,json=participantName,proto3" json:"participant_name,omitempty"`
+	Password        string                 `protobuf:"bytes,2,opt,name=password,proto3" json:"password,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *Credentials) Reset() {
+	*x = Credentials{}
+	mi := &file_proto_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Credentials) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Credentials) ProtoMessage() {}
+
+func (x *Credentials) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Credentials.ProtoReflect.Descriptor instead.
+func (*Credentials) Descriptor() ([]byte, []int) {
+	return file_proto_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Credentials) GetParticipantName() string {
+	if x != nil {
+		return x.ParticipantName
+	}
+	return ""
+}
+
+func (x *Credentials) GetPassword() string {
+	if x != nil {
+		return x.Password
+	}
+	return ""
+}
+
+type LoginResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Success       bool                   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
+	Token         string                 `protobuf:"bytes,2,opt,name=token,proto3" json:"token,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LoginResponse) Reset() {
+	*x = LoginResponse{}
+	mi := &file_proto_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LoginResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LoginResponse) ProtoMessage() {}
+
+func (x *LoginResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LoginResponse.ProtoReflect.Descriptor instead.
+func (*LoginResponse) Descriptor() ([]byte, []int) {
+	return file_proto_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *LoginResponse) GetSuccess() bool {
+	if x != nil {
+		return x.Success
+	}
+	return false
+}
+
+func (x *LoginResponse) GetToken() string {
+	if x != nil {
+		return x.Token
+	}
+	return ""
+}
+
+// An entry of the replicated log
+type RaftEntry struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Term  int64                  `protobuf:"varint,1,opt,name=term,proto3" json:"term,omitempty"`
+	// empty for the entry a new leader starts its term with
+	Message *BroadcastMessage `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	// accounts, bans and mutes are the same on every replica, they go through the log too
+	Account *AccountChange `protobuf:"bytes,3,opt,name=account,proto3" json:"account,omitempty"`
+	// so are the rooms, who is in them and the sessions, a client goes on with them on the next leader
+	Room          *RoomChange    `protobuf:"bytes,4,opt,name=room,proto3" json:"room,omitempty"`
+	Session       *SessionChange `protobuf:"bytes,5,opt,name=session,proto3" json:"session,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RaftEntry) Reset() {
+	*x = RaftEntry{}
+	mi := &file_proto_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RaftEntry) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RaftEntry) ProtoMessage() {}
+
+func (x *RaftEntry) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RaftEntry.ProtoReflect.Descriptor instead.
+func (*RaftEntry) Descriptor() ([]byte, []int) {
+	return file_proto_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *RaftEntry) GetTerm() int64 {
+	if x != nil {
+		return x.Term
+	}
+	return 0
+}
+
+func (x *RaftEntry) GetMessage() *BroadcastMessage {
+	if x != nil {
+		return x.Message
+	}
+	return nil
+}
+
+func (x *RaftEntry) GetAccount() *AccountChange {
+	if x != nil {
+		return x.Account
+	}
+	return nil
+}
+
+func (x *RaftEntry) GetRoom() *RoomChange {
+	if x != nil {
+		return x.Room
+	}
+	return nil
+}
+
+func (x *RaftEntry) GetSession() *SessionChange {
+	if x != nil {
+		return x.Session
+	}
+	return nil
+}
+
+type RoomChange struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Type  RoomChangeType         `protobuf:"varint,1,opt,name=type,proto3,enum=RoomChangeType" json:"type,omitempty"`
+	// empty for joined_chat and left_chat
+	Room            string `protobuf:"bytes,2,opt,name=room,proto3" json:"room,omitempty"`
+	ParticipantName string `protobuf:"bytes,3,opt,name=participant_name,json=participantName,proto3" json:"participant_name,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *RoomChange) Reset() {
+	*x = RoomChange{}
+	mi := &file_proto_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RoomChange) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RoomChange) ProtoMessage() {}
+
+func (x *RoomChange) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RoomChange.ProtoReflect.Descriptor instead.
+func (*RoomChange) Descriptor() ([]byte, []int) {
+	return file_proto_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *RoomChange) GetType() RoomChangeType {
+	if x != nil {
+		return x.Type
+	}
+	return RoomChangeType_CREATED
+}
+
+func (x *RoomChange) GetRoom() string {
+	if x != nil {
+		return x.Room
+	}
+	return ""
+}
+
+func (x *RoomChange) GetParticipantName() string {
+	if x != nil {
+		return x.ParticipantName
+	}
+	return ""
+}
+
+type SessionChange struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	Type            SessionChangeType      `protobuf:"varint,1,opt,name=type,proto3,enum=SessionChangeType" json:"type,omitempty"`
+	ParticipantName string                 `protobuf:"bytes,2,opt,name=participant_name,json=participantName,proto3" json:"participant_name,omitempty"`
+	// started: the SHA-256 of the token, the token itself is never written to the log
+	TokenHash     string `protobuf:"bytes,3,opt,name=token_hash,json=tokenHash,proto3" json:"token_hash,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SessionChange) Reset() {
+	*x = SessionChange{}
+	mi := &file_proto_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SessionChange) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SessionChange) ProtoMessage() {}
+
+func (x *SessionChange) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SessionChange.ProtoReflect.Descriptor instead.
+func (*SessionChange) Descriptor() ([]byte, []int) {
+	return file_proto_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *SessionChange) GetType() SessionChangeType {
+	if x != nil {
+		return x.Type
+	}
+	return SessionChangeType_STARTED
+}
+
+func (x *SessionChange) GetParticipantName() string {
+	if x != nil {
+		return x.ParticipantName
+	}
+	return ""
+}
+
+func (x *SessionChange) GetTokenHash() string {
+	if x != nil {
+		return x.TokenHash
+	}
+	return ""
+}
+
+type AccountChange struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	Type            AccountChangeType      `protobuf:"varint,1,opt,name=type,proto3,enum=AccountChangeType" json:"type,omitempty"`
+	ParticipantName string                 `protobuf:"bytes,2,opt,name=participant_name,json=participantName,proto3" json:"participant_name,omitempty"`
+	// register: the salt and password hash (both empty for a participant with a certificate)
+	Salt string `protobuf:"bytes,3,opt,name=salt,proto3" json:"salt,omitempty"`
+	Hash string `protobuf:"bytes,4,opt,name=hash,proto3" json:"hash,omitempty"`
+	// muted: until this Unix time in milliseconds
+	MutedUntil    int64 `protobuf:"varint,5,opt,name=muted_until,json=mutedUntil,proto3" json:"muted_until,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AccountChange) Reset() {
+	*x = AccountChange{}
+	mi := &file_proto_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AccountChange) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AccountChange) ProtoMessage() {}
+
+func (x *AccountChange) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AccountChange.ProtoReflect.Descriptor instead.
+func (*AccountChange) Descriptor() ([]byte, []int) {
+	return file_proto_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *AccountChange) GetType() AccountChangeType {
+	if x != nil {
+		return x.Type
+	}
+	return AccountChangeType_REGISTER
+}
+
+func (x *AccountChange) GetParticipantName() string {
+	if x != nil {
+		return x.ParticipantName
+	}
+	return ""
+}
+
+func (x *AccountChange) GetSalt() string {
+	if x != nil {
+		return x.Salt
+	}
+	return ""
+}
+
+func (x *AccountChange) GetHash() string {
+	if x != nil {
+		return x.Hash
+	}
+	return ""
+}
+
+func (x *AccountChange) GetMutedUntil() int64 {
+	if x != nil {
+		return x.MutedUntil
+	}
+	return 0
+}
+
+type VoteRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Term          int64                  `protobuf:"varint,1,opt,name=term,proto3" json:"term,omitempty"`
+	Candidate     string                 `protobuf:"bytes,2,opt,name=candidate,proto3" json:"candidate,omitempty"`
+	LastLogIndex  int64                  `protobuf:"varint,3,opt,name=last_log_index,json=lastLogIndex,proto3" json:"last_log_index,omitempty"`
+	LastLogTerm   int64                  `protobuf:"varint,4,opt,name=last_log_term,json=lastLogTerm,proto3" json:"last_log_term,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *VoteRequest) Reset() {
+	*x = VoteRequest{}
+	mi := &file_proto_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *VoteRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*VoteRequest) ProtoMessage() {}
+
+func (x *VoteRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use VoteRequest.ProtoReflect.Descriptor instead.
+func (*VoteRequest) Descriptor() ([]byte, []int) {
+	return file_proto_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *VoteRequest) GetTerm() int64 {
+	if x != nil {
+		return x.Term
+	}
+	return 0
+}
+
+func (x *VoteRequest) GetCandidate() string {
+	if x != nil {
+		return x.Candidate
+	}
+	return ""
+}
+
+func (x *VoteRequest) GetLastLogIndex() int64 {
+	if x != nil {
+		return x.LastLogIndex
+	}
+	return 0
+}
+
+func (x *VoteRequest) GetLastLogTerm() int64 {
+	if x != nil {
+		return x.LastLogTerm
+	}
+	return 0
+}
+
+type VoteResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Term          int64                  `protobuf:"varint,1,opt,name=term,proto3" json:"term,omitempty"`
+	Granted       bool                   `protobuf:"varint,2,opt,name=granted,proto3" json:"granted,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *VoteResponse) Reset() {
+	*x = VoteResponse{}
+	mi := &file_proto_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *VoteResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*VoteResponse) ProtoMessage() {}
+
+func (x *VoteResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use VoteResponse.ProtoReflect.Descriptor instead.
+func (*VoteResponse) Descriptor() ([]byte, []int) {
+	return file_proto_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *VoteResponse) GetTerm() int64 {
+	if x != nil {
+		return x.Term
+	}
+	return 0
+}
+
+func (x *VoteResponse) GetGranted() bool {
+	if x != nil {
+		return x.Granted
+	}
+	return false
+}
+
+type AppendRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Term          int64                  `protobuf:"varint,1,opt,name=term,proto3" json:"term,omitempty"`
+	Leader        string                 `protobuf:"bytes,2,opt,name=leader,proto3" json:"leader,omitempty"`
+	PrevLogIndex  int64                  `protobuf:"varint,3,opt,name=prev_log_index,json=prevLogIndex,proto3" json:"prev_log_index,omitempty"`
+	PrevLogTerm   int64                  `protobuf:"varint,4,opt,name=prev_log_term,json=prevLogTerm,proto3" json:"prev_log_term,omitempty"`
+	Entries       []*RaftEntry           `protobuf:"bytes,5,rep,name=entries,proto3" json:"entries,omitempty"`
+	LeaderCommit  int64                  `protobuf:"varint,6,opt,name=leader_commit,json=leaderCommit,proto3" json:"leader_commit,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AppendRequest) Reset() {
+	*x = AppendRequest{}
+	mi := &file_proto_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AppendRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AppendRequest) ProtoMessage() {}
+
+func (x *AppendRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AppendRequest.ProtoReflect.Descriptor instead.
+func (*AppendRequest) Descriptor() ([]byte, []int) {
+	return file_proto_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *AppendRequest) GetTerm() int64 {
+	if x != nil {
+		return x.Term
+	}
+	return 0
+}
+
+func (x *AppendRequest) GetLeader() string {
+	if x != nil {
+		return x.Leader
+	}
+	return ""
+}
+
+func (x *AppendRequest) GetPrevLogIndex() int64 {
+	if x != nil {
+		return x.PrevLogIndex
+	}
+	return 0
+}
+
+func (x *AppendRequest) GetPrevLogTerm() int64 {
+	if x != nil {
+		return x.PrevLogTerm
+	}
+	return 0
+}
+
+func (x *AppendRequest) GetEntries() []*RaftEntry {
+	if x != nil {
+		return x.Entries
+	}
+	return nil
+}
+
+func (x *AppendRequest) GetLeaderCommit() int64 {
+	if x != nil {
+		return x.LeaderCommit
+	}
+	return 0
+}
+
+type AppendResponse struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Term    int64                  `protobuf:"varint,1,opt,name=term,proto3" json:"term,omitempty"`
+	Success bool                   `protobuf:"varint,2,opt,name=success,proto3" json:"success,omitempty"`
+	// where the leader should try next when success is false
+	ConflictIndex int64 `protobuf:"varint,3,opt,name=conflict_index,json=conflictIndex,proto3" json:"conflict_index,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AppendResponse) Reset() {
+	*x = AppendResponse{}
+	mi := &file_proto_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AppendResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AppendResponse) ProtoMessage() {}
+
+func (x *AppendResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AppendResponse.ProtoReflect.Descriptor instead.
+func (*AppendResponse) Descriptor() ([]byte, []int) {
+	return file_proto_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *AppendResponse) GetTerm() int64 {
+	if x != nil {
+		return x.Term
+	}
+	return 0
+}
+
+func (x *AppendResponse) GetSuccess() bool {
+	if x != nil {
+		return x.Success
+	}
+	return false
+}
+
+func (x *AppendResponse) GetConflictIndex() int64 {
+	if x != nil {
+		return x.ConflictIndex
+	}
+	return 0
+}
+
+// New messages from a node, and the peers it knows
+type GossipRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	From          string                 `protobuf:"bytes,1,opt,name=from,proto3" json:"from,omitempty"`
+	Messages      []*BroadcastMessage    `protobuf:"bytes,2,rep,name=messages,proto3" json:"messages,omitempty"`
+	Peers         []string               `protobuf:"bytes,3,rep,name=peers,proto3" json:"peers,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GossipRequest) Reset() {
+	*x = GossipRequest{}
+	mi := &file_proto_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GossipRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GossipRequest) ProtoMessage() {}
+
+func (x *GossipRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GossipRequest.ProtoReflect.Descriptor instead.
+func (*GossipRequest) Descriptor() ([]byte, []int) {
+	return file_proto_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *GossipRequest) GetFrom() string {
+	if x != nil {
+		return x.From
+	}
+	return ""
+}
+
+func (x *GossipRequest) GetMessages() []*BroadcastMessage {
+	if x != nil {
+		return x.Messages
+	}
+	return nil
+}
+
+func (x *GossipRequest) GetPeers() []string {
+	if x != nil {
+		return x.Peers
+	}
+	return nil
+}
+
+type GossipResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Peers         []string               `protobuf:"bytes,1,rep,name=peers,proto3" json:"peers,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GossipResponse) Reset() {
+	*x = GossipResponse{}
+	mi := &file_proto_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GossipResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GossipResponse) ProtoMessage() {}
+
+func (x *GossipResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GossipResponse.ProtoReflect.Descriptor instead.
+func (*GossipResponse) Descriptor() ([]byte, []int) {
+	return file_proto_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *GossipResponse) GetPeers() []string {
+	if x != nil {
+		return x.Peers
+	}
+	return nil
+}
+
+// Anti-entropy: for every origin the sequence number up to which a node has all messages
+type SyncRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	From          string                 `protobuf:"bytes,1,opt,name=from,proto3" json:"from,omitempty"`
+	Have          map[string]uint64      `protobuf:"bytes,2,rep,name=have,proto3" json:"have,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SyncRequest) Reset() {
+	*x = SyncRequest{}
+	mi := &file_proto_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SyncRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SyncRequest) ProtoMessage() {}
+
+func (x *SyncRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SyncRequest.ProtoReflect.Descriptor instead.
+func (*SyncRequest) Descriptor() ([]byte, []int) {
+	return file_proto_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *SyncRequest) GetFrom() string {
+	if x != nil {
+		return x.From
+	}
+	return ""
+}
+
+func (x *SyncRequest) GetHave() map[string]uint64 {
+	if x != nil {
+		return x.Have
+	}
+	return nil
+}
+
+// The messages the asking node does not have yet
+type SyncResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Messages      []*BroadcastMessage    `protobuf:"bytes,1,rep,name=messages,proto3" json:"messages,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SyncResponse) Reset() {
+	*x = SyncResponse{}
+	mi := &file_proto_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SyncResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SyncResponse) ProtoMessage() {}
+
+func (x *SyncResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SyncResponse.ProtoReflect.Descriptor instead.
+func (*SyncResponse) Descriptor() ([]byte, []int) {
+	return file_proto_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *SyncResponse) GetMessages() []*BroadcastMessage {
+	if x != nil {
+		return x.Messages
+	}
+	return nil
+}
+
+// Request message when a client joins
+type JoinRequest struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	ParticipantName string                 `protobuf:"bytes,1,opt,name=participant_name,json=participantName,proto3" json:"participant_name,omitempty"`
+	// replay the history of #general after this Lamport time before the live messages. Every room has its
+	// own clock, so a resumed session in other rooms too has to use since_seq.
+	SinceLamport *int64 `protobuf:"varint,2,opt,name=since_lamport,json=sinceLamport,proto3,oneof" json:"since_lamport,omitempty"`
+	// send the messages with a higher sequence number in the rooms we are in (they are not marked replayed)
+	SinceSeq *uint64 `protobuf:"varint,3,opt,name=since_seq,json=sinceSeq,proto3,oneof" json:"since_seq,omitempty"`
+	// continue the session of a client that lost its stream, without a new join message
+	Resume bool `protobuf:"varint,4,opt,name=resume,proto3" json:"resume,omitempty"`
+	// what the server does when this client can't keep up with the messages (not BLOCK)
+	SlowPolicy    SlowConsumerPolicy `protobuf:"varint,5,opt,name=slow_policy,json=slowPolicy,proto3,enum=SlowConsumerPolicy" json:"slow_policy,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *JoinRequest) Reset() {
+	*x = JoinRequest{}
+	mi := &file_proto_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *JoinRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*JoinRequest) ProtoMessage() {}
+
+func (x *JoinRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use JoinRequest.ProtoReflect.Descriptor instead.
+func (*JoinRequest) Descriptor() ([]byte, []int) {
+	return file_proto_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *JoinRequest) GetParticipantName() string {
+	if x != nil {
+		return x.ParticipantName
+	}
+	return ""
+}
+
+func (x *JoinRequest) GetSinceLamport() int64 {
+	if x != nil && x.SinceLamport != nil {
+		return *x.SinceLamport
+	}
+	return 0
+}
+
+func (x *JoinRequest) GetSinceSeq() uint64 {
+	if x != nil && x.SinceSeq != nil {
+		return *x.SinceSeq
+	}
+	return 0
+}
+
+func (x *JoinRequest) GetResume() bool {
+	if x != nil {
+		return x.Resume
+	}
+	return false
+}
+
+func (x *JoinRequest) GetSlowPolicy() SlowConsumerPolicy {
+	if x != nil {
+		return x.SlowPolicy
+	}
+	return SlowConsumerPolicy_SERVER_DEFAULT
+}
+
+// Message for publishing chat content
+type ChatMessage struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	ParticipantName string                 `protobuf:"bytes,1,opt,name=participant_name,json=participantName,proto3" json:"participant_name,omitempty"`
+	Content         string                 `protobuf:"bytes,2,opt,name=content,proto3" json:"content,omitempty"`
+	Lamport         int64                  `protobuf:"varint,3,opt,name=lamport,proto3" json:"lamport,omitempty"`
+	// the room the message is for (empty means #general)
+	Room string `protobuf:"bytes,4,opt,name=room,proto3" json:"room,omitempty"`
+	// vector timestamp of the sender in that room, participant name -> messages sent
+	VectorClock   map[string]int64 `protobuf:"bytes,5,rep,name=vector_clock,json=vectorClock,proto3" json:"vector_clock,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ChatMessage) Reset() {
+	*x = ChatMessage{}
+	mi := &file_proto_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ChatMessage) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ChatMessage) ProtoMessage() {}
+
+func (x *ChatMessage) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ChatMessage.ProtoReflect.Descriptor instead.
+func (*ChatMessage) Descriptor() ([]byte, []int) {
+	return file_proto_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *ChatMessage) GetParticipantName() string {
+	if x != nil {
+		return x.ParticipantName
+	}
+	return ""
+}
+
+func (x *ChatMessage) GetContent() string {
+	if x != nil {
+		return x.Content
+	}
+	return ""
+}
+
+func (x *ChatMessage) GetLamport() int64 {
+	if x != nil {
+		return x.Lamport
+	}
+	return 0
+}
+
+func (x *ChatMessage) GetRoom() string {
+	if x != nil {
+		return x.Room
+	}
+	return ""
+}
+
+func (x *ChatMessage) GetVectorClock() map[string]int64 {
+	if x != nil {
+		return x.VectorClock
+	}
+	return nil
+}
+
+// A frame of a session stream
+type SessionFrame struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// the client numbers its join, chat and leave frames, the ack of one has the same id
+	Id uint64 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	// Types that are valid to be assigned to Frame:
+	//
+	//	*SessionFrame_Join
+	//	*SessionFrame_Chat
+	//	*SessionFrame_Leave
+	//	*SessionFrame_Ack
+	//	*SessionFrame_Message
+	Frame         isSessionFrame_Frame `protobuf_oneof:"frame"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SessionFrame) Reset() {
+	*x = SessionFrame{}
+	mi := &file_proto_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SessionFrame) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SessionFrame) ProtoMessage() {}
+
+func (x *SessionFrame) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SessionFrame.ProtoReflect.Descriptor instead.
+func (*SessionFrame) Descriptor() ([]byte, []int) {
+	return file_proto_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *SessionFrame) GetId() uint64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *SessionFrame) GetFrame() isSessionFrame_Frame {
+	if x != nil {
+		return x.Frame
+	}
+	return nil
+}
+
+func (x *SessionFrame) GetJoin() *JoinRequest {
+	if x != nil {
+		if x, ok := x.Frame.(*SessionFrame_Join); ok {
+			return x.Join
+		}
+	}
+	return nil
+}
+
+func (x *SessionFrame) GetChat() *ChatMessage {
+	if x != nil {
+		if x, ok := x.Frame.(*SessionFrame_Chat); ok {
+			return x.Chat
+		}
+	}
+	return nil
+}
+
+func (x *SessionFrame) GetLeave() *LeaveRequest {
+	if x != nil {
+		if x, ok := x.Frame.(*SessionFrame_Leave); ok {
+			return x.Leave
+		}
+	}
+	return nil
+}
+
+func (x *SessionFrame) GetAck() *SessionAck {
+	if x != nil {
+		if x, ok := x.Frame.(*SessionFrame_Ack); ok {
+			return x.Ack
+		}
+	}
+	return nil
+}
+
+func (x *SessionFrame) GetMessage() *BroadcastMessage {
+	if x != nil {
+		if x, ok := x.Frame.(*SessionFrame_Message); ok {
+			return x.Message
+		}
+	}
+	return nil
+}
+
+type isSessionFrame_Frame interface {
+	isSessionFrame_Frame()
+}
+
+type SessionFrame_Join struct {
+	// from the client, the first frame has to be the join
+	Join *JoinRequest `protobuf:"bytes,2,opt,name=join,proto3,oneof"`
+}
+
+type SessionFrame_Chat struct {
+	Chat *ChatMessage `protobuf:"bytes,3,opt,name=chat,proto3,oneof"`
+}
+
+type SessionFrame_Leave struct {
+	Leave *LeaveRequest `protobuf:"bytes,4,opt,name=leave,proto3,oneof"`
+}
+
+type SessionFrame_Ack struct {
+	// both ways: the server acks the frames of the client, the client the messages it got
+	Ack *SessionAck `protobuf:"bytes,5,opt,name=ack,proto3,oneof"`
+}
+
+type SessionFrame_Message struct {
+	// from the server
+	Message *BroadcastMessage `protobuf:"bytes,6,opt,name=message,proto3,oneof"`
+}
+
+func (*SessionFrame_Join) isSessionFrame_Frame() {}
+
+func (*SessionFrame_Chat) isSessionFrame_Frame() {}
+
+func (*SessionFrame_Leave) isSessionFrame_Frame() {}
+
+func (*SessionFrame_Ack) isSessionFrame_Frame() {}
+
+func (*SessionFrame_Message) isSessionFrame_Frame() {}
+
+type SessionAck struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// the frame this is the answer to (0 for the acks of the client)
+	Id      uint64 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Success bool   `protobuf:"varint,2,opt,name=success,proto3" json:"success,omitempty"`
+	// chat: the Lamport time of the message
+	LamportTimestamp int64 `protobuf:"varint,3,opt,name=lamport_timestamp,json=lamportTimestamp,proto3" json:"lamport_timestamp,omitempty"`
+	// why the frame was refused
+	Reason string `protobuf:"bytes,4,opt,name=reason,proto3" json:"reason,omitempty"`
+	// join: true if the session was resumed
+	Resumed bool `protobuf:"varint,5,opt,name=resumed,proto3" json:"resumed,omitempty"`
+	// from the client: the sequence number of the last message it got
+	Seq           uint64 `protobuf:"varint,6,opt,name=seq,proto3" json:"seq,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SessionAck) Reset() {
+	*x = SessionAck{}
+	mi := &file_proto_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SessionAck) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SessionAck) ProtoMessage() {}
+
+func (x *SessionAck) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SessionAck.ProtoReflect.Descriptor instead.
+func (*SessionAck) Descriptor() ([]byte, []int) {
+	return file_proto_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *SessionAck) GetId() uint64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *SessionAck) GetSuccess() bool {
+	if x != nil {
+		return x.Success
+	}
+	return false
+}
+
+func (x *SessionAck) GetLamportTimestamp() int64 {
+	if x != nil {
+		return x.LamportTimestamp
+	}
+	return 0
+}
+
+func (x *SessionAck) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+func (x *SessionAck) GetResumed() bool {
+	if x != nil {
+		return x.Resumed
+	}
+	return false
+}
+
+func (x *SessionAck) GetSeq() uint64 {
+	if x != nil {
+		return x.Seq
+	}
+	return 0
+}
+
+// Response after publishing a message
+type PublishResponse struct {
+	state            protoimpl.MessageState `protogen:"open.v1"`
+	Success          bool                   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
+	LamportTimestamp int64                  `protobuf:"varint,2,opt,name=lamport_timestamp,json=lamportTimestamp,proto3" json:"lamport_timestamp,omitempty"`
+	// why the message was refused, e.g. the sender is muted or sends too fast
+	Reason        string `protobuf:"bytes,3,opt,name=reason,proto3" json:"reason,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PublishResponse) Reset() {
+	*x = PublishResponse{}
+	mi := &file_proto_proto_msgTypes[18]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PublishResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PublishResponse) ProtoMessage() {}
+
+func (x *PublishResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_proto_msgTypes[18]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PublishResponse.ProtoReflect.Descriptor instead.
+func (*PublishResponse) Descriptor() ([]byte, []int) {
+	return file_proto_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *PublishResponse) GetSuccess() bool {
+	if x != nil {
+		return x.Success
+	}
+	return false
+}
+
+func (x *PublishResponse) GetLamportTimestamp() int64 {
+	if x != nil {
+		return x.LamportTimestamp
+	}
+	return 0
+}
+
+func (x *PublishResponse) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+type ModerationRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// the admin
+	ParticipantName string           `protobuf:"bytes,1,opt,name=participant_name,json=participantName,proto3" json:"participant_name,omitempty"`
+	Action          ModerationAction `protobuf:"varint,2,opt,name=action,proto3,enum=ModerationAction" json:"action,omitempty"`
+	Target          string           `protobuf:"bytes,3,opt,name=target,proto3" json:"target,omitempty"`
+	DurationSeconds int64            `protobuf:"varint,4,opt,name=duration_seconds,json=durationSeconds,proto3" json:"duration_seconds,omitempty"`
+	Reason          string           `protobuf:"bytes,5,opt,name=reason,proto3" json:"reason,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *ModerationRequest) Reset() {
+	*x = ModerationRequest{}
+	mi := &file_proto_proto_msgTypes[19]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ModerationRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ModerationRequest) ProtoMessage() {}
+
+func (x *ModerationRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_proto_msgTypes[19]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ModerationRequest.ProtoReflect.Descriptor instead.
+func (*ModerationRequest) Descriptor() ([]byte, []int) {
+	return file_proto_proto_rawDescGZIP(), []int{19}
+}
+
+func (x *ModerationRequest) GetParticipantName() string {
+	if x != nil {
+		return x.ParticipantName
+	}
+	return ""
+}
+
+func (x *ModerationRequest) GetAction() ModerationAction {
+	if x != nil {
+		return x.Action
+	}
+	return ModerationAction_KICK
+}
+
+func (x *ModerationRequest) GetTarget() string {
+	if x != nil {
+		return x.Target
+	}
+	return ""
+}
+
+func (x *ModerationRequest) GetDurationSeconds() int64 {
+	if x != nil {
+		return x.DurationSeconds
+	}
+	return 0
+}
+
+func (x *ModerationRequest) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+type ModerationResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Success       bool                   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ModerationResponse) Reset() {
+	*x = ModerationResponse{}
+	mi := &file_proto_proto_msgTypes[20]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ModerationResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ModerationResponse) ProtoMessage() {}
+
+func (x *ModerationResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_proto_msgTypes[20]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ModerationResponse.ProtoReflect.Descriptor instead.
+func (*ModerationResponse) Descriptor() ([]byte, []int) {
+	return file_proto_proto_rawDescGZIP(), []int{20}
+}
+
+func (x *ModerationResponse) GetSuccess() bool {
+	if x != nil {
+		return x.Success
+	}
+	return false
+}
+
+// Request message when a client leaves
+type LeaveRequest struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	ParticipantName string                 `protobuf:"bytes,1,opt,name=participant_name,json=participantName,proto3" json:"participant_name,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *LeaveRequest) Reset() {
+	*x = LeaveRequest{}
+	mi := &file_proto_proto_msgTypes[21]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LeaveRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LeaveRequest) ProtoMessage() {}
+
+func (x *LeaveRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_proto_msgTypes[21]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LeaveRequest.ProtoReflect.Descriptor instead.
+func (*LeaveRequest) Descriptor() ([]byte, []int) {
+	return file_proto_proto_rawDescGZIP(), []int{21}
+}
+
+func (x *LeaveRequest) GetParticipantName() string {
+	if x != nil {
+		return x.ParticipantName
+	}
+	return ""
+}
+
+// Response after leaving
+type LeaveResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Success       bool                   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LeaveResponse) Reset() {
+	*x = LeaveResponse{}
+	mi := &file_proto_proto_msgTypes[22]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LeaveResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LeaveResponse) ProtoMessage() {}
+
+func (x *LeaveResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_proto_msgTypes[22]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LeaveResponse.ProtoReflect.Descriptor instead.
+func (*LeaveResponse) Descriptor() ([]byte, []int) {
+	return file_proto_proto_rawDescGZIP(), []int{22}
+}
+
+func (x *LeaveResponse) GetSuccess() bool {
+	if x != nil {
+		return x.Success
+	}
+	return false
+}
+
+// Broadcast message sent to all clients
+type BroadcastMessage struct {
+	state            protoimpl.MessageState `protogen:"open.v1"`
+	Content          string                 `protobuf:"bytes,1,opt,name=content,proto3" json:"content,omitempty"`
+	LamportTimestamp int64                  `protobuf:"varint,2,opt,name=lamport_timestamp,json=lamportTimestamp,proto3" json:"lamport_timestamp,omitempty"`
+	Type             MessageType            `protobuf:"varint,3,opt,name=type,proto3,enum=MessageType" json:"type,omitempty"`
+	// true if the message comes from the history and not live
+	Replayed bool `protobuf:"varint,4,opt,name=replayed,proto3" json:"replayed,omitempty"`
+	// the room the message was sent in, every room has its own Lamport clock
+	Room string `protobuf:"bytes,5,opt,name=room,proto3" json:"room,omitempty"`
+	// chat messages: the sender's vector timestamp. Join and leave messages: every chat
+	// message of the room that was broadcast before them
+	VectorClock map[string]int64 `protobuf:"bytes,6,rep,name=vector_clock,json=vectorClock,proto3" json:"vector_clock,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"`
+	// who wrote a chat message (empty for messages from the server)
+	Sender string `protobuf:"bytes,7,opt,name=sender,proto3" json:"sender,omitempty"`
+	// the server numbers every broadcast message, so a client knows what it missed (0 for private messages)
+	Seq uint64 `protobuf:"varint,8,opt,name=seq,proto3" json:"seq,omitempty"`
+	// the server the message was first broadcast on, and its sequence number there
+	Origin        string `protobuf:"bytes,9,opt,name=origin,proto3" json:"origin,omitempty"`
+	OriginSeq     uint64 `protobuf:"varint,10,opt,name=origin_seq,json=originSeq,proto3" json:"origin_seq,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BroadcastMessage) Reset() {
+	*x = BroadcastMessage{}
+	mi := &file_proto_proto_msgTypes[23]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BroadcastMessage) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BroadcastMessage) ProtoMessage() {}
+
+func (x *BroadcastMessage) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_proto_msgTypes[23]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BroadcastMessage.ProtoReflect.Descriptor instead.
+func (*BroadcastMessage) Descriptor() ([]byte, []int) {
+	return file_proto_proto_rawDescGZIP(), []int{23}
+}
+
+func (x *BroadcastMessage) GetContent() string {
+	if x != nil {
+		return x.Content
+	}
+	return ""
+}
+
+func (x *BroadcastMessage) GetLamportTimestamp() int64 {
+	if x != nil {
+		return x.LamportTimestamp
+	}
+	return 0
+}
+
+func (x *BroadcastMessage) GetType() MessageType {
+	if x != nil {
+		return x.Type
+	}
+	return MessageType_CHAT
+}
+
+func (x *BroadcastMessage) GetReplayed() bool {
+	if x != nil {
+		return x.Replayed
+	}
+	return false
+}
+
+func (x *BroadcastMessage) GetRoom() string {
+	if x != nil {
+		return x.Room
+	}
+	return ""
+}
+
+func (x *BroadcastMessage) GetVectorClock() map[string]int64 {
+	if x != nil {
+		return x.VectorClock
+	}
+	return nil
+}
+
+func (x *BroadcastMessage) GetSender() string {
+	if x != nil {
+		return x.Sender
+	}
+	return ""
+}
+
+func (x *BroadcastMessage) GetSeq() uint64 {
+	if x != nil {
+		return x.Seq
+	}
+	return 0
+}
+
+func (x *BroadcastMessage) GetOrigin() string {
+	if x != nil {
+		return x.Origin
+	}
+	return ""
+}
+
+func (x *BroadcastMessage) GetOriginSeq() uint64 {
+	if x != nil {
+		return x.OriginSeq
+	}
+	return 0
+}
+
+// A message relayed between servers
+type RelayMessage struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Message *BroadcastMessage      `protobuf:"bytes,1,opt,name=message,proto3" json:"message,omitempty"`
+	// the servers it went through, it is not sent back to them
+	Path          []string `protobuf:"bytes,2,rep,name=path,proto3" json:"path,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RelayMessage) Reset() {
+	*x = RelayMessage{}
+	mi := &file_proto_proto_msgTypes[24]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RelayMessage) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RelayMessage) ProtoMessage() {}
+
+func (x *RelayMessage) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_proto_msgTypes[24]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RelayMessage.ProtoReflect.Descriptor instead.
+func (*RelayMessage) Descriptor() ([]byte, []int) {
+	return file_proto_proto_rawDescGZIP(), []int{24}
+}
+
+func (x *RelayMessage) GetMessage() *BroadcastMessage {
+	if x != nil {
+		return x.Message
+	}
+	return nil
+}
+
+func (x *RelayMessage) GetPath() []string {
+	if x != nil {
+		return x.Path
+	}
+	return nil
+}
+
+type RelayResponse struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Success bool                   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
+	// true if the server already had the message
+	Duplicate     bool `protobuf:"varint,2,opt,name=duplicate,proto3" json:"duplicate,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RelayResponse) Reset() {
+	*x = RelayResponse{}
+	mi := &file_proto_proto_msgTypes[25]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RelayResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RelayResponse) ProtoMessage() {}
+
+func (x *RelayResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_proto_msgTypes[25]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RelayResponse.ProtoReflect.Descriptor instead.
+func (*RelayResponse) Descriptor() ([]byte, []int) {
+	return file_proto_proto_rawDescGZIP(), []int{25}
+}
+
+func (x *RelayResponse) GetSuccess() bool {
+	if x != nil {
+		return x.Success
+	}
+	return false
+}
+
+func (x *RelayResponse) GetDuplicate() bool {
+	if x != nil {
+		return x.Duplicate
+	}
+	return false
+}
+
+// A message for one participant
+type PrivateMessage struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	ParticipantName string                 `protobuf:"bytes,1,opt,name=participant_name,json=participantName,proto3" json:"participant_name,omitempty"`
+	Recipient       string                 `protobuf:"bytes,2,opt,name=recipient,proto3" json:"recipient,omitempty"`
+	Content         string                 `protobuf:"bytes,3,opt,name=content,proto3" json:"content,omitempty"`
+	Lamport         int64                  `protobuf:"varint,4,opt,name=lamport,proto3" json:"lamport,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *PrivateMessage) Reset() {
+	*x = PrivateMessage{}
+	mi := &file_proto_proto_msgTypes[26]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PrivateMessage) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PrivateMessage) ProtoMessage() {}
+
+func (x *PrivateMessage) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_proto_msgTypes[26]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PrivateMessage.ProtoReflect.Descriptor instead.
+func (*PrivateMessage) Descriptor() ([]byte, []int) {
+	return file_proto_proto_rawDescGZIP(), []int{26}
+}
+
+func (x *PrivateMessage) GetParticipantName() string {
+	if x != nil {
+		return x.ParticipantName
+	}
+	return ""
+}
+
+func (x *PrivateMessage) GetRecipient() string {
+	if x != nil {
+		return x.Recipient
+	}
+	return ""
+}
+
+func (x *PrivateMessage) GetContent() string {
+	if x != nil {
+		return x.Content
+	}
+	return ""
+}
+
+func (x *PrivateMessage) GetLamport() int64 {
+	if x != nil {
+		return x.Lamport
+	}
+	return 0
+}
+
+// Response to a private message, success means it was handed to the recipient's stream
+type PrivateResponse struct {
+	state            protoimpl.MessageState `protogen:"open.v1"`
+	Success          bool                   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
+	Recipient        string                 `protobuf:"bytes,2,opt,name=recipient,proto3" json:"recipient,omitempty"`
+	LamportTimestamp int64                  `protobuf:"varint,3,opt,name=lamport_timestamp,json=lamportTimestamp,proto3" json:"lamport_timestamp,omitempty"`
+	unknownFields    protoimpl.UnknownFields
+	sizeCache        protoimpl.SizeCache
+}
+
+func (x *PrivateResponse) Reset() {
+	*x = PrivateResponse{}
+	mi := &file_proto_proto_msgTypes[27]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PrivateResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PrivateResponse) ProtoMessage() {}
+
+func (x *PrivateResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_proto_msgTypes[27]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PrivateResponse.ProtoReflect.Descriptor instead.
+func (*PrivateResponse) Descriptor() ([]byte, []int) {
+	return file_proto_proto_rawDescGZIP(), []int{27}
+}
+
+func (x *PrivateResponse) GetSuccess() bool {
+	if x != nil {
+		return x.Success
+	}
+	return false
+}
+
+func (x *PrivateResponse) GetRecipient() string {
+	if x != nil {
+		return x.Recipient
+	}
+	return ""
+}
+
+func (x *PrivateResponse) GetLamportTimestamp() int64 {
+	if x != nil {
+		return x.LamportTimestamp
+	}
+	return 0
+}
+
+// Request to create, join or leave a room
+type RoomRequest struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	ParticipantName string                 `protobuf:"bytes,1,opt,name=participant_name,json=participantName,proto3" json:"participant_name,omitempty"`
+	Room            string                 `protobuf:"bytes,2,opt,name=room,proto3" json:"room,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *RoomRequest) Reset() {
+	*x = RoomRequest{}
+	mi := &file_proto_proto_msgTypes[28]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RoomRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RoomRequest) ProtoMessage() {}
+
+func (x *RoomRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_proto_msgTypes[28]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RoomRequest.ProtoReflect.Descriptor instead.
+func (*RoomRequest) Descriptor() ([]byte, []int) {
+	return file_proto_proto_rawDescGZIP(), []int{28}
+}
+
+func (x *RoomRequest) GetParticipantName() string {
+	if x != nil {
+		return x.ParticipantName
+	}
+	return ""
+}
+
+func (x *RoomRequest) GetRoom() string {
+	if x != nil {
+		return x.Room
+	}
+	return ""
+}
+
+// Response to a room request
+type RoomResponse struct {
+	state            protoimpl.MessageState `protogen:"open.v1"`
+	Success          bool                   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
+	Room             string                 `protobuf:"bytes,2,opt,name=room,proto3" json:"room,omitempty"`
+	LamportTimestamp int64                  `protobuf:"varint,3,opt,name=lamport_timestamp,json=lamportTimestamp,proto3" json:"lamport_timestamp,omitempty"`
+	unknownFields    protoimpl.UnknownFields
+	sizeCache        protoimpl.SizeCache
+}
+
+func (x *RoomResponse) Reset() {
+	*x = RoomResponse{}
+	mi := &file_proto_proto_msgTypes[29]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RoomResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RoomResponse) ProtoMessage() {}
+
+func (x *RoomResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_proto_msgTypes[29]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RoomResponse.ProtoReflect.Descriptor instead.
+func (*RoomResponse) Descriptor() ([]byte, []int) {
+	return file_proto_proto_rawDescGZIP(), []int{29}
+}
+
+func (x *RoomResponse) GetSuccess() bool {
+	if x != nil {
+		return x.Success
+	}
+	return false
+}
+
+func (x *RoomResponse) GetRoom() string {
+	if x != nil {
+		return x.Room
+	}
+	return ""
+}
+
+func (x *RoomResponse) GetLamportTimestamp() int64 {
+	if x != nil {
+		return x.LamportTimestamp
+	}
+	return 0
+}
+
+type ListRoomsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListRoomsRequest) Reset() {
+	*x = ListRoomsRequest{}
+	mi := &file_proto_proto_msgTypes[30]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListRoomsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListRoomsRequest) ProtoMessage() {}
+
+func (x *ListRoomsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_proto_msgTypes[30]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListRoomsRequest.ProtoReflect.Descriptor instead.
+func (*ListRoomsRequest) Descriptor() ([]byte, []int) {
+	return file_proto_proto_rawDescGZIP(), []int{30}
+}
+
+type ListRoomsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Rooms         []*RoomInfo            `protobuf:"bytes,1,rep,name=rooms,proto3" json:"rooms,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListRoomsResponse) Reset() {
+	*x = ListRoomsResponse{}
+	mi := &file_proto_proto_msgTypes[31]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListRoomsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListRoomsResponse) ProtoMessage() {}
+
+func (x *ListRoomsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_proto_msgTypes[31]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
 }
 
-func (x MessageType) Number() protoreflect.EnumNumber {
-	return protoreflect.EnumNumber(x)
+// Deprecated: Use ListRoomsResponse.ProtoReflect.Descriptor instead.
+func (*ListRoomsResponse) Descriptor() ([]byte, []int) {
+	return file_proto_proto_rawDescGZIP(), []int{31}
 }
 
-// Deprecated: Use MessageType.Descriptor instead.
-func (MessageType) EnumDescriptor() ([]byte, []int) {
-	return file_proto_proto_rawDescGZIP(), []int{0}
+func (x *ListRoomsResponse) GetRooms() []*RoomInfo {
+	if x != nil {
+		return x.Rooms
+	}
+	return nil
 }
 
-// Request message when a client joins
-type JoinRequest struct {
-	state           protoimpl.MessageState `protogen:"open.v1"`
-	ParticipantName string                 `protobuf:"bytes,1,opt,name=participant_name,json=participantName,proto3" json:"participant_name,omitempty"`
-	unknownFields   protoimpl.UnknownFields
-	sizeCache       protoimpl.SizeCache
+// A room and how many participants are in it
+type RoomInfo struct {
+	state            protoimpl.MessageState `protogen:"open.v1"`
+	Name             string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Members          int32                  `protobuf:"varint,2,opt,name=members,proto3" json:"members,omitempty"`
+	LamportTimestamp int64                  `protobuf:"varint,3,opt,name=lamport_timestamp,json=lamportTimestamp,proto3" json:"lamport_timestamp,omitempty"`
+	unknownFields    protoimpl.UnknownFields
+	sizeCache        protoimpl.SizeCache
 }
 
-func (x *JoinRequest) Reset() {
-	*x = JoinRequest{}
-	mi := &file_proto_proto_msgTypes[0]
+func (x *RoomInfo) Reset() {
+	*x = RoomInfo{}
+	mi := &file_proto_proto_msgTypes[32]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *JoinRequest) String() string {
+func (x *RoomInfo) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*JoinRequest) ProtoMessage() {}
+func (*RoomInfo) ProtoMessage() {}
 
-func (x *JoinRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_proto_msgTypes[0]
+func (x *RoomInfo) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_proto_msgTypes[32]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -104,43 +2513,53 @@ func (x *JoinRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use JoinRequest.ProtoReflect.Descriptor instead.
-func (*JoinRequest) Descriptor() ([]byte, []int) {
-	return file_proto_proto_rawDescGZIP(), []int{0}
+// Deprecated: Use RoomInfo.ProtoReflect.Descriptor instead.
+func (*RoomInfo) Descriptor() ([]byte, []int) {
+	return file_proto_proto_rawDescGZIP(), []int{32}
 }
 
-func (x *JoinRequest) GetParticipantName() string {
+func (x *RoomInfo) GetName() string {
 	if x != nil {
-		return x.ParticipantName
+		return x.Name
 	}
 	return ""
 }
 
-// Message for publishing chat content
-type ChatMessage struct {
-	state           protoimpl.MessageState `protogen:"open.v1"`
-	ParticipantName string                 `protobuf:"bytes,1,opt,name=participant_name,json=participantName,proto3" json:"participant_name,omitempty"`
-	Content         string                 `protobuf:"bytes,2,opt,name=content,proto3" json:"content,omitempty"`
-	Lamport         int64                  `protobuf:"varint,3,opt,name=lamport,proto3" json:"lamport,omitempty"`
-	unknownFields   protoimpl.UnknownFields
-	sizeCache       protoimpl.SizeCache
+func (x *RoomInfo) GetMembers() int32 {
+	if x != nil {
+		return x.Members
+	}
+	return 0
 }
 
-func (x *ChatMessage) Reset() {
-	*x = ChatMessage{}
-	mi := &file_proto_proto_msgTypes[1]
+func (x *RoomInfo) GetLamportTimestamp() int64 {
+	if x != nil {
+		return x.LamportTimestamp
+	}
+	return 0
+}
+
+type ListParticipantsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListParticipantsRequest) Reset() {
+	*x = ListParticipantsRequest{}
+	mi := &file_proto_proto_msgTypes[33]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *ChatMessage) String() string {
+func (x *ListParticipantsRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*ChatMessage) ProtoMessage() {}
+func (*ListParticipantsRequest) ProtoMessage() {}
 
-func (x *ChatMessage) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_proto_msgTypes[1]
+func (x *ListParticipantsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_proto_msgTypes[33]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -151,56 +2570,81 @@ func (x *ChatMessage) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use ChatMessage.ProtoReflect.Descriptor instead.
-func (*ChatMessage) Descriptor() ([]byte, []int) {
-	return file_proto_proto_rawDescGZIP(), []int{1}
+// Deprecated: Use ListParticipantsRequest.ProtoReflect.Descriptor instead.
+func (*ListParticipantsRequest) Descriptor() ([]byte, []int) {
+	return file_proto_proto_rawDescGZIP(), []int{33}
 }
 
-func (x *ChatMessage) GetParticipantName() string {
-	if x != nil {
-		return x.ParticipantName
-	}
-	return ""
+type ListParticipantsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Participants  []*ParticipantInfo     `protobuf:"bytes,1,rep,name=participants,proto3" json:"participants,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
 }
 
-func (x *ChatMessage) GetContent() string {
+func (x *ListParticipantsResponse) Reset() {
+	*x = ListParticipantsResponse{}
+	mi := &file_proto_proto_msgTypes[34]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListParticipantsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListParticipantsResponse) ProtoMessage() {}
+
+func (x *ListParticipantsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_proto_msgTypes[34]
 	if x != nil {
-		return x.Content
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
 	}
-	return ""
+	return mi.MessageOf(x)
 }
 
-func (x *ChatMessage) GetLamport() int64 {
+// Deprecated: Use ListParticipantsResponse.ProtoReflect.Descriptor instead.
+func (*ListParticipantsResponse) Descriptor() ([]byte, []int) {
+	return file_proto_proto_rawDescGZIP(), []int{34}
+}
+
+func (x *ListParticipantsResponse) GetParticipants() []*ParticipantInfo {
 	if x != nil {
-		return x.Lamport
+		return x.Participants
 	}
-	return 0
+	return nil
 }
 
-// Response after publishing a message
-type PublishResponse struct {
-	state            protoimpl.MessageState `protogen:"open.v1"`
-	Success          bool                   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
-	LamportTimestamp int64                  `protobuf:"varint,2,opt,name=lamport_timestamp,json=lamportTimestamp,proto3" json:"lamport_timestamp,omitempty"`
-	unknownFields    protoimpl.UnknownFields
-	sizeCache        protoimpl.SizeCache
+// A participant that is connected, or may still resume
+type ParticipantInfo struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Status        PresenceStatus         `protobuf:"varint,2,opt,name=status,proto3,enum=PresenceStatus" json:"status,omitempty"`
+	Typing        bool                   `protobuf:"varint,3,opt,name=typing,proto3" json:"typing,omitempty"`
+	Rooms         []string               `protobuf:"bytes,4,rep,name=rooms,proto3" json:"rooms,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
 }
 
-func (x *PublishResponse) Reset() {
-	*x = PublishResponse{}
-	mi := &file_proto_proto_msgTypes[2]
+func (x *ParticipantInfo) Reset() {
+	*x = ParticipantInfo{}
+	mi := &file_proto_proto_msgTypes[35]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *PublishResponse) String() string {
+func (x *ParticipantInfo) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*PublishResponse) ProtoMessage() {}
+func (*ParticipantInfo) ProtoMessage() {}
 
-func (x *PublishResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_proto_msgTypes[2]
+func (x *ParticipantInfo) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_proto_msgTypes[35]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -211,48 +2655,61 @@ func (x *PublishResponse) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use PublishResponse.ProtoReflect.Descriptor instead.
-func (*PublishResponse) Descriptor() ([]byte, []int) {
-	return file_proto_proto_rawDescGZIP(), []int{2}
+// Deprecated: Use ParticipantInfo.ProtoReflect.Descriptor instead.
+func (*ParticipantInfo) Descriptor() ([]byte, []int) {
+	return file_proto_proto_rawDescGZIP(), []int{35}
 }
 
-func (x *PublishResponse) GetSuccess() bool {
+func (x *ParticipantInfo) GetName() string {
 	if x != nil {
-		return x.Success
+		return x.Name
+	}
+	return ""
+}
+
+func (x *ParticipantInfo) GetStatus() PresenceStatus {
+	if x != nil {
+		return x.Status
+	}
+	return PresenceStatus_OFFLINE
+}
+
+func (x *ParticipantInfo) GetTyping() bool {
+	if x != nil {
+		return x.Typing
 	}
 	return false
 }
 
-func (x *PublishResponse) GetLamportTimestamp() int64 {
+func (x *ParticipantInfo) GetRooms() []string {
 	if x != nil {
-		return x.LamportTimestamp
+		return x.Rooms
 	}
-	return 0
+	return nil
 }
 
-// Request message when a client leaves
-type LeaveRequest struct {
+type PresenceRequest struct {
 	state           protoimpl.MessageState `protogen:"open.v1"`
 	ParticipantName string                 `protobuf:"bytes,1,opt,name=participant_name,json=participantName,proto3" json:"participant_name,omitempty"`
 	unknownFields   protoimpl.UnknownFields
 	sizeCache       protoimpl.SizeCache
 }
 
-func (x *LeaveRequest) Reset() {
-	*x = LeaveRequest{}
-	mi := &file_proto_proto_msgTypes[3]
+func (x *PresenceRequest) Reset() {
+	*x = PresenceRequest{}
+	mi := &file_proto_proto_msgTypes[36]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *LeaveRequest) String() string {
+func (x *PresenceRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*LeaveRequest) ProtoMessage() {}
+func (*PresenceRequest) ProtoMessage() {}
 
-func (x *LeaveRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_proto_msgTypes[3]
+func (x *PresenceRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_proto_msgTypes[36]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -263,41 +2720,45 @@ func (x *LeaveRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use LeaveRequest.ProtoReflect.Descriptor instead.
-func (*LeaveRequest) Descriptor() ([]byte, []int) {
-	return file_proto_proto_rawDescGZIP(), []int{3}
+// Deprecated: Use PresenceRequest.ProtoReflect.Descriptor instead.
+func (*PresenceRequest) Descriptor() ([]byte, []int) {
+	return file_proto_proto_rawDescGZIP(), []int{36}
 }
 
-func (x *LeaveRequest) GetParticipantName() string {
+func (x *PresenceRequest) GetParticipantName() string {
 	if x != nil {
 		return x.ParticipantName
 	}
 	return ""
 }
 
-// Response after leaving
-type LeaveResponse struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Success       bool                   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
+// A participant changed its status, or started or stopped typing
+type PresenceEvent struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	ParticipantName string                 `protobuf:"bytes,1,opt,name=participant_name,json=participantName,proto3" json:"participant_name,omitempty"`
+	Status          PresenceStatus         `protobuf:"varint,2,opt,name=status,proto3,enum=PresenceStatus" json:"status,omitempty"`
+	Typing          bool                   `protobuf:"varint,3,opt,name=typing,proto3" json:"typing,omitempty"`
+	// the room the participant is typing in
+	Room          string `protobuf:"bytes,4,opt,name=room,proto3" json:"room,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *LeaveResponse) Reset() {
-	*x = LeaveResponse{}
-	mi := &file_proto_proto_msgTypes[4]
+func (x *PresenceEvent) Reset() {
+	*x = PresenceEvent{}
+	mi := &file_proto_proto_msgTypes[37]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *LeaveResponse) String() string {
+func (x *PresenceEvent) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*LeaveResponse) ProtoMessage() {}
+func (*PresenceEvent) ProtoMessage() {}
 
-func (x *LeaveResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_proto_msgTypes[4]
+func (x *PresenceEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_proto_msgTypes[37]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -308,43 +2769,63 @@ func (x *LeaveResponse) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use LeaveResponse.ProtoReflect.Descriptor instead.
-func (*LeaveResponse) Descriptor() ([]byte, []int) {
-	return file_proto_proto_rawDescGZIP(), []int{4}
+// Deprecated: Use PresenceEvent.ProtoReflect.Descriptor instead.
+func (*PresenceEvent) Descriptor() ([]byte, []int) {
+	return file_proto_proto_rawDescGZIP(), []int{37}
 }
 
-func (x *LeaveResponse) GetSuccess() bool {
+func (x *PresenceEvent) GetParticipantName() string {
 	if x != nil {
-		return x.Success
+		return x.ParticipantName
+	}
+	return ""
+}
+
+func (x *PresenceEvent) GetStatus() PresenceStatus {
+	if x != nil {
+		return x.Status
+	}
+	return PresenceStatus_OFFLINE
+}
+
+func (x *PresenceEvent) GetTyping() bool {
+	if x != nil {
+		return x.Typing
 	}
 	return false
 }
 
-// Broadcast message sent to all clients
-type BroadcastMessage struct {
-	state            protoimpl.MessageState `protogen:"open.v1"`
-	Content          string                 `protobuf:"bytes,1,opt,name=content,proto3" json:"content,omitempty"`
-	LamportTimestamp int64                  `protobuf:"varint,2,opt,name=lamport_timestamp,json=lamportTimestamp,proto3" json:"lamport_timestamp,omitempty"`
-	Type             MessageType            `protobuf:"varint,3,opt,name=type,proto3,enum=MessageType" json:"type,omitempty"`
-	unknownFields    protoimpl.UnknownFields
-	sizeCache        protoimpl.SizeCache
+func (x *PresenceEvent) GetRoom() string {
+	if x != nil {
+		return x.Room
+	}
+	return ""
 }
 
-func (x *BroadcastMessage) Reset() {
-	*x = BroadcastMessage{}
-	mi := &file_proto_proto_msgTypes[5]
+type HeartbeatRequest struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	ParticipantName string                 `protobuf:"bytes,1,opt,name=participant_name,json=participantName,proto3" json:"participant_name,omitempty"`
+	Typing          bool                   `protobuf:"varint,2,opt,name=typing,proto3" json:"typing,omitempty"`
+	Room            string                 `protobuf:"bytes,3,opt,name=room,proto3" json:"room,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *HeartbeatRequest) Reset() {
+	*x = HeartbeatRequest{}
+	mi := &file_proto_proto_msgTypes[38]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *BroadcastMessage) String() string {
+func (x *HeartbeatRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*BroadcastMessage) ProtoMessage() {}
+func (*HeartbeatRequest) ProtoMessage() {}
 
-func (x *BroadcastMessage) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_proto_msgTypes[5]
+func (x *HeartbeatRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_proto_msgTypes[38]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -355,62 +2836,327 @@ func (x *BroadcastMessage) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use BroadcastMessage.ProtoReflect.Descriptor instead.
-func (*BroadcastMessage) Descriptor() ([]byte, []int) {
-	return file_proto_proto_rawDescGZIP(), []int{5}
+// Deprecated: Use HeartbeatRequest.ProtoReflect.Descriptor instead.
+func (*HeartbeatRequest) Descriptor() ([]byte, []int) {
+	return file_proto_proto_rawDescGZIP(), []int{38}
 }
 
-func (x *BroadcastMessage) GetContent() string {
+func (x *HeartbeatRequest) GetParticipantName() string {
 	if x != nil {
-		return x.Content
+		return x.ParticipantName
 	}
 	return ""
 }
 
-func (x *BroadcastMessage) GetLamportTimestamp() int64 {
+func (x *HeartbeatRequest) GetTyping() bool {
 	if x != nil {
-		return x.LamportTimestamp
+		return x.Typing
 	}
-	return 0
+	return false
 }
 
-func (x *BroadcastMessage) GetType() MessageType {
+func (x *HeartbeatRequest) GetRoom() string {
 	if x != nil {
-		return x.Type
+		return x.Room
 	}
-	return MessageType_CHAT
+	return ""
+}
+
+type HeartbeatResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Success       bool                   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *HeartbeatResponse) Reset() {
+	*x = HeartbeatResponse{}
+	mi := &file_proto_proto_msgTypes[39]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *HeartbeatResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HeartbeatResponse) ProtoMessage() {}
+
+func (x *HeartbeatResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_proto_msgTypes[39]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HeartbeatResponse.ProtoReflect.Descriptor instead.
+func (*HeartbeatResponse) Descriptor() ([]byte, []int) {
+	return file_proto_proto_rawDescGZIP(), []int{39}
+}
+
+func (x *HeartbeatResponse) GetSuccess() bool {
+	if x != nil {
+		return x.Success
+	}
+	return false
 }
 
 var File_proto_proto protoreflect.FileDescriptor
 
 const file_proto_proto_rawDesc = "" +
 	"\n" +
-	"\vproto.proto\"8\n" +
+	"\vproto.proto\"T\n" +
+	"\vcredentials\x12)\n" +
+	"\x10participant_name\x18\x01 \x01(\tR\x0fparticipantName\x12\x1a\n" +
+	"\bpassword\x18\x02 \x01(\tR\bpassword\"?\n" +
+	"\rloginResponse\x12\x18\n" +
+	"\asuccess\x18\x01 \x01(\bR\asuccess\x12\x14\n" +
+	"\x05token\x18\x02 \x01(\tR\x05token\"\xc1\x01\n" +
+	"\traftEntry\x12\x12\n" +
+	"\x04term\x18\x01 \x01(\x03R\x04term\x12+\n" +
+	"\amessage\x18\x02 \x01(\v2\x11.broadcastMessageR\amessage\x12(\n" +
+	"\aaccount\x18\x03 \x01(\v2\x0e.accountChangeR\aaccount\x12\x1f\n" +
+	"\x04room\x18\x04 \x01(\v2\v.roomChangeR\x04room\x12(\n" +
+	"\asession\x18\x05 \x01(\v2\x0e.sessionChangeR\asession\"p\n" +
+	"\n" +
+	"roomChange\x12#\n" +
+	"\x04type\x18\x01 \x01(\x0e2\x0f.roomChangeTypeR\x04type\x12\x12\n" +
+	"\x04room\x18\x02 \x01(\tR\x04room\x12)\n" +
+	"\x10participant_name\x18\x03 \x01(\tR\x0fparticipantName\"\x81\x01\n" +
+	"\rsessionChange\x12&\n" +
+	"\x04type\x18\x01 \x01(\x0e2\x12.sessionChangeTypeR\x04type\x12)\n" +
+	"\x10participant_name\x18\x02 \x01(\tR\x0fparticipantName\x12\x1d\n" +
+	"\n" +
+	"token_hash\x18\x03 \x01(\tR\ttokenHash\"\xab\x01\n" +
+	"\raccountChange\x12&\n" +
+	"\x04type\x18\x01 \x01(\x0e2\x12.accountChangeTypeR\x04type\x12)\n" +
+	"\x10participant_name\x18\x02 \x01(\tR\x0fparticipantName\x12\x12\n" +
+	"\x04salt\x18\x03 \x01(\tR\x04salt\x12\x12\n" +
+	"\x04hash\x18\x04 \x01(\tR\x04hash\x12\x1f\n" +
+	"\vmuted_until\x18\x05 \x01(\x03R\n" +
+	"mutedUntil\"\x89\x01\n" +
+	"\vvoteRequest\x12\x12\n" +
+	"\x04term\x18\x01 \x01(\x03R\x04term\x12\x1c\n" +
+	"\tcandidate\x18\x02 \x01(\tR\tcandidate\x12$\n" +
+	"\x0elast_log_index\x18\x03 \x01(\x03R\flastLogIndex\x12\"\n" +
+	"\rlast_log_term\x18\x04 \x01(\x03R\vlastLogTerm\"<\n" +
+	"\fvoteResponse\x12\x12\n" +
+	"\x04term\x18\x01 \x01(\x03R\x04term\x12\x18\n" +
+	"\agranted\x18\x02 \x01(\bR\agranted\"\xd0\x01\n" +
+	"\rappendRequest\x12\x12\n" +
+	"\x04term\x18\x01 \x01(\x03R\x04term\x12\x16\n" +
+	"\x06leader\x18\x02 \x01(\tR\x06leader\x12$\n" +
+	"\x0eprev_log_index\x18\x03 \x01(\x03R\fprevLogIndex\x12\"\n" +
+	"\rprev_log_term\x18\x04 \x01(\x03R\vprevLogTerm\x12$\n" +
+	"\aentries\x18\x05 \x03(\v2\n" +
+	".raftEntryR\aentries\x12#\n" +
+	"\rleader_commit\x18\x06 \x01(\x03R\fleaderCommit\"e\n" +
+	"\x0eappendResponse\x12\x12\n" +
+	"\x04term\x18\x01 \x01(\x03R\x04term\x12\x18\n" +
+	"\asuccess\x18\x02 \x01(\bR\asuccess\x12%\n" +
+	"\x0econflict_index\x18\x03 \x01(\x03R\rconflictIndex\"h\n" +
+	"\rgossipRequest\x12\x12\n" +
+	"\x04from\x18\x01 \x01(\tR\x04from\x12-\n" +
+	"\bmessages\x18\x02 \x03(\v2\x11.broadcastMessageR\bmessages\x12\x14\n" +
+	"\x05peers\x18\x03 \x03(\tR\x05peers\"&\n" +
+	"\x0egossipResponse\x12\x14\n" +
+	"\x05peers\x18\x01 \x03(\tR\x05peers\"\x86\x01\n" +
+	"\vsyncRequest\x12\x12\n" +
+	"\x04from\x18\x01 \x01(\tR\x04from\x12*\n" +
+	"\x04have\x18\x02 \x03(\v2\x16.syncRequest.HaveEntryR\x04have\x1a7\n" +
+	"\tHaveEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\x04R\x05value:\x028\x01\"=\n" +
+	"\fsyncResponse\x12-\n" +
+	"\bmessages\x18\x01 \x03(\v2\x11.broadcastMessageR\bmessages\"\xf2\x01\n" +
 	"\vjoinRequest\x12)\n" +
-	"\x10participant_name\x18\x01 \x01(\tR\x0fparticipantName\"l\n" +
+	"\x10participant_name\x18\x01 \x01(\tR\x0fparticipantName\x12(\n" +
+	"\rsince_lamport\x18\x02 \x01(\x03H\x00R\fsinceLamport\x88\x01\x01\x12 \n" +
+	"\tsince_seq\x18\x03 \x01(\x04H\x01R\bsinceSeq\x88\x01\x01\x12\x16\n" +
+	"\x06resume\x18\x04 \x01(\bR\x06resume\x124\n" +
+	"\vslow_policy\x18\x05 \x01(\x0e2\x13.slowConsumerPolicyR\n" +
+	"slowPolicyB\x10\n" +
+	"\x0e_since_lamportB\f\n" +
+	"\n" +
+	"_since_seq\"\x82\x02\n" +
 	"\vchatMessage\x12)\n" +
 	"\x10participant_name\x18\x01 \x01(\tR\x0fparticipantName\x12\x18\n" +
 	"\acontent\x18\x02 \x01(\tR\acontent\x12\x18\n" +
-	"\alamport\x18\x03 \x01(\x03R\alamport\"X\n" +
+	"\alamport\x18\x03 \x01(\x03R\alamport\x12\x12\n" +
+	"\x04room\x18\x04 \x01(\tR\x04room\x12@\n" +
+	"\fvector_clock\x18\x05 \x03(\v2\x1d.chatMessage.VectorClockEntryR\vvectorClock\x1a>\n" +
+	"\x10VectorClockEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\x03R\x05value:\x028\x01\"\xe6\x01\n" +
+	"\fsessionFrame\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x04R\x02id\x12\"\n" +
+	"\x04join\x18\x02 \x01(\v2\f.joinRequestH\x00R\x04join\x12\"\n" +
+	"\x04chat\x18\x03 \x01(\v2\f.chatMessageH\x00R\x04chat\x12%\n" +
+	"\x05leave\x18\x04 \x01(\v2\r.leaveRequestH\x00R\x05leave\x12\x1f\n" +
+	"\x03ack\x18\x05 \x01(\v2\v.sessionAckH\x00R\x03ack\x12-\n" +
+	"\amessage\x18\x06 \x01(\v2\x11.broadcastMessageH\x00R\amessageB\a\n" +
+	"\x05frame\"\xa7\x01\n" +
+	"\n" +
+	"sessionAck\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x04R\x02id\x12\x18\n" +
+	"\asuccess\x18\x02 \x01(\bR\asuccess\x12+\n" +
+	"\x11lamport_timestamp\x18\x03 \x01(\x03R\x10lamportTimestamp\x12\x16\n" +
+	"\x06reason\x18\x04 \x01(\tR\x06reason\x12\x18\n" +
+	"\aresumed\x18\x05 \x01(\bR\aresumed\x12\x10\n" +
+	"\x03seq\x18\x06 \x01(\x04R\x03seq\"p\n" +
 	"\x0fpublishResponse\x12\x18\n" +
 	"\asuccess\x18\x01 \x01(\bR\asuccess\x12+\n" +
-	"\x11lamport_timestamp\x18\x02 \x01(\x03R\x10lamportTimestamp\"9\n" +
+	"\x11lamport_timestamp\x18\x02 \x01(\x03R\x10lamportTimestamp\x12\x16\n" +
+	"\x06reason\x18\x03 \x01(\tR\x06reason\"\xc4\x01\n" +
+	"\x11moderationRequest\x12)\n" +
+	"\x10participant_name\x18\x01 \x01(\tR\x0fparticipantName\x12)\n" +
+	"\x06action\x18\x02 \x01(\x0e2\x11.moderationActionR\x06action\x12\x16\n" +
+	"\x06target\x18\x03 \x01(\tR\x06target\x12)\n" +
+	"\x10duration_seconds\x18\x04 \x01(\x03R\x0fdurationSeconds\x12\x16\n" +
+	"\x06reason\x18\x05 \x01(\tR\x06reason\".\n" +
+	"\x12moderationResponse\x12\x18\n" +
+	"\asuccess\x18\x01 \x01(\bR\asuccess\"9\n" +
 	"\fleaveRequest\x12)\n" +
 	"\x10participant_name\x18\x01 \x01(\tR\x0fparticipantName\")\n" +
 	"\rleaveResponse\x12\x18\n" +
-	"\asuccess\x18\x01 \x01(\bR\asuccess\"{\n" +
+	"\asuccess\x18\x01 \x01(\bR\asuccess\"\x93\x03\n" +
 	"\x10broadcastMessage\x12\x18\n" +
 	"\acontent\x18\x01 \x01(\tR\acontent\x12+\n" +
 	"\x11lamport_timestamp\x18\x02 \x01(\x03R\x10lamportTimestamp\x12 \n" +
-	"\x04type\x18\x03 \x01(\x0e2\f.messageTypeR\x04type*,\n" +
+	"\x04type\x18\x03 \x01(\x0e2\f.messageTypeR\x04type\x12\x1a\n" +
+	"\breplayed\x18\x04 \x01(\bR\breplayed\x12\x12\n" +
+	"\x04room\x18\x05 \x01(\tR\x04room\x12E\n" +
+	"\fvector_clock\x18\x06 \x03(\v2\".broadcastMessage.VectorClockEntryR\vvectorClock\x12\x16\n" +
+	"\x06sender\x18\a \x01(\tR\x06sender\x12\x10\n" +
+	"\x03seq\x18\b \x01(\x04R\x03seq\x12\x16\n" +
+	"\x06origin\x18\t \x01(\tR\x06origin\x12\x1d\n" +
+	"\n" +
+	"origin_seq\x18\n" +
+	" \x01(\x04R\toriginSeq\x1a>\n" +
+	"\x10VectorClockEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\x03R\x05value:\x028\x01\"O\n" +
+	"\frelayMessage\x12+\n" +
+	"\amessage\x18\x01 \x01(\v2\x11.broadcastMessageR\amessage\x12\x12\n" +
+	"\x04path\x18\x02 \x03(\tR\x04path\"G\n" +
+	"\rrelayResponse\x12\x18\n" +
+	"\asuccess\x18\x01 \x01(\bR\asuccess\x12\x1c\n" +
+	"\tduplicate\x18\x02 \x01(\bR\tduplicate\"\x8d\x01\n" +
+	"\x0eprivateMessage\x12)\n" +
+	"\x10participant_name\x18\x01 \x01(\tR\x0fparticipantName\x12\x1c\n" +
+	"\trecipient\x18\x02 \x01(\tR\trecipient\x12\x18\n" +
+	"\acontent\x18\x03 \x01(\tR\acontent\x12\x18\n" +
+	"\alamport\x18\x04 \x01(\x03R\alamport\"v\n" +
+	"\x0fprivateResponse\x12\x18\n" +
+	"\asuccess\x18\x01 \x01(\bR\asuccess\x12\x1c\n" +
+	"\trecipient\x18\x02 \x01(\tR\trecipient\x12+\n" +
+	"\x11lamport_timestamp\x18\x03 \x01(\x03R\x10lamportTimestamp\"L\n" +
+	"\vroomRequest\x12)\n" +
+	"\x10participant_name\x18\x01 \x01(\tR\x0fparticipantName\x12\x12\n" +
+	"\x04room\x18\x02 \x01(\tR\x04room\"i\n" +
+	"\froomResponse\x12\x18\n" +
+	"\asuccess\x18\x01 \x01(\bR\asuccess\x12\x12\n" +
+	"\x04room\x18\x02 \x01(\tR\x04room\x12+\n" +
+	"\x11lamport_timestamp\x18\x03 \x01(\x03R\x10lamportTimestamp\"\x12\n" +
+	"\x10listRoomsRequest\"4\n" +
+	"\x11listRoomsResponse\x12\x1f\n" +
+	"\x05rooms\x18\x01 \x03(\v2\t.roomInfoR\x05rooms\"e\n" +
+	"\broomInfo\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x18\n" +
+	"\amembers\x18\x02 \x01(\x05R\amembers\x12+\n" +
+	"\x11lamport_timestamp\x18\x03 \x01(\x03R\x10lamportTimestamp\"\x19\n" +
+	"\x17listParticipantsRequest\"P\n" +
+	"\x18listParticipantsResponse\x124\n" +
+	"\fparticipants\x18\x01 \x03(\v2\x10.participantInfoR\fparticipants\"|\n" +
+	"\x0fparticipantInfo\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12'\n" +
+	"\x06status\x18\x02 \x01(\x0e2\x0f.presenceStatusR\x06status\x12\x16\n" +
+	"\x06typing\x18\x03 \x01(\bR\x06typing\x12\x14\n" +
+	"\x05rooms\x18\x04 \x03(\tR\x05rooms\"<\n" +
+	"\x0fpresenceRequest\x12)\n" +
+	"\x10participant_name\x18\x01 \x01(\tR\x0fparticipantName\"\x8f\x01\n" +
+	"\rpresenceEvent\x12)\n" +
+	"\x10participant_name\x18\x01 \x01(\tR\x0fparticipantName\x12'\n" +
+	"\x06status\x18\x02 \x01(\x0e2\x0f.presenceStatusR\x06status\x12\x16\n" +
+	"\x06typing\x18\x03 \x01(\bR\x06typing\x12\x12\n" +
+	"\x04room\x18\x04 \x01(\tR\x04room\"i\n" +
+	"\x10heartbeatRequest\x12)\n" +
+	"\x10participant_name\x18\x01 \x01(\tR\x0fparticipantName\x12\x16\n" +
+	"\x06typing\x18\x02 \x01(\bR\x06typing\x12\x12\n" +
+	"\x04room\x18\x03 \x01(\tR\x04room\"-\n" +
+	"\x11heartbeatResponse\x12\x18\n" +
+	"\asuccess\x18\x01 \x01(\bR\asuccess*S\n" +
+	"\x0eroomChangeType\x12\v\n" +
+	"\aCREATED\x10\x00\x12\n" +
+	"\n" +
+	"\x06JOINED\x10\x01\x12\b\n" +
+	"\x04LEFT\x10\x02\x12\x0f\n" +
+	"\vJOINED_CHAT\x10\x03\x12\r\n" +
+	"\tLEFT_CHAT\x10\x04*+\n" +
+	"\x11sessionChangeType\x12\v\n" +
+	"\aSTARTED\x10\x00\x12\t\n" +
+	"\x05ENDED\x10\x01*S\n" +
+	"\x11accountChangeType\x12\f\n" +
+	"\bREGISTER\x10\x00\x12\n" +
+	"\n" +
+	"\x06BANNED\x10\x01\x12\f\n" +
+	"\bUNBANNED\x10\x02\x12\t\n" +
+	"\x05MUTED\x10\x03\x12\v\n" +
+	"\aUNMUTED\x10\x04*F\n" +
+	"\x10moderationAction\x12\b\n" +
+	"\x04KICK\x10\x00\x12\b\n" +
+	"\x04MUTE\x10\x01\x12\n" +
+	"\n" +
+	"\x06UNMUTE\x10\x02\x12\a\n" +
+	"\x03BAN\x10\x03\x12\t\n" +
+	"\x05UNBAN\x10\x04*3\n" +
+	"\x0epresenceStatus\x12\v\n" +
+	"\aOFFLINE\x10\x00\x12\n" +
+	"\n" +
+	"\x06ONLINE\x10\x01\x12\b\n" +
+	"\x04IDLE\x10\x02*E\n" +
 	"\vmessageType\x12\b\n" +
 	"\x04CHAT\x10\x00\x12\b\n" +
 	"\x04JOIN\x10\x01\x12\t\n" +
-	"\x05LEAVE\x10\x022\x9a\x01\n" +
-	"\vITUDatabase\x12-\n" +
+	"\x05LEAVE\x10\x02\x12\v\n" +
+	"\aPRIVATE\x10\x03\x12\n" +
+	"\n" +
+	"\x06NOTICE\x10\x04*T\n" +
+	"\x12slowConsumerPolicy\x12\x12\n" +
+	"\x0eSERVER_DEFAULT\x10\x00\x12\x0f\n" +
+	"\vDROP_OLDEST\x10\x01\x12\x0e\n" +
+	"\n" +
+	"DISCONNECT\x10\x02\x12\t\n" +
+	"\x05BLOCK\x10\x032\x86\x06\n" +
+	"\vITUDatabase\x12(\n" +
+	"\bregister\x12\f.credentials\x1a\x0e.loginResponse\x12%\n" +
+	"\x05login\x12\f.credentials\x1a\x0e.loginResponse\x12-\n" +
 	"\bjoinChat\x12\f.joinRequest\x1a\x11.broadcastMessage0\x01\x120\n" +
 	"\x0epublishMessage\x12\f.chatMessage\x1a\x10.publishResponse\x12*\n" +
-	"\tleaveChat\x12\r.leaveRequest\x1a\x0e.leaveResponseB\tZ\a./;grpcb\x06proto3"
+	"\tleaveChat\x12\r.leaveRequest\x1a\x0e.leaveResponse\x12+\n" +
+	"\asession\x12\r.sessionFrame\x1a\r.sessionFrame(\x010\x01\x12)\n" +
+	"\n" +
+	"createRoom\x12\f.roomRequest\x1a\r.roomResponse\x122\n" +
+	"\tlistRooms\x12\x11.listRoomsRequest\x1a\x12.listRoomsResponse\x12'\n" +
+	"\bjoinRoom\x12\f.roomRequest\x1a\r.roomResponse\x12(\n" +
+	"\tleaveRoom\x12\f.roomRequest\x1a\r.roomResponse\x120\n" +
+	"\vsendPrivate\x12\x0f.privateMessage\x1a\x10.privateResponse\x12&\n" +
+	"\x05relay\x12\r.relayMessage\x1a\x0e.relayResponse\x12G\n" +
+	"\x10listParticipants\x12\x18.listParticipantsRequest\x1a\x19.listParticipantsResponse\x12.\n" +
+	"\bpresence\x12\x10.presenceRequest\x1a\x0e.presenceEvent0\x01\x122\n" +
+	"\theartbeat\x12\x11.heartbeatRequest\x1a\x12.heartbeatResponse\x123\n" +
+	"\bmoderate\x12\x12.moderationRequest\x1a\x13.moderationResponse2d\n" +
+	"\x04Raft\x12*\n" +
+	"\vrequestVote\x12\f.voteRequest\x1a\r.voteResponse\x120\n" +
+	"\rappendEntries\x12\x0e.appendRequest\x1a\x0f.appendResponse2V\n" +
+	"\x06Gossip\x12'\n" +
+	"\x04push\x12\x0e.gossipRequest\x1a\x0f.gossipResponse\x12#\n" +
+	"\x04sync\x12\f.syncRequest\x1a\r.syncResponseB\tZ\a./;grpcb\x06proto3"
 
 var (
 	file_proto_proto_rawDescOnce sync.Once
@@ -424,30 +3170,132 @@ func file_proto_proto_rawDescGZIP() []byte {
 	return file_proto_proto_rawDescData
 }
 
-var file_proto_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
-var file_proto_proto_msgTypes = make([]protoimpl.MessageInfo, 6)
+var file_proto_proto_enumTypes = make([]protoimpl.EnumInfo, 7)
+var file_proto_proto_msgTypes = make([]protoimpl.MessageInfo, 43)
 var file_proto_proto_goTypes = []any{
-	(MessageType)(0),         // 0: messageType
-	(*JoinRequest)(nil),      // 1: joinRequest
-	(*ChatMessage)(nil),      // 2: chatMessage
-	(*PublishResponse)(nil),  // 3: publishResponse
-	(*LeaveRequest)(nil),     // 4: leaveRequest
-	(*LeaveResponse)(nil),    // 5: leaveResponse
-	(*BroadcastMessage)(nil), // 6: broadcastMessage
+	(RoomChangeType)(0),              // 0: roomChangeType
+	(SessionChangeType)(0),           // 1: sessionChangeType
+	(AccountChangeType)(0),           // 2: accountChangeType
+	(ModerationAction)(0),            // 3: moderationAction
+	(PresenceStatus)(0),              // 4: presenceStatus
+	(MessageType)(0),                 // 5: messageType
+	(SlowConsumerPolicy)(0),          // 6: slowConsumerPolicy
+	(*Credentials)(nil),              // 7: credentials
+	(*LoginResponse)(nil),            // 8: loginResponse
+	(*RaftEntry)(nil),                // 9: raftEntry
+	(*RoomChange)(nil),               // 10: roomChange
+	(*SessionChange)(nil),            // 11: sessionChange
+	(*AccountChange)(nil),            // 12: accountChange
+	(*VoteRequest)(nil),              // 13: voteRequest
+	(*VoteResponse)(nil),             // 14: voteResponse
+	(*AppendRequest)(nil),            // 15: appendRequest
+	(*AppendResponse)(nil),           // 16: appendResponse
+	(*GossipRequest)(nil),            // 17: gossipRequest
+	(*GossipResponse)(nil),           // 18: gossipResponse
+	(*SyncRequest)(nil),              // 19: syncRequest
+	(*SyncResponse)(nil),             // 20: syncResponse
+	(*JoinRequest)(nil),              // 21: joinRequest
+	(*ChatMessage)(nil),              // 22: chatMessage
+	(*SessionFrame)(nil),             // 23: sessionFrame
+	(*SessionAck)(nil),               // 24: sessionAck
+	(*PublishResponse)(nil),          // 25: publishResponse
+	(*ModerationRequest)(nil),        // 26: moderationRequest
+	(*ModerationResponse)(nil),       // 27: moderationResponse
+	(*LeaveRequest)(nil),             // 28: leaveRequest
+	(*LeaveResponse)(nil),            // 29: leaveResponse
+	(*BroadcastMessage)(nil),         // 30: broadcastMessage
+	(*RelayMessage)(nil),             // 31: relayMessage
+	(*RelayResponse)(nil),            // 32: relayResponse
+	(*PrivateMessage)(nil),           // 33: privateMessage
+	(*PrivateResponse)(nil),          // 34: privateResponse
+	(*RoomRequest)(nil),              // 35: roomRequest
+	(*RoomResponse)(nil),             // 36: roomResponse
+	(*ListRoomsRequest)(nil),         // 37: listRoomsRequest
+	(*ListRoomsResponse)(nil),        // 38: listRoomsResponse
+	(*RoomInfo)(nil),                 // 39: roomInfo
+	(*ListParticipantsRequest)(nil),  // 40: listParticipantsRequest
+	(*ListParticipantsResponse)(nil), // 41: listParticipantsResponse
+	(*ParticipantInfo)(nil),          // 42: participantInfo
+	(*PresenceRequest)(nil),          // 43: presenceRequest
+	(*PresenceEvent)(nil),            // 44: presenceEvent
+	(*HeartbeatRequest)(nil),         // 45: heartbeatRequest
+	(*HeartbeatResponse)(nil),        // 46: heartbeatResponse
+	nil,                              // 47: syncRequest.HaveEntry
+	nil,                              // 48: chatMessage.VectorClockEntry
+	nil,                              // 49: broadcastMessage.VectorClockEntry
 }
 var file_proto_proto_depIdxs = []int32{
-	0, // 0: broadcastMessage.type:type_name -> messageType
-	1, // 1: ITUDatabase.joinChat:input_type -> joinRequest
-	2, // 2: ITUDatabase.publishMessage:input_type -> chatMessage
-	4, // 3: ITUDatabase.leaveChat:input_type -> leaveRequest
-	6, // 4: ITUDatabase.joinChat:output_type -> broadcastMessage
-	3, // 5: ITUDatabase.publishMessage:output_type -> publishResponse
-	5, // 6: ITUDatabase.leaveChat:output_type -> leaveResponse
-	4, // [4:7] is the sub-list for method output_type
-	1, // [1:4] is the sub-list for method input_type
-	1, // [1:1] is the sub-list for extension type_name
-	1, // [1:1] is the sub-list for extension extendee
-	0, // [0:1] is the sub-list for field type_name
+	30, // 0: raftEntry.message:type_name -> broadcastMessage
+	12, // 1: raftEntry.account:type_name -> accountChange
+	10, // 2: raftEntry.room:type_name -> roomChange
+	11, // 3: raftEntry.session:type_name -> sessionChange
+	0,  // 4: roomChange.type:type_name -> roomChangeType
+	1,  // 5: sessionChange.type:type_name -> sessionChangeType
+	2,  // 6: accountChange.type:type_name -> accountChangeType
+	9,  // 7: appendRequest.entries:type_name -> raftEntry
+	30, // 8: gossipRequest.messages:type_name -> broadcastMessage
+	47, // 9: syncRequest.have:type_name -> syncRequest.HaveEntry
+	30, // 10: syncResponse.messages:type_name -> broadcastMessage
+	6,  // 11: joinRequest.slow_policy:type_name -> slowConsumerPolicy
+	48, // 12: chatMessage.vector_clock:type_name -> chatMessage.VectorClockEntry
+	21, // 13: sessionFrame.join:type_name -> joinRequest
+	22, // 14: sessionFrame.chat:type_name -> chatMessage
+	28, // 15: sessionFrame.leave:type_name -> leaveRequest
+	24, // 16: sessionFrame.ack:type_name -> sessionAck
+	30, // 17: sessionFrame.message:type_name -> broadcastMessage
+	3,  // 18: moderationRequest.action:type_name -> moderationAction
+	5,  // 19: broadcastMessage.type:type_name -> messageType
+	49, // 20: broadcastMessage.vector_clock:type_name -> broadcastMessage.VectorClockEntry
+	30, // 21: relayMessage.message:type_name -> broadcastMessage
+	39, // 22: listRoomsResponse.rooms:type_name -> roomInfo
+	42, // 23: listParticipantsResponse.participants:type_name -> participantInfo
+	4,  // 24: participantInfo.status:type_name -> presenceStatus
+	4,  // 25: presenceEvent.status:type_name -> presenceStatus
+	7,  // 26: ITUDatabase.register:input_type -> credentials
+	7,  // 27: ITUDatabase.login:input_type -> credentials
+	21, // 28: ITUDatabase.joinChat:input_type -> joinRequest
+	22, // 29: ITUDatabase.publishMessage:input_type -> chatMessage
+	28, // 30: ITUDatabase.leaveChat:input_type -> leaveRequest
+	23, // 31: ITUDatabase.session:input_type -> sessionFrame
+	35, // 32: ITUDatabase.createRoom:input_type -> roomRequest
+	37, // 33: ITUDatabase.listRooms:input_type -> listRoomsRequest
+	35, // 34: ITUDatabase.joinRoom:input_type -> roomRequest
+	35, // 35: ITUDatabase.leaveRoom:input_type -> roomRequest
+	33, // 36: ITUDatabase.sendPrivate:input_type -> privateMessage
+	31, // 37: ITUDatabase.relay:input_type -> relayMessage
+	40, // 38: ITUDatabase.listParticipants:input_type -> listParticipantsRequest
+	43, // 39: ITUDatabase.presence:input_type -> presenceRequest
+	45, // 40: ITUDatabase.heartbeat:input_type -> heartbeatRequest
+	26, // 41: ITUDatabase.moderate:input_type -> moderationRequest
+	13, // 42: Raft.requestVote:input_type -> voteRequest
+	15, // 43: Raft.appendEntries:input_type -> appendRequest
+	17, // 44: Gossip.push:input_type -> gossipRequest
+	19, // 45: Gossip.sync:input_type -> syncRequest
+	8,  // 46: ITUDatabase.register:output_type -> loginResponse
+	8,  // 47: ITUDatabase.login:output_type -> loginResponse
+	30, // 48: ITUDatabase.joinChat:output_type -> broadcastMessage
+	25, // 49: ITUDatabase.publishMessage:output_type -> publishResponse
+	29, // 50: ITUDatabase.leaveChat:output_type -> leaveResponse
+	23, // 51: ITUDatabase.session:output_type -> sessionFrame
+	36, // 52: ITUDatabase.createRoom:output_type -> roomResponse
+	38, // 53: ITUDatabase.listRooms:output_type -> listRoomsResponse
+	36, // 54: ITUDatabase.joinRoom:output_type -> roomResponse
+	36, // 55: ITUDatabase.leaveRoom:output_type -> roomResponse
+	34, // 56: ITUDatabase.sendPrivate:output_type -> privateResponse
+	32, // 57: ITUDatabase.relay:output_type -> relayResponse
+	41, // 58: ITUDatabase.listParticipants:output_type -> listParticipantsResponse
+	44, // 59: ITUDatabase.presence:output_type -> presenceEvent
+	46, // 60: ITUDatabase.heartbeat:output_type -> heartbeatResponse
+	27, // 61: ITUDatabase.moderate:output_type -> moderationResponse
+	14, // 62: Raft.requestVote:output_type -> voteResponse
+	16, // 63: Raft.appendEntries:output_type -> appendResponse
+	18, // 64: Gossip.push:output_type -> gossipResponse
+	20, // 65: Gossip.sync:output_type -> syncResponse
+	46, // [46:66] is the sub-list for method output_type
+	26, // [26:46] is the sub-list for method input_type
+	26, // [26:26] is the sub-list for extension type_name
+	26, // [26:26] is the sub-list for extension extendee
+	0,  // [0:26] is the sub-list for field type_name
 }
 
 func init() { file_proto_proto_init() }
@@ -455,15 +3303,23 @@ func file_proto_proto_init() {
 	if File_proto_proto != nil {
 		return
 	}
+	file_proto_proto_msgTypes[14].OneofWrappers = []any{}
+	file_proto_proto_msgTypes[16].OneofWrappers = []any{
+		(*SessionFrame_Join)(nil),
+		(*SessionFrame_Chat)(nil),
+		(*SessionFrame_Leave)(nil),
+		(*SessionFrame_Ack)(nil),
+		(*SessionFrame_Message)(nil),
+	}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_proto_proto_rawDesc), len(file_proto_proto_rawDesc)),
-			NumEnums:      1,
-			NumMessages:   6,
+			NumEnums:      7,
+			NumMessages:   43,
 			NumExtensions: 0,
-			NumServices:   1,
+			NumServices:   3,
 		},
 		GoTypes:           file_proto_proto_goTypes,
 		DependencyIndexes: file_proto_proto_depIdxs,
@@ -19,21 +19,59 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	ITUDatabase_JoinChat_FullMethodName       = "/ITUDatabase/joinChat"
-	ITUDatabase_PublishMessage_FullMethodName = "/ITUDatabase/publishMessage"
-	ITUDatabase_LeaveChat_FullMethodName      = "/ITUDatabase/leaveChat"
+	ITUDatabase_Register_FullMethodName         = "/ITUDatabase/register"
+	ITUDatabase_Login_FullMethodName            = "/ITUDatabase/login"
+	ITUDatabase_JoinChat_FullMethodName         = "/ITUDatabase/joinChat"
+	ITUDatabase_PublishMessage_FullMethodName   = "/ITUDatabase/publishMessage"
+	ITUDatabase_LeaveChat_FullMethodName        = "/ITUDatabase/leaveChat"
+	ITUDatabase_Session_FullMethodName          = "/ITUDatabase/session"
+	ITUDatabase_CreateRoom_FullMethodName       = "/ITUDatabase/createRoom"
+	ITUDatabase_ListRooms_FullMethodName        = "/ITUDatabase/listRooms"
+	ITUDatabase_JoinRoom_FullMethodName         = "/ITUDatabase/joinRoom"
+	ITUDatabase_LeaveRoom_FullMethodName        = "/ITUDatabase/leaveRoom"
+	ITUDatabase_SendPrivate_FullMethodName      = "/ITUDatabase/sendPrivate"
+	ITUDatabase_Relay_FullMethodName            = "/ITUDatabase/relay"
+	ITUDatabase_ListParticipants_FullMethodName = "/ITUDatabase/listParticipants"
+	ITUDatabase_Presence_FullMethodName         = "/ITUDatabase/presence"
+	ITUDatabase_Heartbeat_FullMethodName        = "/ITUDatabase/heartbeat"
+	ITUDatabase_Moderate_FullMethodName         = "/ITUDatabase/moderate"
 )
 
 // ITUDatabaseClient is the client API for ITUDatabase service.
 //
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type ITUDatabaseClient interface {
-	// Join the chat and receive broadcast messages via server-side streaming
+	// Create an account, or log in to one. The token in the response has to be sent
+	// in the "session-token" metadata of every other call.
+	Register(ctx context.Context, in *Credentials, opts ...grpc.CallOption) (*LoginResponse, error)
+	Login(ctx context.Context, in *Credentials, opts ...grpc.CallOption) (*LoginResponse, error)
+	// Join the chat and receive broadcast messages via server-side streaming.
+	// With since_lamport set, the stored history after that time is sent first
 	JoinChat(ctx context.Context, in *JoinRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[BroadcastMessage], error)
 	// Publish a message to the chat rpc JoinC
 	PublishMessage(ctx context.Context, in *ChatMessage, opts ...grpc.CallOption) (*PublishResponse, error)
 	// Leave the chat
 	LeaveChat(ctx context.Context, in *LeaveRequest, opts ...grpc.CallOption) (*LeaveResponse, error)
+	// All of the above on one stream: the client joins, chats and leaves with frames on it,
+	// and gets the broadcast messages on it. A session that never joined can't chat.
+	Session(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[SessionFrame, SessionFrame], error)
+	// Rooms: everybody is in #general, other rooms have to be created and joined
+	CreateRoom(ctx context.Context, in *RoomRequest, opts ...grpc.CallOption) (*RoomResponse, error)
+	ListRooms(ctx context.Context, in *ListRoomsRequest, opts ...grpc.CallOption) (*ListRoomsResponse, error)
+	JoinRoom(ctx context.Context, in *RoomRequest, opts ...grpc.CallOption) (*RoomResponse, error)
+	LeaveRoom(ctx context.Context, in *RoomRequest, opts ...grpc.CallOption) (*RoomResponse, error)
+	// Send a message only to one participant, fails if the recipient is not online
+	SendPrivate(ctx context.Context, in *PrivateMessage, opts ...grpc.CallOption) (*PrivateResponse, error)
+	// Between servers: a broadcast message from another server, to deliver to our clients
+	// and to pass on to the peers it has not been to yet
+	Relay(ctx context.Context, in *RelayMessage, opts ...grpc.CallOption) (*RelayResponse, error)
+	// Presence: who is connected, and a stream of status and typing changes.
+	// Clients send a heartbeat every few seconds, without them they count as idle.
+	ListParticipants(ctx context.Context, in *ListParticipantsRequest, opts ...grpc.CallOption) (*ListParticipantsResponse, error)
+	Presence(ctx context.Context, in *PresenceRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[PresenceEvent], error)
+	Heartbeat(ctx context.Context, in *HeartbeatRequest, opts ...grpc.CallOption) (*HeartbeatResponse, error)
+	// Admins only: kick, mute or ban a participant
+	Moderate(ctx context.Context, in *ModerationRequest, opts ...grpc.CallOption) (*ModerationResponse, error)
 }
 
 type iTUDatabaseClient struct {
@@ -44,6 +82,26 @@ func NewITUDatabaseClient(cc grpc.ClientConnInterface) ITUDatabaseClient {
 	return &iTUDatabaseClient{cc}
 }
 
+func (c *iTUDatabaseClient) Register(ctx context.Context, in *Credentials, opts ...grpc.CallOption) (*LoginResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(LoginResponse)
+	err := c.cc.Invoke(ctx, ITUDatabase_Register_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *iTUDatabaseClient) Login(ctx context.Context, in *Credentials, opts ...grpc.CallOption) (*LoginResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(LoginResponse)
+	err := c.cc.Invoke(ctx, ITUDatabase_Login_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *iTUDatabaseClient) JoinChat(ctx context.Context, in *JoinRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[BroadcastMessage], error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	stream, err := c.cc.NewStream(ctx, &ITUDatabase_ServiceDesc.Streams[0], ITUDatabase_JoinChat_FullMethodName, cOpts...)
@@ -83,16 +141,163 @@ func (c *iTUDatabaseClient) LeaveChat(ctx context.Context, in *LeaveRequest, opt
 	return out, nil
 }
 
+func (c *iTUDatabaseClient) Session(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[SessionFrame, SessionFrame], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &ITUDatabase_ServiceDesc.Streams[1], ITUDatabase_Session_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[SessionFrame, SessionFrame]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ITUDatabase_SessionClient = grpc.BidiStreamingClient[SessionFrame, SessionFrame]
+
+func (c *iTUDatabaseClient) CreateRoom(ctx context.Context, in *RoomRequest, opts ...grpc.CallOption) (*RoomResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RoomResponse)
+	err := c.cc.Invoke(ctx, ITUDatabase_CreateRoom_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *iTUDatabaseClient) ListRooms(ctx context.Context, in *ListRoomsRequest, opts ...grpc.CallOption) (*ListRoomsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListRoomsResponse)
+	err := c.cc.Invoke(ctx, ITUDatabase_ListRooms_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *iTUDatabaseClient) JoinRoom(ctx context.Context, in *RoomRequest, opts ...grpc.CallOption) (*RoomResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RoomResponse)
+	err := c.cc.Invoke(ctx, ITUDatabase_JoinRoom_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *iTUDatabaseClient) LeaveRoom(ctx context.Context, in *RoomRequest, opts ...grpc.CallOption) (*RoomResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RoomResponse)
+	err := c.cc.Invoke(ctx, ITUDatabase_LeaveRoom_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *iTUDatabaseClient) SendPrivate(ctx context.Context, in *PrivateMessage, opts ...grpc.CallOption) (*PrivateResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(PrivateResponse)
+	err := c.cc.Invoke(ctx, ITUDatabase_SendPrivate_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *iTUDatabaseClient) Relay(ctx context.Context, in *RelayMessage, opts ...grpc.CallOption) (*RelayResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RelayResponse)
+	err := c.cc.Invoke(ctx, ITUDatabase_Relay_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *iTUDatabaseClient) ListParticipants(ctx context.Context, in *ListParticipantsRequest, opts ...grpc.CallOption) (*ListParticipantsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListParticipantsResponse)
+	err := c.cc.Invoke(ctx, ITUDatabase_ListParticipants_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *iTUDatabaseClient) Presence(ctx context.Context, in *PresenceRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[PresenceEvent], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &ITUDatabase_ServiceDesc.Streams[2], ITUDatabase_Presence_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[PresenceRequest, PresenceEvent]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ITUDatabase_PresenceClient = grpc.ServerStreamingClient[PresenceEvent]
+
+func (c *iTUDatabaseClient) Heartbeat(ctx context.Context, in *HeartbeatRequest, opts ...grpc.CallOption) (*HeartbeatResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(HeartbeatResponse)
+	err := c.cc.Invoke(ctx, ITUDatabase_Heartbeat_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *iTUDatabaseClient) Moderate(ctx context.Context, in *ModerationRequest, opts ...grpc.CallOption) (*ModerationResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ModerationResponse)
+	err := c.cc.Invoke(ctx, ITUDatabase_Moderate_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // ITUDatabaseServer is the server API for ITUDatabase service.
 // All implementations must embed UnimplementedITUDatabaseServer
 // for forward compatibility.
 type ITUDatabaseServer interface {
-	// Join the chat and receive broadcast messages via server-side streaming
+	// Create an account, or log in to one. The token in the response has to be sent
+	// in the "session-token" metadata of every other call.
+	Register(context.Context, *Credentials) (*LoginResponse, error)
+	Login(context.Context, *Credentials) (*LoginResponse, error)
+	// Join the chat and receive broadcast messages via server-side streaming.
+	// With since_lamport set, the stored history after that time is sent first
 	JoinChat(*JoinRequest, grpc.ServerStreamingServer[BroadcastMessage]) error
 	// Publish a message to the chat rpc JoinC
 	PublishMessage(context.Context, *ChatMessage) (*PublishResponse, error)
 	// Leave the chat
 	LeaveChat(context.Context, *LeaveRequest) (*LeaveResponse, error)
+	// All of the above on one stream: the client joins, chats and leaves with frames on it,
+	// and gets the broadcast messages on it. A session that never joined can't chat.
+	Session(grpc.BidiStreamingServer[SessionFrame, SessionFrame]) error
+	// Rooms: everybody is in #general, other rooms have to be created and joined
+	CreateRoom(context.Context, *RoomRequest) (*RoomResponse, error)
+	ListRooms(context.Context, *ListRoomsRequest) (*ListRoomsResponse, error)
+	JoinRoom(context.Context, *RoomRequest) (*RoomResponse, error)
+	LeaveRoom(context.Context, *RoomRequest) (*RoomResponse, error)
+	// Send a message only to one participant, fails if the recipient is not online
+	SendPrivate(context.Context, *PrivateMessage) (*PrivateResponse, error)
+	// Between servers: a broadcast message from another server, to deliver to our clients
+	// and to pass on to the peers it has not been to yet
+	Relay(context.Context, *RelayMessage) (*RelayResponse, error)
+	// Presence: who is connected, and a stream of status and typing changes.
+	// Clients send a heartbeat every few seconds, without them they count as idle.
+	ListParticipants(context.Context, *ListParticipantsRequest) (*ListParticipantsResponse, error)
+	Presence(*PresenceRequest, grpc.ServerStreamingServer[PresenceEvent]) error
+	Heartbeat(context.Context, *HeartbeatRequest) (*HeartbeatResponse, error)
+	// Admins only: kick, mute or ban a participant
+	Moderate(context.Context, *ModerationRequest) (*ModerationResponse, error)
 	mustEmbedUnimplementedITUDatabaseServer()
 }
 
@@ -103,6 +308,12 @@ type ITUDatabaseServer interface {
 // pointer dereference when methods are called.
 type UnimplementedITUDatabaseServer struct{}
 
+func (UnimplementedITUDatabaseServer) Register(context.Context, *Credentials) (*LoginResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Register not implemented")
+}
+func (UnimplementedITUDatabaseServer) Login(context.Context, *Credentials) (*LoginResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Login not implemented")
+}
 func (UnimplementedITUDatabaseServer) JoinChat(*JoinRequest, grpc.ServerStreamingServer[BroadcastMessage]) error {
 	return status.Errorf(codes.Unimplemented, "method JoinChat not implemented")
 }
@@ -112,6 +323,39 @@ func (UnimplementedITUDatabaseServer) PublishMessage(context.Context, *ChatMessa
 func (UnimplementedITUDatabaseServer) LeaveChat(context.Context, *LeaveRequest) (*LeaveResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method LeaveChat not implemented")
 }
+func (UnimplementedITUDatabaseServer) Session(grpc.BidiStreamingServer[SessionFrame, SessionFrame]) error {
+	return status.Errorf(codes.Unimplemented, "method Session not implemented")
+}
+func (UnimplementedITUDatabaseServer) CreateRoom(context.Context, *RoomRequest) (*RoomResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateRoom not implemented")
+}
+func (UnimplementedITUDatabaseServer) ListRooms(context.Context, *ListRoomsRequest) (*ListRoomsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListRooms not implemented")
+}
+func (UnimplementedITUDatabaseServer) JoinRoom(context.Context, *RoomRequest) (*RoomResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method JoinRoom not implemented")
+}
+func (UnimplementedITUDatabaseServer) LeaveRoom(context.Context, *RoomRequest) (*RoomResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method LeaveRoom not implemented")
+}
+func (UnimplementedITUDatabaseServer) SendPrivate(context.Context, *PrivateMessage) (*PrivateResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SendPrivate not implemented")
+}
+func (UnimplementedITUDatabaseServer) Relay(context.Context, *RelayMessage) (*RelayResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Relay not implemented")
+}
+func (UnimplementedITUDatabaseServer) ListParticipants(context.Context, *ListParticipantsRequest) (*ListParticipantsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListParticipants not implemented")
+}
+func (UnimplementedITUDatabaseServer) Presence(*PresenceRequest, grpc.ServerStreamingServer[PresenceEvent]) error {
+	return status.Errorf(codes.Unimplemented, "method Presence not implemented")
+}
+func (UnimplementedITUDatabaseServer) Heartbeat(context.Context, *HeartbeatRequest) (*HeartbeatResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Heartbeat not implemented")
+}
+func (UnimplementedITUDatabaseServer) Moderate(context.Context, *ModerationRequest) (*ModerationResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Moderate not implemented")
+}
 func (UnimplementedITUDatabaseServer) mustEmbedUnimplementedITUDatabaseServer() {}
 func (UnimplementedITUDatabaseServer) testEmbeddedByValue()                     {}
 
@@ -133,6 +377,42 @@ func RegisterITUDatabaseServer(s grpc.ServiceRegistrar, srv ITUDatabaseServer) {
 	s.RegisterService(&ITUDatabase_ServiceDesc, srv)
 }
 
+func _ITUDatabase_Register_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Credentials)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ITUDatabaseServer).Register(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ITUDatabase_Register_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ITUDatabaseServer).Register(ctx, req.(*Credentials))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ITUDatabase_Login_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Credentials)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ITUDatabaseServer).Login(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ITUDatabase_Login_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ITUDatabaseServer).Login(ctx, req.(*Credentials))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _ITUDatabase_JoinChat_Handler(srv interface{}, stream grpc.ServerStream) error {
 	m := new(JoinRequest)
 	if err := stream.RecvMsg(m); err != nil {
@@ -180,6 +460,186 @@ func _ITUDatabase_LeaveChat_Handler(srv interface{}, ctx context.Context, dec fu
 	return interceptor(ctx, in, info, handler)
 }
 
+func _ITUDatabase_Session_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(ITUDatabaseServer).Session(&grpc.GenericServerStream[SessionFrame, SessionFrame]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ITUDatabase_SessionServer = grpc.BidiStreamingServer[SessionFrame, SessionFrame]
+
+func _ITUDatabase_CreateRoom_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RoomRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ITUDatabaseServer).CreateRoom(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ITUDatabase_CreateRoom_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ITUDatabaseServer).CreateRoom(ctx, req.(*RoomRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ITUDatabase_ListRooms_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListRoomsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ITUDatabaseServer).ListRooms(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ITUDatabase_ListRooms_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ITUDatabaseServer).ListRooms(ctx, req.(*ListRoomsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ITUDatabase_JoinRoom_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RoomRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ITUDatabaseServer).JoinRoom(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ITUDatabase_JoinRoom_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ITUDatabaseServer).JoinRoom(ctx, req.(*RoomRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ITUDatabase_LeaveRoom_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RoomRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ITUDatabaseServer).LeaveRoom(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ITUDatabase_LeaveRoom_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ITUDatabaseServer).LeaveRoom(ctx, req.(*RoomRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ITUDatabase_SendPrivate_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PrivateMessage)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ITUDatabaseServer).SendPrivate(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ITUDatabase_SendPrivate_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ITUDatabaseServer).SendPrivate(ctx, req.(*PrivateMessage))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ITUDatabase_Relay_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RelayMessage)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ITUDatabaseServer).Relay(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ITUDatabase_Relay_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ITUDatabaseServer).Relay(ctx, req.(*RelayMessage))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ITUDatabase_ListParticipants_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListParticipantsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ITUDatabaseServer).ListParticipants(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ITUDatabase_ListParticipants_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ITUDatabaseServer).ListParticipants(ctx, req.(*ListParticipantsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ITUDatabase_Presence_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(PresenceRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ITUDatabaseServer).Presence(m, &grpc.GenericServerStream[PresenceRequest, PresenceEvent]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ITUDatabase_PresenceServer = grpc.ServerStreamingServer[PresenceEvent]
+
+func _ITUDatabase_Heartbeat_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(HeartbeatRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ITUDatabaseServer).Heartbeat(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ITUDatabase_Heartbeat_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ITUDatabaseServer).Heartbeat(ctx, req.(*HeartbeatRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ITUDatabase_Moderate_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ModerationRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ITUDatabaseServer).Moderate(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ITUDatabase_Moderate_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ITUDatabaseServer).Moderate(ctx, req.(*ModerationRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // ITUDatabase_ServiceDesc is the grpc.ServiceDesc for ITUDatabase service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -187,6 +647,14 @@ var ITUDatabase_ServiceDesc = grpc.ServiceDesc{
 	ServiceName: "ITUDatabase",
 	HandlerType: (*ITUDatabaseServer)(nil),
 	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "register",
+			Handler:    _ITUDatabase_Register_Handler,
+		},
+		{
+			MethodName: "login",
+			Handler:    _ITUDatabase_Login_Handler,
+		},
 		{
 			MethodName: "publishMessage",
 			Handler:    _ITUDatabase_PublishMessage_Handler,
@@ -195,6 +663,42 @@ var ITUDatabase_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "leaveChat",
 			Handler:    _ITUDatabase_LeaveChat_Handler,
 		},
+		{
+			MethodName: "createRoom",
+			Handler:    _ITUDatabase_CreateRoom_Handler,
+		},
+		{
+			MethodName: "listRooms",
+			Handler:    _ITUDatabase_ListRooms_Handler,
+		},
+		{
+			MethodName: "joinRoom",
+			Handler:    _ITUDatabase_JoinRoom_Handler,
+		},
+		{
+			MethodName: "leaveRoom",
+			Handler:    _ITUDatabase_LeaveRoom_Handler,
+		},
+		{
+			MethodName: "sendPrivate",
+			Handler:    _ITUDatabase_SendPrivate_Handler,
+		},
+		{
+			MethodName: "relay",
+			Handler:    _ITUDatabase_Relay_Handler,
+		},
+		{
+			MethodName: "listParticipants",
+			Handler:    _ITUDatabase_ListParticipants_Handler,
+		},
+		{
+			MethodName: "heartbeat",
+			Handler:    _ITUDatabase_Heartbeat_Handler,
+		},
+		{
+			MethodName: "moderate",
+			Handler:    _ITUDatabase_Moderate_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
 		{
@@ -202,6 +706,305 @@ var ITUDatabase_ServiceDesc = grpc.ServiceDesc{
 			Handler:       _ITUDatabase_JoinChat_Handler,
 			ServerStreams: true,
 		},
+		{
+			StreamName:    "session",
+			Handler:       _ITUDatabase_Session_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "presence",
+			Handler:       _ITUDatabase_Presence_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "proto.proto",
+}
+
+const (
+	Raft_RequestVote_FullMethodName   = "/Raft/requestVote"
+	Raft_AppendEntries_FullMethodName = "/Raft/appendEntries"
+)
+
+// RaftClient is the client API for Raft service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Raft between the replicas of a replicated server
+type RaftClient interface {
+	RequestVote(ctx context.Context, in *VoteRequest, opts ...grpc.CallOption) (*VoteResponse, error)
+	AppendEntries(ctx context.Context, in *AppendRequest, opts ...grpc.CallOption) (*AppendResponse, error)
+}
+
+type raftClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewRaftClient(cc grpc.ClientConnInterface) RaftClient {
+	return &raftClient{cc}
+}
+
+func (c *raftClient) RequestVote(ctx context.Context, in *VoteRequest, opts ...grpc.CallOption) (*VoteResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(VoteResponse)
+	err := c.cc.Invoke(ctx, Raft_RequestVote_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *raftClient) AppendEntries(ctx context.Context, in *AppendRequest, opts ...grpc.CallOption) (*AppendResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(AppendResponse)
+	err := c.cc.Invoke(ctx, Raft_AppendEntries_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// RaftServer is the server API for Raft service.
+// All implementations must embed UnimplementedRaftServer
+// for forward compatibility.
+//
+// Raft between the replicas of a replicated server
+type RaftServer interface {
+	RequestVote(context.Context, *VoteRequest) (*VoteResponse, error)
+	AppendEntries(context.Context, *AppendRequest) (*AppendResponse, error)
+	mustEmbedUnimplementedRaftServer()
+}
+
+// UnimplementedRaftServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedRaftServer struct{}
+
+func (UnimplementedRaftServer) RequestVote(context.Context, *VoteRequest) (*VoteResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RequestVote not implemented")
+}
+func (UnimplementedRaftServer) AppendEntries(context.Context, *AppendRequest) (*AppendResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AppendEntries not implemented")
+}
+func (UnimplementedRaftServer) mustEmbedUnimplementedRaftServer() {}
+func (UnimplementedRaftServer) testEmbeddedByValue()              {}
+
+// UnsafeRaftServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to RaftServer will
+// result in compilation errors.
+type UnsafeRaftServer interface {
+	mustEmbedUnimplementedRaftServer()
+}
+
+func RegisterRaftServer(s grpc.ServiceRegistrar, srv RaftServer) {
+	// If the following call pancis, it indicates UnimplementedRaftServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Raft_ServiceDesc, srv)
+}
+
+func _Raft_RequestVote_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(VoteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RaftServer).RequestVote(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Raft_RequestVote_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RaftServer).RequestVote(ctx, req.(*VoteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Raft_AppendEntries_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AppendRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RaftServer).AppendEntries(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Raft_AppendEntries_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RaftServer).AppendEntries(ctx, req.(*AppendRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Raft_ServiceDesc is the grpc.ServiceDesc for Raft service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Raft_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "Raft",
+	HandlerType: (*RaftServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "requestVote",
+			Handler:    _Raft_RequestVote_Handler,
+		},
+		{
+			MethodName: "appendEntries",
+			Handler:    _Raft_AppendEntries_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "proto.proto",
+}
+
+const (
+	Gossip_Push_FullMethodName = "/Gossip/push"
+	Gossip_Sync_FullMethodName = "/Gossip/sync"
+)
+
+// GossipClient is the client API for Gossip service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Serverless mode: the nodes of the participants gossip the messages to each other
+type GossipClient interface {
+	Push(ctx context.Context, in *GossipRequest, opts ...grpc.CallOption) (*GossipResponse, error)
+	Sync(ctx context.Context, in *SyncRequest, opts ...grpc.CallOption) (*SyncResponse, error)
+}
+
+type gossipClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewGossipClient(cc grpc.ClientConnInterface) GossipClient {
+	return &gossipClient{cc}
+}
+
+func (c *gossipClient) Push(ctx context.Context, in *GossipRequest, opts ...grpc.CallOption) (*GossipResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GossipResponse)
+	err := c.cc.Invoke(ctx, Gossip_Push_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *gossipClient) Sync(ctx context.Context, in *SyncRequest, opts ...grpc.CallOption) (*SyncResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SyncResponse)
+	err := c.cc.Invoke(ctx, Gossip_Sync_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// GossipServer is the server API for Gossip service.
+// All implementations must embed UnimplementedGossipServer
+// for forward compatibility.
+//
+// Serverless mode: the nodes of the participants gossip the messages to each other
+type GossipServer interface {
+	Push(context.Context, *GossipRequest) (*GossipResponse, error)
+	Sync(context.Context, *SyncRequest) (*SyncResponse, error)
+	mustEmbedUnimplementedGossipServer()
+}
+
+// UnimplementedGossipServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedGossipServer struct{}
+
+func (UnimplementedGossipServer) Push(context.Context, *GossipRequest) (*GossipResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Push not implemented")
+}
+func (UnimplementedGossipServer) Sync(context.Context, *SyncRequest) (*SyncResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Sync not implemented")
+}
+func (UnimplementedGossipServer) mustEmbedUnimplementedGossipServer() {}
+func (UnimplementedGossipServer) testEmbeddedByValue()                {}
+
+// UnsafeGossipServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to GossipServer will
+// result in compilation errors.
+type UnsafeGossipServer interface {
+	mustEmbedUnimplementedGossipServer()
+}
+
+func RegisterGossipServer(s grpc.ServiceRegistrar, srv GossipServer) {
+	// If the following call pancis, it indicates UnimplementedGossipServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Gossip_ServiceDesc, srv)
+}
+
+func _Gossip_Push_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GossipRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GossipServer).Push(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Gossip_Push_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GossipServer).Push(ctx, req.(*GossipRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Gossip_Sync_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SyncRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GossipServer).Sync(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Gossip_Sync_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GossipServer).Sync(ctx, req.(*SyncRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Gossip_ServiceDesc is the grpc.ServiceDesc for Gossip service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Gossip_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "Gossip",
+	HandlerType: (*GossipServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "push",
+			Handler:    _Gossip_Push_Handler,
+		},
+		{
+			MethodName: "sync",
+			Handler:    _Gossip_Sync_Handler,
+		},
 	},
+	Streams:  []grpc.StreamDesc{},
 	Metadata: "proto.proto",
 }
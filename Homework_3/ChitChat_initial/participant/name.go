@@ -0,0 +1,26 @@
+// Package participant has the rules for participant names, shared by the server and certgen
+// so a certificate is never issued for a name the server refuses.
+package participant
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MaxNameLength is the longest name a participant can have
+const MaxNameLength = 32
+
+// ValidName keeps names printable in messages and usable in /msg. A name is also the file name
+// of its certificate, so it can't be a path or take the files of the CA and the server.
+func ValidName(name string) error {
+	if name == "" || len(name) > MaxNameLength || strings.ContainsAny(name, " \t\n#") {
+		return fmt.Errorf("invalid participant name %q", name)
+	}
+	if name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return fmt.Errorf("participant name %q is a path", name)
+	}
+	if name == "ca" || name == "server" || strings.HasSuffix(name, "-key") {
+		return fmt.Errorf("participant name %q is reserved", name)
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package participant
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidName(t *testing.T) {
+	tests := []struct {
+		name  string
+		valid bool
+	}{
+		{"Alice", true},
+		{"bob_2", true},
+		{"key", true},
+		{strings.Repeat("x", MaxNameLength), true},
+		{"", false},
+		{strings.Repeat("x", MaxNameLength+1), false},
+		{"Alice Bob", false},
+		{"tab\there", false},
+		{"#general", false},
+		{".", false},
+		{"..", false},
+		{"../x", false},
+		{`a\b`, false},
+		{"ca", false},
+		{"server", false},
+		{"Alice-key", false},
+	}
+	for _, test := range tests {
+		if err := ValidName(test.name); (err == nil) != test.valid {
+			t.Errorf("ValidName(%q) = %v, want valid %v", test.name, err, test.valid)
+		}
+	}
+}
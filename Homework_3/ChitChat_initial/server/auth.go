@@ -0,0 +1,434 @@
+package main
+
+import (
+	pb "ITUserver/grpc"
+	"ITUserver/participant"
+	"context"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Every call except register and login has to carry a session token in this metadata key
+const tokenKey = "session-token"
+
+const pbkdf2Iterations = 100000
+
+// account is what is stored of a participant, never the password itself
+type account struct {
+	Salt   string `json:"salt"`
+	Hash   string `json:"hash"`
+	Banned bool   `json:"banned,omitempty"`
+}
+
+// accounts are the registered participants, kept in a JSON file
+type accounts struct {
+	mu    sync.Mutex
+	path  string
+	users map[string]account
+}
+
+func openAccounts(path string) (*accounts, error) {
+	a := &accounts{path: path, users: make(map[string]account)}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return a, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &a.users); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+func hashPassword(password string, salt []byte) (string, error) {
+	key, err := pbkdf2.Key(sha256.New, password, salt, pbkdf2Iterations, 32)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(key), nil
+}
+
+// newAccount makes the salt and hash of an account with a password
+func newAccount(password string) (account, error) {
+	salt := make([]byte, 16)
+	rand.Read(salt)
+	hash, err := hashPassword(password, salt)
+	if err != nil {
+		return account{}, err
+	}
+	return account{Salt: hex.EncodeToString(salt), Hash: hash}, nil
+}
+
+// add stores a new account, the name must not be taken
+func (a *accounts) add(name string, acc account) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if _, exists := a.users[name]; exists {
+		return status.Errorf(codes.AlreadyExists, "%s is already registered", name)
+	}
+	a.users[name] = acc
+	return a.saveLocked()
+}
+
+func (a *accounts) get(name string) (account, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	acc, exists := a.users[name]
+	return acc, exists
+}
+
+// allows refuses a change that can't work with the accounts as they are now
+func (a *accounts) allows(change *pb.AccountChange) error {
+	_, exists := a.get(change.ParticipantName)
+	switch change.Type {
+	case pb.AccountChangeType_REGISTER:
+		if exists {
+			return status.Errorf(codes.AlreadyExists, "%s is already registered", change.ParticipantName)
+		}
+	case pb.AccountChangeType_BANNED, pb.AccountChangeType_UNBANNED:
+		if !exists {
+			return status.Errorf(codes.NotFound, "%s is not registered", change.ParticipantName)
+		}
+	}
+	return nil
+}
+
+// saveLocked writes the accounts file (a.mu must be held)
+func (a *accounts) saveLocked() error {
+	data, err := json.MarshalIndent(a.users, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(a.path, data, 0600)
+}
+
+// setBanned bans or unbans a registered participant
+func (a *accounts) setBanned(name string, banned bool) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	acc, exists := a.users[name]
+	if !exists {
+		return status.Errorf(codes.NotFound, "%s is not registered", name)
+	}
+	acc.Banned = banned
+	a.users[name] = acc
+	return a.saveLocked()
+}
+
+func (a *accounts) check(name, password string) error {
+	a.mu.Lock()
+	acc, exists := a.users[name]
+	a.mu.Unlock()
+
+	if !exists {
+		return status.Errorf(codes.NotFound, "%s is not registered", name)
+	}
+	if acc.Hash == "" {
+		return status.Errorf(codes.Unauthenticated, "%s logs in with a certificate", name)
+	}
+	salt, err := hex.DecodeString(acc.Salt)
+	if err != nil {
+		return err
+	}
+	hash, err := hashPassword(password, salt)
+	if err != nil {
+		return err
+	}
+	if subtle.ConstantTimeCompare([]byte(hash), []byte(acc.Hash)) != 1 {
+		return status.Errorf(codes.Unauthenticated, "wrong password for %s", name)
+	}
+	if acc.Banned {
+		return status.Errorf(codes.PermissionDenied, "%s is banned", name)
+	}
+	return nil
+}
+
+// validName checks a name with the rules of participant.ValidName
+func validName(name string) error {
+	if err := participant.ValidName(name); err != nil {
+		return status.Errorf(codes.InvalidArgument, "%v", err)
+	}
+	return nil
+}
+
+func (s *server) Register(ctx context.Context, req *pb.Credentials) (*pb.LoginResponse, error) {
+	if err := validName(req.ParticipantName); err != nil {
+		return &pb.LoginResponse{Success: false}, err
+	}
+	if err := checkCertificate(ctx, req.ParticipantName); err != nil {
+		return &pb.LoginResponse{Success: false}, err
+	}
+	if req.Password == "" {
+		return &pb.LoginResponse{Success: false}, status.Errorf(codes.InvalidArgument, "the password is empty")
+	}
+	acc, err := newAccount(req.Password)
+	if err != nil {
+		return &pb.LoginResponse{Success: false}, err
+	}
+	change := &pb.AccountChange{Type: pb.AccountChangeType_REGISTER, ParticipantName: req.ParticipantName, Salt: acc.Salt, Hash: acc.Hash}
+	if err := s.changeAccount(change); err != nil {
+		return &pb.LoginResponse{Success: false}, err
+	}
+	log.Printf("[Server] Registered %s", req.ParticipantName)
+	return s.newSession(req.ParticipantName)
+}
+
+func (s *server) Login(ctx context.Context, req *pb.Credentials) (*pb.LoginResponse, error) {
+	if err := checkCertificate(ctx, req.ParticipantName); err != nil {
+		return &pb.LoginResponse{Success: false}, err
+	}
+	if err := s.accounts.check(req.ParticipantName, req.Password); err != nil {
+		log.Printf("[Server] Login of %s failed: %v", req.ParticipantName, err)
+		return &pb.LoginResponse{Success: false}, err
+	}
+	log.Printf("[Server] %s logged in", req.ParticipantName)
+	return s.newSession(req.ParticipantName)
+}
+
+// newSession makes a token for a participant. Only its hash is kept (and goes through the Raft log),
+// so the files of the server are no good for logging in.
+func (s *server) newSession(name string) (*pb.LoginResponse, error) {
+	token := make([]byte, 32)
+	rand.Read(token)
+	change := &pb.SessionChange{Type: pb.SessionChangeType_STARTED, ParticipantName: name, TokenHash: tokenHash(hex.EncodeToString(token))}
+	if err := s.replicate(&pb.RaftEntry{Session: change}); err != nil {
+		return &pb.LoginResponse{Success: false}, err
+	}
+	return &pb.LoginResponse{Success: true, Token: hex.EncodeToString(token)}, nil
+}
+
+// endSession forgets every token of a participant
+func (s *server) endSession(name string) {
+	change := &pb.SessionChange{Type: pb.SessionChangeType_ENDED, ParticipantName: name}
+	if err := s.replicate(&pb.RaftEntry{Session: change}); err != nil {
+		log.Printf("[Server] Could not end the session of %s: %v", name, err)
+	}
+}
+
+// applySessionLocked starts or ends a session on this server (s.mu must be held)
+func (s *server) applySessionLocked(change *pb.SessionChange) {
+	switch change.Type {
+	case pb.SessionChangeType_STARTED:
+		s.sessions[change.TokenHash] = change.ParticipantName
+	case pb.SessionChangeType_ENDED:
+		for hash, owner := range s.sessions {
+			if owner == change.ParticipantName {
+				delete(s.sessions, hash)
+			}
+		}
+	}
+}
+
+func tokenHash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+type participantKey struct{}
+
+// authenticate finds the participant of the session token in the metadata. A client with a
+// certificate is the participant named in it, it does not need a token.
+func (s *server) authenticate(ctx context.Context) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	tokens := md.Get(tokenKey)
+	certified := certName(ctx)
+	if len(tokens) == 0 && certified != "" {
+		if err := s.checkCertified(certified); err != nil {
+			return nil, err
+		}
+		return context.WithValue(ctx, participantKey{}, certified), nil
+	}
+	if len(tokens) == 0 {
+		return nil, status.Errorf(codes.Unauthenticated, "log in first")
+	}
+
+	s.mu.Lock()
+	name, ok := s.sessions[tokenHash(tokens[0])]
+	s.mu.Unlock()
+	if !ok {
+		return nil, status.Errorf(codes.Unauthenticated, "the session is not valid, log in again")
+	}
+	if certified != "" && name != certified {
+		return nil, status.Errorf(codes.PermissionDenied, "the certificate is for %s, not %s", certified, name)
+	}
+	if acc, _ := s.accounts.get(name); acc.Banned {
+		return nil, status.Errorf(codes.PermissionDenied, "%s is banned", name)
+	}
+	return context.WithValue(ctx, participantKey{}, name), nil
+}
+
+// checkCertified is the login of a participant with a certificate
+func (s *server) checkCertified(name string) error {
+	if err := validName(name); err != nil {
+		return status.Errorf(codes.Unauthenticated, "the certificate is not for a participant: %v", status.Convert(err).Message())
+	}
+	acc, exists := s.accounts.get(name)
+	if !exists {
+		// registered without a password, so it can be banned and nobody registers the name with one
+		err := s.changeAccount(&pb.AccountChange{Type: pb.AccountChangeType_REGISTER, ParticipantName: name})
+		if err != nil && status.Code(err) != codes.AlreadyExists {
+			return err
+		}
+		log.Printf("[Server] Registered %s with a certificate", name)
+		acc, _ = s.accounts.get(name)
+	}
+	if acc.Banned {
+		return status.Errorf(codes.PermissionDenied, "%s is banned", name)
+	}
+	return nil
+}
+
+// changeAccount registers, bans or mutes a participant. With replicas the change goes through
+// the Raft log, and every replica applies it once it is committed.
+func (s *server) changeAccount(change *pb.AccountChange) error {
+	if s.raft == nil {
+		return s.applyAccount(change)
+	}
+	// the log is applied whatever it says, so the leader refuses what can't work first
+	if err := s.accounts.allows(change); err != nil {
+		return err
+	}
+	if err := s.replicate(&pb.RaftEntry{Account: change}); err != nil {
+		return err
+	}
+	if change.Type == pb.AccountChangeType_REGISTER {
+		if acc, _ := s.accounts.get(change.ParticipantName); acc.Salt != change.Salt || acc.Hash != change.Hash {
+			// registered by somebody else at the same time, the first one in the log has it
+			return status.Errorf(codes.AlreadyExists, "%s is already registered", change.ParticipantName)
+		}
+	}
+	return nil
+}
+
+// applyAccount makes an account change on this server
+func (s *server) applyAccount(change *pb.AccountChange) error {
+	name := change.ParticipantName
+	switch change.Type {
+	case pb.AccountChangeType_REGISTER:
+		return s.accounts.add(name, account{Salt: change.Salt, Hash: change.Hash})
+	case pb.AccountChangeType_BANNED, pb.AccountChangeType_UNBANNED:
+		return s.accounts.setBanned(name, change.Type == pb.AccountChangeType_BANNED)
+	case pb.AccountChangeType_MUTED:
+		s.mu.Lock()
+		s.moderation.muted[name] = time.UnixMilli(change.MutedUntil)
+		s.mu.Unlock()
+	case pb.AccountChangeType_UNMUTED:
+		s.mu.Lock()
+		delete(s.moderation.muted, name)
+		s.mu.Unlock()
+	}
+	return nil
+}
+
+// checkCertificate refuses a login or registration with a certificate for somebody else
+func checkCertificate(ctx context.Context, name string) error {
+	if certified := certName(ctx); certified != "" && certified != name {
+		return status.Errorf(codes.PermissionDenied, "the certificate is for %s, not %s", certified, name)
+	}
+	return nil
+}
+
+// checkSender makes sure the participant a request claims to come from is the one who is logged in
+func checkSender(ctx context.Context, claimed string) error {
+	name, _ := ctx.Value(participantKey{}).(string)
+	if name != claimed {
+		return status.Errorf(codes.PermissionDenied, "logged in as %s, not %s", name, claimed)
+	}
+	return nil
+}
+
+func needsSession(method string) bool {
+	return method != pb.ITUDatabase_Register_FullMethodName && method != pb.ITUDatabase_Login_FullMethodName
+}
+
+// checkPeer lets other servers relay (or replicate) if they know the peer secret, or show
+// a server certificate signed by our CA. Nobody else can, there is no open relay.
+func (s *server) checkPeer(ctx context.Context) error {
+	if s.federation.secret != "" {
+		md, _ := metadata.FromIncomingContext(ctx)
+		secrets := md.Get(peerSecretKey)
+		if len(secrets) > 0 && subtle.ConstantTimeCompare([]byte(secrets[0]), []byte(s.federation.secret)) == 1 {
+			return nil
+		}
+	}
+	if peerCertified(ctx) {
+		return nil
+	}
+	return status.Errorf(codes.PermissionDenied, "only peer servers can relay or replicate")
+}
+
+// checkLeader sends clients of a replica that is not the leader away, they try the next one
+func (s *server) checkLeader() error {
+	if s.raft != nil && !s.raft.isLeader() {
+		return errNotLeader
+	}
+	return nil
+}
+
+func isRaftMethod(method string) bool {
+	return method == pb.Raft_RequestVote_FullMethodName || method == pb.Raft_AppendEntries_FullMethodName
+}
+
+func (s *server) authUnary(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if isRaftMethod(info.FullMethod) {
+		if err := s.checkPeer(ctx); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+	if err := s.checkLeader(); err != nil {
+		return nil, err
+	}
+	if info.FullMethod == pb.ITUDatabase_Relay_FullMethodName {
+		if err := s.checkPeer(ctx); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+	if !needsSession(info.FullMethod) {
+		return handler(ctx, req)
+	}
+	ctx, err := s.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// authenticatedStream hands the context with the participant to the handler
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (a *authenticatedStream) Context() context.Context {
+	return a.ctx
+}
+
+func (s *server) authStream(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := s.checkLeader(); err != nil {
+		return err
+	}
+	ctx, err := s.authenticate(stream.Context())
+	if err != nil {
+		return err
+	}
+	return handler(srv, &authenticatedStream{ServerStream: stream, ctx: ctx})
+}
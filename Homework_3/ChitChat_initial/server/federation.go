@@ -0,0 +1,207 @@
+package main
+
+import (
+	pb "ITUserver/grpc"
+	"context"
+	"fmt"
+	"log"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// Federation: several servers share one conversation. Every message broadcast on a server
+// is relayed to its peers, they deliver it to their clients and pass it on to their own peers.
+// A message is known by its origin server and its sequence number there, so a server that
+// gets it a second time (on another path, or back from a peer) drops it.
+// The servers don't have to be a full mesh, a chain or a ring works too.
+
+// Peers prove they are peers with this metadata, if the server has a -peer-secret
+const peerSecretKey = "peer-secret"
+
+// How many messages of an origin we remember above its watermark. A message that is still
+// missing after this many later ones were seen is given up on, so seen stays small.
+const seenWindow = 1024
+
+type federation struct {
+	self   string // our name, as the peers know us
+	secret string
+	peers  []*peer
+	seen   map[string]*seenSeqs // origin -> sequence numbers we have (guarded by s.mu)
+}
+
+// seenSeqs are the sequence numbers of one origin we have: all up to the watermark, and the
+// ones above it that came before a missing one (a faster path, or a queue lost in a restart)
+type seenSeqs struct {
+	watermark uint64
+	above     map[uint64]bool
+}
+
+func newFederation(self string, peerAddrs []string, secret string, creds credentials.TransportCredentials) *federation {
+	f := &federation{self: self, secret: secret, seen: make(map[string]*seenSeqs)}
+	for _, addr := range peerAddrs {
+		if addr == "" || addr == self {
+			continue
+		}
+		f.peers = append(f.peers, newPeer(addr, secret, creds))
+	}
+	return f
+}
+
+// markSeen remembers a message and returns false if we had it already (s.mu must be held)
+func (f *federation) markSeen(msg *pb.BroadcastMessage) bool {
+	if msg.Origin == "" {
+		return true
+	}
+	seqs, ok := f.seen[msg.Origin]
+	if !ok {
+		seqs = &seenSeqs{above: make(map[uint64]bool)}
+		f.seen[msg.Origin] = seqs
+	}
+	return seqs.mark(msg.OriginSeq)
+}
+
+// mark remembers a sequence number and returns false if we had it already
+func (s *seenSeqs) mark(seq uint64) bool {
+	if seq <= s.watermark || s.above[seq] {
+		return false
+	}
+	s.above[seq] = true
+	if len(s.above) > seenWindow {
+		// the missing ones before the oldest we have are not coming any more
+		s.watermark = slices.Min(slices.Collect(maps.Keys(s.above))) - 1
+	}
+	for s.above[s.watermark+1] {
+		delete(s.above, s.watermark+1)
+		s.watermark++
+	}
+	return true
+}
+
+// send queues a relay to every peer that is not on its path yet. It does not block.
+func (f *federation) send(relay *pb.RelayMessage) {
+	for _, p := range f.peers {
+		if !slices.Contains(relay.Path, p.addr) {
+			p.add(relay)
+		}
+	}
+}
+
+// Relay takes a message from a peer server
+func (s *server) Relay(ctx context.Context, req *pb.RelayMessage) (*pb.RelayResponse, error) {
+	if len(s.federation.peers) == 0 {
+		return &pb.RelayResponse{Success: false}, status.Errorf(codes.PermissionDenied, "this server has no peers")
+	}
+	msg := req.Message
+	if msg == nil || msg.Origin == "" {
+		return &pb.RelayResponse{Success: false}, fmt.Errorf("the relayed message has no origin")
+	}
+	room, err := roomName(msg.Room)
+	if err != nil {
+		return &pb.RelayResponse{Success: false}, err
+	}
+
+	s.mu.Lock()
+	if msg.Origin == s.federation.self || !s.federation.markSeen(msg) {
+		s.mu.Unlock()
+		return &pb.RelayResponse{Success: true, Duplicate: true}, nil
+	}
+
+	r, ok := s.rooms[room]
+	if !ok {
+		// a room created on another server
+		r = newRoom(room)
+		s.rooms[room] = r
+	}
+	// the Lamport receive rule, across servers
+	if msg.LamportTimestamp > r.lamportClock {
+		r.lamportClock = msg.LamportTimestamp
+	}
+	r.lamportClock++
+	mergeVector(r.vector, msg.VectorClock)
+
+	local := proto.Clone(msg).(*pb.BroadcastMessage)
+	local.Room = room
+	local.LamportTimestamp = r.lamportClock
+	local.Replayed = false
+	s.broadcastLocked(local)
+	s.mu.Unlock()
+
+	log.Printf("[Server] Relayed from %s (seq %d there, path %s)", msg.Origin, msg.OriginSeq, strings.Join(req.Path, " -> "))
+	s.federation.send(&pb.RelayMessage{Message: msg, Path: append(slices.Clone(req.Path), s.federation.self)})
+	return &pb.RelayResponse{Success: true}, nil
+}
+
+// peer is another server. Relays to it are sent in order, and retried until it takes them.
+type peer struct {
+	addr   string
+	secret string
+	creds  credentials.TransportCredentials
+
+	mu    sync.Mutex
+	queue []*pb.RelayMessage
+	ready chan struct{}
+}
+
+func newPeer(addr, secret string, creds credentials.TransportCredentials) *peer {
+	p := &peer{addr: addr, secret: secret, creds: creds, ready: make(chan struct{}, 1)}
+	go p.run()
+	return p
+}
+
+func (p *peer) add(relay *pb.RelayMessage) {
+	p.mu.Lock()
+	p.queue = append(p.queue, relay)
+	p.mu.Unlock()
+	notify(p.ready)
+}
+
+func (p *peer) run() {
+	conn, err := grpc.NewClient(p.addr, grpc.WithTransportCredentials(p.creds))
+	if err != nil {
+		log.Printf("[Server] Can't use peer %s: %v", p.addr, err)
+		return
+	}
+	client := pb.NewITUDatabaseClient(conn)
+	ctx := context.Background()
+	if p.secret != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, peerSecretKey, p.secret)
+	}
+
+	backoff := 500 * time.Millisecond
+	for range p.ready {
+		for {
+			p.mu.Lock()
+			if len(p.queue) == 0 {
+				p.mu.Unlock()
+				break
+			}
+			relay := p.queue[0]
+			p.mu.Unlock()
+
+			callCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+			_, err := client.Relay(callCtx, relay)
+			cancel()
+			if err != nil {
+				log.Printf("[Server] Relay to %s failed, retrying in %v: %v", p.addr, backoff, err)
+				time.Sleep(backoff)
+				backoff = min(backoff*2, 10*time.Second)
+				continue
+			}
+			backoff = 500 * time.Millisecond
+
+			p.mu.Lock()
+			p.queue = p.queue[1:]
+			p.mu.Unlock()
+		}
+	}
+}
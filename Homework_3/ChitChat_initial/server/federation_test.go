@@ -0,0 +1,46 @@
+package main
+
+import (
+	pb "ITUserver/grpc"
+	"testing"
+)
+
+func TestMarkSeen(t *testing.T) {
+	tests := []struct {
+		name  string
+		seqs  []uint64
+		fresh []bool
+	}{
+		{"in order", []uint64{1, 2, 3}, []bool{true, true, true}},
+		{"again", []uint64{1, 2, 1, 2}, []bool{true, true, false, false}},
+		{"overtaken", []uint64{2, 1, 2, 1, 3}, []bool{true, true, false, false, true}},
+		{"gap filled late", []uint64{1, 3, 4, 2, 3}, []bool{true, true, true, true, false}},
+	}
+	for _, test := range tests {
+		f := newFederation("self", nil, "", nil)
+		for i, seq := range test.seqs {
+			got := f.markSeen(&pb.BroadcastMessage{Origin: "other", OriginSeq: seq})
+			if got != test.fresh[i] {
+				t.Errorf("%s: message %d (seq %d) fresh = %v, want %v", test.name, i, seq, got, test.fresh[i])
+			}
+		}
+	}
+}
+
+func TestSeenStaysSmall(t *testing.T) {
+	f := newFederation("self", nil, "", nil)
+	// seq 1 never comes, so nothing is below the watermark until the window is full
+	for seq := uint64(2); seq <= 3*seenWindow; seq++ {
+		f.markSeen(&pb.BroadcastMessage{Origin: "other", OriginSeq: seq})
+	}
+	seqs := f.seen["other"]
+	if len(seqs.above) > seenWindow {
+		t.Errorf("%d sequence numbers above the watermark, want at most %d", len(seqs.above), seenWindow)
+	}
+	if seqs.watermark != 3*seenWindow {
+		t.Errorf("watermark = %d, want %d", seqs.watermark, 3*seenWindow)
+	}
+	if f.markSeen(&pb.BroadcastMessage{Origin: "other", OriginSeq: 1}) {
+		t.Errorf("seq 1 came after it was given up on, and was taken")
+	}
+}
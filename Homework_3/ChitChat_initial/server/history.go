@@ -0,0 +1,134 @@
+package main
+
+import (
+	pb "ITUserver/grpc"
+	"bufio"
+	"fmt"
+	"os"
+
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// history is an append-only log of every broadcast message, one JSON object per line.
+// It is read back when the server starts, so it survives restarts.
+type history struct {
+	file     *os.File
+	messages []*pb.BroadcastMessage
+}
+
+func openHistory(path string) (*history, error) {
+	h := &history{}
+
+	if data, err := os.Open(path); err == nil {
+		scanner := bufio.NewScanner(data)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for line := 1; scanner.Scan(); line++ {
+			msg := &pb.BroadcastMessage{}
+			if err := protojson.Unmarshal(scanner.Bytes(), msg); err != nil {
+				data.Close()
+				return nil, fmt.Errorf("%s line %d: %v", path, line, err)
+			}
+			if msg.Room == "" {
+				// written before there were rooms
+				msg.Room = generalRoom
+			}
+			h.messages = append(h.messages, msg)
+		}
+		data.Close()
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+	if err != nil {
+		return nil, err
+	}
+	h.file = file
+	return h, nil
+}
+
+// append writes the message to the end of the log
+func (h *history) append(msg *pb.BroadcastMessage) error {
+	line, err := protojson.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	if _, err := h.file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	h.messages = append(h.messages, msg)
+	return nil
+}
+
+// since returns the messages of a room after the Lamport time, in the order they were broadcast
+func (h *history) since(room string, lamport int64) []*pb.BroadcastMessage {
+	var result []*pb.BroadcastMessage
+	for _, msg := range h.messages {
+		if msg.Room == room && msg.LamportTimestamp > lamport {
+			result = append(result, msg)
+		}
+	}
+	return result
+}
+
+// afterSeq returns the messages after a sequence number in the given rooms
+func (h *history) afterSeq(seq uint64, rooms map[string]bool) []*pb.BroadcastMessage {
+	var result []*pb.BroadcastMessage
+	for _, msg := range h.messages {
+		if msg.Seq > seq && rooms[msg.Room] {
+			result = append(result, msg)
+		}
+	}
+	return result
+}
+
+// lastSeq is the sequence number of the last message in the log
+func (h *history) lastSeq() uint64 {
+	var last uint64
+	for _, msg := range h.messages {
+		last = max(last, msg.Seq)
+	}
+	return last
+}
+
+// lastLamport is the highest Lamport time of a room in the log (0 if there is none)
+func (h *history) lastLamport(room string) int64 {
+	var last int64
+	for _, msg := range h.messages {
+		if msg.Room == room {
+			last = max(last, msg.LamportTimestamp)
+		}
+	}
+	return last
+}
+
+// vector is the merge of the vector timestamps of the chat messages of a room
+func (h *history) vector(room string) map[string]int64 {
+	vector := make(map[string]int64)
+	for _, msg := range h.messages {
+		if msg.Room == room && msg.Sender != "" {
+			mergeVector(vector, msg.VectorClock)
+		}
+	}
+	return vector
+}
+
+// rooms lists every room that has messages in the log
+func (h *history) rooms() []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, msg := range h.messages {
+		if !seen[msg.Room] {
+			seen[msg.Room] = true
+			names = append(names, msg.Room)
+		}
+	}
+	return names
+}
+
+func (h *history) close() error {
+	return h.file.Close()
+}
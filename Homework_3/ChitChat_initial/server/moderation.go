@@ -0,0 +1,168 @@
+package main
+
+import (
+	pb "ITUserver/grpc"
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// moderation is who may moderate, who is muted and how fast everybody may publish (guarded by s.mu)
+type moderation struct {
+	admins  map[string]bool
+	muted   map[string]time.Time // until when
+	rate    float64              // messages per second, 0 means no limit
+	burst   float64              // messages that may be sent at once
+	buckets map[string]*bucket
+}
+
+// bucket is a token bucket: it holds up to burst tokens, gets rate tokens every second,
+// and a message takes one
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func newModeration(admins []string, rate float64, burst int) *moderation {
+	m := &moderation{
+		admins:  make(map[string]bool),
+		muted:   make(map[string]time.Time),
+		rate:    rate,
+		burst:   float64(max(burst, 1)),
+		buckets: make(map[string]*bucket),
+	}
+	for _, name := range admins {
+		if name = strings.TrimSpace(name); name != "" {
+			m.admins[name] = true
+		}
+	}
+	return m
+}
+
+// refusalLocked is why a participant may not publish now, or "" if it may.
+// A message that is allowed takes a token from its bucket (s.mu must be held).
+func (s *server) refusalLocked(name string) string {
+	m := s.moderation
+	if until, muted := m.muted[name]; muted {
+		if left := time.Until(until); left > 0 {
+			return fmt.Sprintf("you are muted for another %v", left.Round(time.Second))
+		}
+		delete(m.muted, name)
+	}
+	if m.rate <= 0 {
+		return ""
+	}
+
+	now := time.Now()
+	b, ok := m.buckets[name]
+	if !ok {
+		b = &bucket{tokens: m.burst, last: now}
+		m.buckets[name] = b
+	}
+	b.tokens = min(m.burst, b.tokens+now.Sub(b.last).Seconds()*m.rate)
+	b.last = now
+	if b.tokens < 1 {
+		wait := time.Duration((1 - b.tokens) / m.rate * float64(time.Second))
+		return fmt.Sprintf("you are sending too fast, wait %v", wait.Round(100*time.Millisecond))
+	}
+	b.tokens--
+	return ""
+}
+
+func because(reason string) string {
+	if reason == "" {
+		return ""
+	}
+	return ": " + reason
+}
+
+func (s *server) Moderate(ctx context.Context, req *pb.ModerationRequest) (*pb.ModerationResponse, error) {
+	admin := req.ParticipantName
+	if err := checkSender(ctx, admin); err != nil {
+		return &pb.ModerationResponse{Success: false}, err
+	}
+	if !s.moderation.admins[admin] {
+		return &pb.ModerationResponse{Success: false}, status.Errorf(codes.PermissionDenied, "only admins can moderate")
+	}
+	if err := validName(req.Target); err != nil {
+		return &pb.ModerationResponse{Success: false}, err
+	}
+
+	var err error
+	switch req.Action {
+	case pb.ModerationAction_KICK:
+		err = s.kick(admin, req.Target, "kicked", req.Reason)
+	case pb.ModerationAction_MUTE:
+		err = s.mute(admin, req.Target, time.Duration(req.DurationSeconds)*time.Second, req.Reason)
+	case pb.ModerationAction_UNMUTE:
+		err = s.changeAccount(&pb.AccountChange{Type: pb.AccountChangeType_UNMUTED, ParticipantName: req.Target})
+	case pb.ModerationAction_BAN:
+		if err = s.changeAccount(&pb.AccountChange{Type: pb.AccountChangeType_BANNED, ParticipantName: req.Target}); err == nil {
+			if kickErr := s.kick(admin, req.Target, "banned", req.Reason); status.Code(kickErr) != codes.NotFound {
+				err = kickErr
+			}
+			// kick only ends the session of a participant in the chat, a banned one that is
+			// logged in but not joined must lose its token too
+			s.endSession(req.Target)
+		}
+	case pb.ModerationAction_UNBAN:
+		err = s.changeAccount(&pb.AccountChange{Type: pb.AccountChangeType_UNBANNED, ParticipantName: req.Target})
+	default:
+		err = status.Errorf(codes.InvalidArgument, "unknown moderation action %v", req.Action)
+	}
+	if err != nil {
+		return &pb.ModerationResponse{Success: false}, err
+	}
+	log.Printf("[Server] %s: %v %s%s", admin, req.Action, req.Target, because(req.Reason))
+	return &pb.ModerationResponse{Success: true}, nil
+}
+
+// kick ends the stream and the session of a participant, and tells everybody
+func (s *server) kick(admin, target, verb, reason string) error {
+	s.mu.Lock()
+	box, online := s.clients[target]
+	_, detached := s.detached[target]
+	if !online && !detached {
+		s.mu.Unlock()
+		return status.Errorf(codes.NotFound, "%s is not in the chat", target)
+	}
+	if online {
+		// the client gets this as the error of its stream, and does not reconnect
+		box.close(status.Errorf(codes.PermissionDenied, "you were %s by %s%s", verb, admin, because(reason)))
+	}
+	s.mu.Unlock()
+
+	s.removeClient(target)
+	s.endSession(target)
+	s.announce(generalRoom, pb.MessageType_LEAVE, nil, func(lamport int64) string {
+		return fmt.Sprintf("Participant %s was %s by %s at Lamport time %d%s", target, verb, admin, lamport, because(reason))
+	})
+	return nil
+}
+
+// mute refuses the messages of a participant for a while, and tells it why
+func (s *server) mute(admin, target string, duration time.Duration, reason string) error {
+	if duration <= 0 {
+		return status.Errorf(codes.InvalidArgument, "mute needs a duration")
+	}
+	until := time.Now().Add(duration).UnixMilli()
+	if err := s.changeAccount(&pb.AccountChange{Type: pb.AccountChangeType_MUTED, ParticipantName: target, MutedUntil: until}); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	box, online := s.clients[target]
+	s.mu.Unlock()
+
+	if online {
+		box.push(&pb.BroadcastMessage{
+			Content: fmt.Sprintf("You were muted by %s for %v%s", admin, duration, because(reason)),
+			Type:    pb.MessageType_NOTICE,
+		})
+	}
+	return nil
+}
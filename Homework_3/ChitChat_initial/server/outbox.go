@@ -0,0 +1,216 @@
+package main
+
+import (
+	pb "ITUserver/grpc"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// How many messages can wait for a client before its slow consumer policy kicks in
+const outboxSize = 100
+
+// outbox is the queue of messages waiting to be sent on a client's stream
+type outbox struct {
+	name    string
+	policy  pb.SlowConsumerPolicy
+	timeout time.Duration // how long BLOCK waits
+
+	mu      sync.Mutex
+	queue   []*pb.BroadcastMessage
+	missed  int // dropped since the client was last told
+	dropped int // dropped in total
+	closed  bool
+	reason  error // why the stream has to end, nil if it just ends
+
+	ready chan struct{} // there are messages, or the outbox was closed
+	space chan struct{} // messages were taken out
+}
+
+func newOutbox(name string, policy pb.SlowConsumerPolicy, timeout time.Duration) *outbox {
+	return &outbox{
+		name:    name,
+		policy:  policy,
+		timeout: timeout,
+		ready:   make(chan struct{}, 1),
+		space:   make(chan struct{}, 1),
+	}
+}
+
+func notify(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
+
+// push queues a message. It returns an error if the message did not make it into the queue.
+func (o *outbox) push(msg *pb.BroadcastMessage) error {
+	deadline := time.Now().Add(o.timeout)
+	for {
+		o.mu.Lock()
+		if o.closed {
+			o.mu.Unlock()
+			return fmt.Errorf("%s is not connected", o.name)
+		}
+		if len(o.queue) < outboxSize {
+			o.queue = append(o.queue, msg)
+			o.mu.Unlock()
+			notify(o.ready)
+			return nil
+		}
+
+		switch o.policy {
+		case pb.SlowConsumerPolicy_DROP_OLDEST:
+			// the sender of a private message was told it was delivered, so the oldest
+			// broadcast message goes instead (or the new one if there is none)
+			if i := slices.IndexFunc(o.queue, droppable); i >= 0 {
+				o.queue = append(slices.Delete(o.queue, i, i+1), msg)
+			} else if !droppable(msg) {
+				o.mu.Unlock()
+				return fmt.Errorf("%s has too many private messages waiting", o.name)
+			}
+			o.droppedLocked()
+			o.mu.Unlock()
+			return nil
+
+		case pb.SlowConsumerPolicy_DISCONNECT:
+			o.droppedLocked()
+			o.closeLocked(status.Errorf(codes.ResourceExhausted, "you could not keep up with the messages"))
+			o.mu.Unlock()
+			return fmt.Errorf("%s could not keep up and was disconnected", o.name)
+
+		default: // BLOCK
+			o.mu.Unlock()
+			wait := time.Until(deadline)
+			if wait > 0 {
+				timer := time.NewTimer(wait)
+				select {
+				case <-o.space:
+				case <-timer.C:
+				}
+				timer.Stop()
+				continue
+			}
+			o.mu.Lock()
+			o.droppedLocked()
+			o.mu.Unlock()
+			return fmt.Errorf("%s could not keep up, gave up after %v", o.name, o.timeout)
+		}
+	}
+}
+
+// droppable is false for private messages, drop-oldest keeps them
+func droppable(msg *pb.BroadcastMessage) bool {
+	return msg.Type != pb.MessageType_PRIVATE
+}
+
+func (o *outbox) droppedLocked() {
+	o.missed++
+	o.dropped++
+	log.Printf("[Server] %s is too slow, dropped a message (%d dropped in total)", o.name, o.dropped)
+}
+
+// take empties the queue. missed is how many messages were dropped since the last call,
+// and done is true (with the reason) if the stream has to end after sending msgs.
+func (o *outbox) take() (msgs []*pb.BroadcastMessage, missed int, done bool, reason error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	msgs, o.queue = o.queue, nil
+	missed, o.missed = o.missed, 0
+	notify(o.space)
+	return msgs, missed, o.closed, o.reason
+}
+
+// close ends the stream once the queued messages are sent, with reason as its error
+func (o *outbox) close(reason error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.closeLocked(reason)
+}
+
+func (o *outbox) closeLocked(reason error) {
+	if o.closed {
+		return
+	}
+	o.closed = true
+	o.reason = reason
+	notify(o.ready)
+	// a blocked push finds out it is closed
+	notify(o.space)
+}
+
+func (o *outbox) droppedTotal() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.dropped
+}
+
+// notice tells a client how many messages it missed
+func missedNotice(missed int) *pb.BroadcastMessage {
+	return &pb.BroadcastMessage{
+		Content: fmt.Sprintf("You missed %d messages because you could not keep up", missed),
+		Type:    pb.MessageType_NOTICE,
+	}
+}
+
+// parsePolicy reads the name of a slow consumer policy, like "drop-oldest"
+func parsePolicy(name string) (pb.SlowConsumerPolicy, error) {
+	value, ok := pb.SlowConsumerPolicy_value[strings.ToUpper(strings.ReplaceAll(name, "-", "_"))]
+	if !ok || value == int32(pb.SlowConsumerPolicy_SERVER_DEFAULT) {
+		return 0, fmt.Errorf("unknown slow consumer policy %q (drop-oldest, disconnect or block)", name)
+	}
+	return pb.SlowConsumerPolicy(value), nil
+}
+
+// dispatch is a message and the outboxes it goes to
+type dispatch struct {
+	msg *pb.BroadcastMessage
+	to  []*outbox
+}
+
+// dispatcher pushes the broadcast messages into the outboxes, in the order they were broadcast.
+// It runs without s.mu, so a client with the BLOCK policy does not stop the whole server,
+// only the delivery of the next messages.
+type dispatcher struct {
+	mu      sync.Mutex
+	pending []dispatch
+	ready   chan struct{}
+}
+
+func newDispatcher() *dispatcher {
+	d := &dispatcher{ready: make(chan struct{}, 1)}
+	go d.run()
+	return d
+}
+
+// add never blocks, so it can be called with s.mu held
+func (d *dispatcher) add(msg *pb.BroadcastMessage, to []*outbox) {
+	d.mu.Lock()
+	d.pending = append(d.pending, dispatch{msg: msg, to: to})
+	d.mu.Unlock()
+	notify(d.ready)
+}
+
+func (d *dispatcher) run() {
+	for range d.ready {
+		d.mu.Lock()
+		pending := d.pending
+		d.pending = nil
+		d.mu.Unlock()
+
+		for _, p := range pending {
+			for _, o := range p.to {
+				if err := o.push(p.msg); err != nil {
+					log.Printf("[Server] Not delivered: %v", err)
+				}
+			}
+		}
+	}
+}
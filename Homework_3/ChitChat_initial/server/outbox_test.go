@@ -0,0 +1,141 @@
+package main
+
+import (
+	pb "ITUserver/grpc"
+	"slices"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func chat(content string) *pb.BroadcastMessage {
+	return &pb.BroadcastMessage{Content: content, Type: pb.MessageType_CHAT}
+}
+
+func private(content string) *pb.BroadcastMessage {
+	return &pb.BroadcastMessage{Content: content, Type: pb.MessageType_PRIVATE}
+}
+
+func contents(msgs []*pb.BroadcastMessage) []string {
+	var out []string
+	for _, msg := range msgs {
+		out = append(out, msg.Content)
+	}
+	return out
+}
+
+func TestOutboxFull(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy pb.SlowConsumerPolicy
+		first  *pb.BroadcastMessage // the oldest message of the full queue
+		rest   *pb.BroadcastMessage // the other outboxSize-1 messages
+		push   *pb.BroadcastMessage
+		fails  bool
+		head   []string // the first two messages in the queue afterwards
+		tail   string   // the last one
+		missed int
+		closed bool
+		code   codes.Code // of the reason the stream ends with
+	}{
+		{
+			name:   "drop-oldest drops the oldest",
+			policy: pb.SlowConsumerPolicy_DROP_OLDEST,
+			first:  chat("old"), rest: chat("queued"), push: chat("new"),
+			head: []string{"queued", "queued"}, tail: "new", missed: 1,
+		},
+		{
+			name:   "drop-oldest keeps private messages",
+			policy: pb.SlowConsumerPolicy_DROP_OLDEST,
+			first:  private("secret"), rest: chat("queued"), push: chat("new"),
+			head: []string{"secret", "queued"}, tail: "new", missed: 1,
+		},
+		{
+			name:   "drop-oldest drops a new broadcast message when all are private",
+			policy: pb.SlowConsumerPolicy_DROP_OLDEST,
+			first:  private("secret"), rest: private("secret"), push: chat("new"),
+			head: []string{"secret", "secret"}, tail: "secret", missed: 1,
+		},
+		{
+			name:   "drop-oldest refuses a private message when all are private",
+			policy: pb.SlowConsumerPolicy_DROP_OLDEST,
+			first:  private("secret"), rest: private("secret"), push: private("new"),
+			fails: true,
+			head:  []string{"secret", "secret"}, tail: "secret",
+		},
+		{
+			name:   "disconnect closes the stream",
+			policy: pb.SlowConsumerPolicy_DISCONNECT,
+			first:  chat("old"), rest: chat("queued"), push: chat("new"),
+			fails: true,
+			head:  []string{"old", "queued"}, tail: "queued", missed: 1,
+			closed: true, code: codes.ResourceExhausted,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			o := newOutbox("Alice", test.policy, time.Second)
+			if err := o.push(test.first); err != nil {
+				t.Fatal(err)
+			}
+			for range outboxSize - 1 {
+				if err := o.push(test.rest); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := o.push(test.push); (err != nil) != test.fails {
+				t.Errorf("push error %v, want an error: %v", err, test.fails)
+			}
+			msgs, missed, closed, reason := o.take()
+			if len(msgs) != outboxSize {
+				t.Fatalf("%d messages queued, want %d", len(msgs), outboxSize)
+			}
+			if got := contents(msgs[:2]); !slices.Equal(got, test.head) {
+				t.Errorf("queue starts with %v, want %v", got, test.head)
+			}
+			if got := msgs[len(msgs)-1].Content; got != test.tail {
+				t.Errorf("queue ends with %q, want %q", got, test.tail)
+			}
+			if missed != test.missed {
+				t.Errorf("missed %d, want %d", missed, test.missed)
+			}
+			if closed != test.closed || status.Code(reason) != test.code {
+				t.Errorf("closed %v with %v, want %v with %v", closed, reason, test.closed, test.code)
+			}
+		})
+	}
+}
+
+func TestOutboxBlock(t *testing.T) {
+	o := newOutbox("Alice", pb.SlowConsumerPolicy_BLOCK, 50*time.Millisecond)
+	for range outboxSize {
+		o.push(chat("queued"))
+	}
+
+	// nobody takes the messages, push gives up after the timeout
+	start := time.Now()
+	if err := o.push(chat("late")); err == nil {
+		t.Errorf("push into a full queue worked")
+	}
+	if waited := time.Since(start); waited < 50*time.Millisecond {
+		t.Errorf("push gave up after %v, before the timeout", waited)
+	}
+
+	// once the client takes them, a blocked push goes through
+	o.timeout = time.Second
+	done := make(chan error)
+	go func() { done <- o.push(chat("new")) }()
+	time.Sleep(10 * time.Millisecond)
+	if _, missed, _, _ := o.take(); missed != 1 {
+		t.Errorf("missed %d, want 1", missed)
+	}
+	if err := <-done; err != nil {
+		t.Errorf("push after take: %v", err)
+	}
+	if msgs, _, _, _ := o.take(); !slices.Equal(contents(msgs), []string{"new"}) {
+		t.Errorf("queue %v, want [new]", contents(msgs))
+	}
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	pb "ITUserver/grpc"
+	"context"
+	"fmt"
+	"log"
+	"maps"
+	"slices"
+	"time"
+)
+
+const (
+	// clients send a heartbeat every 5 seconds, a client we did not hear from for this long is idle
+	idleAfter = 15 * time.Second
+	// a client that said it is typing and did not say it again is not typing anymore after this
+	typingTimeout = 10 * time.Second
+	// presence events for a watcher that is this far behind are dropped, they are only hints
+	presenceQueueSize = 64
+)
+
+// presence is who is online, idle or typing (guarded by s.mu)
+type presence struct {
+	status    map[string]pb.PresenceStatus
+	heartbeat map[string]time.Time
+	typing    map[string]typing
+	watchers  map[chan *pb.PresenceEvent]bool
+}
+
+type typing struct {
+	room  string
+	until time.Time
+}
+
+func newPresence() *presence {
+	return &presence{
+		status:    make(map[string]pb.PresenceStatus),
+		heartbeat: make(map[string]time.Time),
+		typing:    make(map[string]typing),
+		watchers:  make(map[chan *pb.PresenceEvent]bool),
+	}
+}
+
+// eventLocked is the current presence of a participant (s.mu must be held)
+func (s *server) eventLocked(name string) *pb.PresenceEvent {
+	t, typing := s.presence.typing[name]
+	return &pb.PresenceEvent{ParticipantName: name, Status: s.presence.status[name], Typing: typing, Room: t.room}
+}
+
+// emitLocked sends an event to every presence stream, without waiting for slow ones (s.mu must be held)
+func (s *server) emitLocked(ev *pb.PresenceEvent) {
+	log.Printf("[Server] Presence of %s: %v (typing: %v)", ev.ParticipantName, ev.Status, ev.Typing)
+	for ch := range s.presence.watchers {
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+}
+
+// setStatusLocked changes the status of a participant and tells the watchers (s.mu must be held)
+func (s *server) setStatusLocked(name string, status pb.PresenceStatus) {
+	if s.presence.status[name] == status {
+		return
+	}
+	s.presence.status[name] = status
+	if status == pb.PresenceStatus_ONLINE {
+		s.presence.heartbeat[name] = time.Now()
+	}
+	if status == pb.PresenceStatus_OFFLINE {
+		delete(s.presence.status, name)
+		delete(s.presence.heartbeat, name)
+		delete(s.presence.typing, name)
+	}
+	s.emitLocked(&pb.PresenceEvent{ParticipantName: name, Status: status})
+}
+
+// stopTypingLocked is called when a participant sent its message, or did not say it is typing for a while (s.mu must be held)
+func (s *server) stopTypingLocked(name string) {
+	if _, typing := s.presence.typing[name]; !typing {
+		return
+	}
+	delete(s.presence.typing, name)
+	s.emitLocked(s.eventLocked(name))
+}
+
+// watchPresence makes the clients without heartbeats idle, and ends typing that was not renewed
+func (s *server) watchPresence() {
+	for range time.Tick(time.Second) {
+		s.mu.Lock()
+		for name := range s.clients {
+			if s.presence.status[name] == pb.PresenceStatus_ONLINE && time.Since(s.presence.heartbeat[name]) > idleAfter {
+				s.setStatusLocked(name, pb.PresenceStatus_IDLE)
+			}
+		}
+		for name, t := range s.presence.typing {
+			if time.Now().After(t.until) {
+				s.stopTypingLocked(name)
+			}
+		}
+		s.mu.Unlock()
+	}
+}
+
+func (s *server) Heartbeat(ctx context.Context, req *pb.HeartbeatRequest) (*pb.HeartbeatResponse, error) {
+	name := req.ParticipantName
+	if err := checkSender(ctx, name); err != nil {
+		return &pb.HeartbeatResponse{Success: false}, err
+	}
+	room := generalRoom
+	if req.Typing {
+		var err error
+		if room, err = roomName(req.Room); err != nil {
+			return &pb.HeartbeatResponse{Success: false}, err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, online := s.clients[name]; !online {
+		return &pb.HeartbeatResponse{Success: false}, fmt.Errorf("%s has not joined the chat", name)
+	}
+	s.presence.heartbeat[name] = time.Now()
+	s.setStatusLocked(name, pb.PresenceStatus_ONLINE)
+
+	if !req.Typing {
+		s.stopTypingLocked(name)
+		return &pb.HeartbeatResponse{Success: true}, nil
+	}
+	old, wasTyping := s.presence.typing[name]
+	s.presence.typing[name] = typing{room: room, until: time.Now().Add(typingTimeout)}
+	if !wasTyping || old.room != room {
+		s.emitLocked(s.eventLocked(name))
+	}
+	return &pb.HeartbeatResponse{Success: true}, nil
+}
+
+func (s *server) ListParticipants(ctx context.Context, req *pb.ListParticipantsRequest) (*pb.ListParticipantsResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	resp := &pb.ListParticipantsResponse{}
+	for _, name := range s.participantsLocked() {
+		ev := s.eventLocked(name)
+		resp.Participants = append(resp.Participants, &pb.ParticipantInfo{
+			Name:   name,
+			Status: ev.Status,
+			Typing: ev.Typing,
+			Rooms:  slices.Sorted(maps.Keys(s.memberOfLocked(name))),
+		})
+	}
+	return resp, nil
+}
+
+// participantsLocked are the connected participants and the ones that may still resume, by name (s.mu must be held)
+func (s *server) participantsLocked() []string {
+	names := slices.Collect(maps.Keys(s.clients))
+	for name := range s.detached {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Presence streams the changes, starting with the presence of everybody who is there
+func (s *server) Presence(req *pb.PresenceRequest, stream pb.ITUDatabase_PresenceServer) error {
+	if err := checkSender(stream.Context(), req.ParticipantName); err != nil {
+		return err
+	}
+	events := make(chan *pb.PresenceEvent, presenceQueueSize)
+
+	s.mu.Lock()
+	var current []*pb.PresenceEvent
+	for _, name := range s.participantsLocked() {
+		current = append(current, s.eventLocked(name))
+	}
+	s.presence.watchers[events] = true
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.presence.watchers, events)
+		s.mu.Unlock()
+	}()
+
+	for _, ev := range current {
+		if err := stream.Send(ev); err != nil {
+			return err
+		}
+	}
+	for {
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				// the server shuts down or is no longer the leader
+				return nil
+			}
+			if err := stream.Send(ev); err != nil {
+				return err
+			}
+		case <-stream.Context().Done():
+			return nil
+		}
+	}
+}
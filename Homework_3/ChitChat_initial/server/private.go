@@ -0,0 +1,53 @@
+package main
+
+import (
+	pb "ITUserver/grpc"
+	"context"
+	"fmt"
+	"log"
+)
+
+// SendPrivate hands a message to the recipient's stream only. It is not stored in the history.
+func (s *server) SendPrivate(ctx context.Context, msg *pb.PrivateMessage) (*pb.PrivateResponse, error) {
+	if err := checkSender(ctx, msg.ParticipantName); err != nil {
+		return &pb.PrivateResponse{Success: false}, err
+	}
+	if len(msg.Content) > 128 {
+		return &pb.PrivateResponse{Success: false}, fmt.Errorf("message exceeds 128 characters")
+	}
+
+	s.mu.Lock()
+	if _, online := s.clients[msg.ParticipantName]; !online {
+		s.mu.Unlock()
+		return &pb.PrivateResponse{Success: false}, fmt.Errorf("%s has not joined the chat", msg.ParticipantName)
+	}
+	if refusal := s.refusalLocked(msg.ParticipantName); refusal != "" {
+		s.mu.Unlock()
+		return &pb.PrivateResponse{Success: false, Recipient: msg.Recipient}, fmt.Errorf("message refused, %s", refusal)
+	}
+	box, online := s.clients[msg.Recipient]
+	if !online {
+		s.mu.Unlock()
+		return &pb.PrivateResponse{Success: false, Recipient: msg.Recipient}, fmt.Errorf("%s is not online", msg.Recipient)
+	}
+
+	if msg.Lamport > s.privateClock {
+		s.privateClock = msg.Lamport
+	}
+	s.privateClock++
+	lamportTime := s.privateClock
+	s.mu.Unlock()
+
+	// without the lock, a full outbox may make us wait
+	err := box.push(&pb.BroadcastMessage{
+		Content:          fmt.Sprintf("%s: %s", msg.ParticipantName, msg.Content),
+		LamportTimestamp: lamportTime,
+		Type:             pb.MessageType_PRIVATE,
+	})
+	if err != nil {
+		return &pb.PrivateResponse{Success: false, Recipient: msg.Recipient}, fmt.Errorf("message not delivered: %v", err)
+	}
+
+	log.Printf("[Server] Private message from %s to %s (Lamport: %d)", msg.ParticipantName, msg.Recipient, lamportTime)
+	return &pb.PrivateResponse{Success: true, Recipient: msg.Recipient, LamportTimestamp: lamportTime}, nil
+}
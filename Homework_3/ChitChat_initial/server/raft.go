@@ -0,0 +1,709 @@
+package main
+
+import (
+	pb "ITUserver/grpc"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/backoff"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// Replicated mode: three or five replicas keep the broadcast messages in a log replicated with Raft.
+// Only the leader takes calls from clients. A message is broadcast (stored in the history and sent
+// to the clients) once it is committed, that is once a majority of the replicas have it, and every
+// replica applies the committed messages in the same order. When the leader dies the others elect
+// a new one, and the clients move over to it.
+
+const (
+	heartbeatInterval  = 100 * time.Millisecond
+	minElectionTimeout = 300 * time.Millisecond
+	maxElectionTimeout = 600 * time.Millisecond
+	maxAppendEntries   = 64
+	commitTimeout      = 5 * time.Second
+)
+
+// gRPC waits up to two minutes before reconnecting to a server it lost, far too long here
+var peerBackoff = backoff.Config{BaseDelay: 100 * time.Millisecond, Multiplier: 1.6, Jitter: 0.2, MaxDelay: time.Second}
+
+type raftRole int
+
+const (
+	follower raftRole = iota
+	candidate
+	leader
+)
+
+func (r raftRole) String() string {
+	return [...]string{"follower", "candidate", "leader"}[r]
+}
+
+// errNotLeader is what clients get from a replica that is not the leader, they try the next one
+var errNotLeader = status.Errorf(codes.Unavailable, "this replica is not the leader")
+
+type raft struct {
+	pb.UnimplementedRaftServer
+
+	self    string
+	peers   map[string]pb.RaftClient
+	secret  string
+	timeout time.Duration // election timeout, random per replica
+
+	mu          sync.Mutex
+	role        raftRole
+	term        int64
+	votedFor    string
+	leaderID    string
+	log         []*pb.RaftEntry // log[0] is a placeholder, so the first entry has index 1
+	commitIndex int64
+	lastApplied int64
+	nextIndex   map[string]int64
+	matchIndex  map[string]int64
+	ready       bool          // a new leader takes messages once it caught up with its log
+	heardFrom   time.Time     // last time a leader or candidate kept us from starting an election
+	applied     chan struct{} // closed and replaced when entries are applied
+	commit      chan struct{}
+	replicate   map[string]chan struct{}
+
+	statePath string
+	logFile   *os.File
+
+	// apply gets the committed messages and applyChange the other entries (account, room and
+	// session changes), in log order. onLeader is called when a new leader applied the entries of
+	// the old ones, before it takes calls, and onFollower when a leader steps down.
+	apply       func(msg *pb.BroadcastMessage)
+	applyChange func(entry *pb.RaftEntry)
+	onLeader    func()
+	onFollower  func()
+}
+
+// raftState is what has to survive a restart besides the log
+type raftState struct {
+	Term     int64  `json:"term"`
+	VotedFor string `json:"voted_for"`
+}
+
+func newRaft(self string, replicas []string, secret string, dir string, creds credentials.TransportCredentials) (*raft, error) {
+	r := &raft{
+		self:       self,
+		peers:      make(map[string]pb.RaftClient),
+		secret:     secret,
+		timeout:    minElectionTimeout + rand.N(maxElectionTimeout-minElectionTimeout),
+		log:        []*pb.RaftEntry{{}},
+		nextIndex:  make(map[string]int64),
+		matchIndex: make(map[string]int64),
+		heardFrom:  time.Now(),
+		applied:    make(chan struct{}),
+		commit:     make(chan struct{}, 1),
+		replicate:  make(map[string]chan struct{}),
+		statePath:  filepath.Join(dir, "raft_state.json"),
+	}
+	for _, addr := range replicas {
+		if addr == "" || addr == self {
+			continue
+		}
+		conn, err := grpc.NewClient(addr,
+			grpc.WithTransportCredentials(creds),
+			// a replica that comes back has to hear from the leader before it starts an election
+			grpc.WithConnectParams(grpc.ConnectParams{Backoff: peerBackoff}),
+		)
+		if err != nil {
+			return nil, err
+		}
+		r.peers[addr] = pb.NewRaftClient(conn)
+		r.replicate[addr] = make(chan struct{}, 1)
+	}
+
+	if data, err := os.ReadFile(r.statePath); err == nil {
+		var state raftState
+		if err := json.Unmarshal(data, &state); err != nil {
+			return nil, fmt.Errorf("%s: %v", r.statePath, err)
+		}
+		r.term, r.votedFor = state.Term, state.VotedFor
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err := r.loadLog(filepath.Join(dir, "raft_log.jsonl")); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// start runs the election timer, the replication to every peer and the applier
+func (r *raft) start() {
+	go r.electionTimer()
+	go r.applier()
+	for addr := range r.peers {
+		go r.replicator(addr)
+	}
+}
+
+func (r *raft) loadLog(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	// a crash while writing leaves half a line at the end, it was never acknowledged
+	valid := 0
+	for line := 1; valid < len(data); line++ {
+		end := bytes.IndexByte(data[valid:], '\n')
+		if end < 0 {
+			log.Printf("[Raft] %s line %d was not written completely, dropping it", path, line)
+			break
+		}
+		entry := &pb.RaftEntry{}
+		if err := protojson.Unmarshal(data[valid:valid+end], entry); err != nil {
+			return fmt.Errorf("%s line %d: %v", path, line, err)
+		}
+		r.log = append(r.log, entry)
+		valid += end + 1
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+	if err != nil {
+		return err
+	}
+	if valid < len(data) {
+		if err := file.Truncate(int64(valid)); err != nil {
+			file.Close()
+			return err
+		}
+	}
+	r.logFile = file
+	return nil
+}
+
+// persistStateLocked writes the term and vote, before we answer anybody (r.mu must be held).
+// It is on the disk when this returns: the file is synced, and replaces the old one in one step.
+func (r *raft) persistStateLocked() error {
+	data, _ := json.Marshal(raftState{Term: r.term, VotedFor: r.votedFor})
+	err := writeSynced(r.statePath, data)
+	if err != nil {
+		log.Printf("[Raft] Could not write %s: %v", r.statePath, err)
+	}
+	return err
+}
+
+// writeSynced writes a file through a temporary one, so a crash leaves the old or the new file
+func writeSynced(path string, data []byte) error {
+	tmp := path + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0666)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(data)
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// persistEntriesLocked appends entries to the log file and syncs it, the entries are
+// on the disk before we tell anybody we have them (r.mu must be held)
+func (r *raft) persistEntriesLocked(entries []*pb.RaftEntry) error {
+	var buf []byte
+	for _, entry := range entries {
+		line, err := protojson.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		buf = append(append(buf, line...), '\n')
+	}
+	_, err := r.logFile.Write(buf)
+	if err == nil {
+		err = r.logFile.Sync()
+	}
+	if err != nil {
+		log.Printf("[Raft] Could not write the log: %v", err)
+	}
+	return err
+}
+
+// truncateLocked throws away the entries from index on, they conflict with the leader's (r.mu must be held)
+func (r *raft) truncateLocked(index int64) error {
+	r.log = r.log[:index]
+	if err := r.logFile.Truncate(0); err != nil {
+		log.Printf("[Raft] Could not truncate the log: %v", err)
+		return err
+	}
+	return r.persistEntriesLocked(r.log[1:])
+}
+
+func (r *raft) lastLocked() (index int64, term int64) {
+	index = int64(len(r.log) - 1)
+	return index, r.log[index].Term
+}
+
+func (r *raft) majority() int {
+	return (len(r.peers)+1)/2 + 1
+}
+
+func notifyAll(chans map[string]chan struct{}) {
+	for _, ch := range chans {
+		notify(ch)
+	}
+}
+
+// becomeFollowerLocked moves to a newer term, or steps down (r.mu must be held)
+func (r *raft) becomeFollowerLocked(term int64) {
+	wasLeader := r.role == leader
+	r.role = follower
+	r.ready = false
+	if term > r.term {
+		r.term = term
+		r.votedFor = ""
+		r.persistStateLocked()
+	}
+	if wasLeader {
+		log.Printf("[Raft] %s is no longer the leader (term %d)", r.self, r.term)
+		go r.onFollower()
+	}
+}
+
+func (r *raft) electionTimer() {
+	for {
+		time.Sleep(10 * time.Millisecond)
+		r.mu.Lock()
+		start := r.role != leader && time.Since(r.heardFrom) > r.timeout
+		r.mu.Unlock()
+		if start {
+			r.startElection()
+		}
+	}
+}
+
+func (r *raft) startElection() {
+	r.mu.Lock()
+	r.role = candidate
+	r.term++
+	r.votedFor = r.self
+	r.persistStateLocked()
+	r.heardFrom = time.Now()
+	lastIndex, lastTerm := r.lastLocked()
+	req := &pb.VoteRequest{Term: r.term, Candidate: r.self, LastLogIndex: lastIndex, LastLogTerm: lastTerm}
+	votes := 1
+	if votes >= r.majority() {
+		r.becomeLeaderLocked()
+	}
+	r.mu.Unlock()
+	log.Printf("[Raft] %s starts an election for term %d", r.self, req.Term)
+
+	for addr, peer := range r.peers {
+		go func() {
+			ctx, cancel := context.WithTimeout(r.peerContext(), minElectionTimeout)
+			defer cancel()
+			resp, err := peer.RequestVote(ctx, req)
+			if err != nil {
+				return
+			}
+
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			if resp.Term > r.term {
+				r.becomeFollowerLocked(resp.Term)
+				return
+			}
+			if r.role != candidate || r.term != req.Term || !resp.Granted {
+				return
+			}
+			votes++
+			log.Printf("[Raft] %s got the vote of %s for term %d", r.self, addr, req.Term)
+			if votes >= r.majority() {
+				r.becomeLeaderLocked()
+			}
+		}()
+	}
+}
+
+// becomeLeaderLocked starts the term with an empty entry, which commits the older entries with it (r.mu must be held)
+func (r *raft) becomeLeaderLocked() {
+	r.role = leader
+	r.leaderID = r.self
+	r.ready = false
+	next, _ := r.lastLocked()
+	for addr := range r.peers {
+		r.nextIndex[addr] = next + 1
+		r.matchIndex[addr] = 0
+	}
+	entry := &pb.RaftEntry{Term: r.term}
+	r.log = append(r.log, entry)
+	r.persistEntriesLocked([]*pb.RaftEntry{entry})
+	log.Printf("[Raft] %s is the leader of term %d", r.self, r.term)
+
+	// the entries of the old leaders are applied before the server takes calls: its clocks,
+	// rooms and sessions are where the old leader left them
+	index, term := r.lastLocked()
+	go func() {
+		for r.waitApplied(index, term) != nil {
+			r.mu.Lock()
+			still := r.role == leader && r.term == term
+			r.mu.Unlock()
+			if !still {
+				return
+			}
+		}
+		r.onLeader()
+		r.mu.Lock()
+		if r.role == leader && r.term == term {
+			r.ready = true
+		}
+		r.mu.Unlock()
+	}()
+
+	notifyAll(r.replicate)
+	r.advanceCommitLocked()
+}
+
+// replicator sends the new entries to a peer, or an empty AppendEntries as heartbeat
+func (r *raft) replicator(addr string) {
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-r.replicate[addr]:
+		}
+		r.sendAppend(addr)
+	}
+}
+
+func (r *raft) sendAppend(addr string) {
+	r.mu.Lock()
+	if r.role != leader {
+		r.mu.Unlock()
+		return
+	}
+	prev := r.nextIndex[addr] - 1
+	end := min(int64(len(r.log)), prev+1+maxAppendEntries)
+	req := &pb.AppendRequest{
+		Term:         r.term,
+		Leader:       r.self,
+		PrevLogIndex: prev,
+		PrevLogTerm:  r.log[prev].Term,
+		Entries:      r.log[prev+1 : end],
+		LeaderCommit: r.commitIndex,
+	}
+	more := end < int64(len(r.log))
+	r.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(r.peerContext(), heartbeatInterval*3)
+	resp, err := r.peers[addr].AppendEntries(ctx, req)
+	cancel()
+	if err != nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if resp.Term > r.term {
+		r.becomeFollowerLocked(resp.Term)
+		return
+	}
+	if r.role != leader || r.term != req.Term {
+		return
+	}
+	if resp.Success {
+		r.matchIndex[addr] = max(r.matchIndex[addr], prev+int64(len(req.Entries)))
+		r.nextIndex[addr] = r.matchIndex[addr] + 1
+		r.advanceCommitLocked()
+	} else {
+		r.nextIndex[addr] = max(1, min(resp.ConflictIndex, r.nextIndex[addr]-1))
+		more = true
+	}
+	if more {
+		notify(r.replicate[addr])
+	}
+}
+
+// advanceCommitLocked commits the entries of our term a majority has (r.mu must be held)
+func (r *raft) advanceCommitLocked() {
+	last, _ := r.lastLocked()
+	for n := last; n > r.commitIndex; n-- {
+		if r.log[n].Term != r.term {
+			// entries of older terms are only committed together with one of ours
+			break
+		}
+		count := 1
+		for _, match := range r.matchIndex {
+			if match >= n {
+				count++
+			}
+		}
+		if count >= r.majority() {
+			r.commitIndex = n
+			notify(r.commit)
+			return
+		}
+	}
+}
+
+// applier hands the committed entries to the server, in order
+func (r *raft) applier() {
+	for range r.commit {
+		for {
+			r.mu.Lock()
+			if r.lastApplied >= r.commitIndex {
+				r.mu.Unlock()
+				break
+			}
+			r.lastApplied++
+			entry := r.log[r.lastApplied]
+			r.mu.Unlock()
+
+			if entry.Message != nil {
+				r.apply(entry.Message)
+			} else {
+				r.applyChange(entry)
+			}
+
+			r.mu.Lock()
+			close(r.applied)
+			r.applied = make(chan struct{})
+			r.mu.Unlock()
+		}
+	}
+}
+
+// propose adds an entry to the log in the current term. It returns the index and term to wait for.
+func (r *raft) propose(entry *pb.RaftEntry) (int64, int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.role != leader || !r.ready {
+		return 0, 0, errNotLeader
+	}
+	entry.Term = r.term
+	r.log = append(r.log, entry)
+	r.persistEntriesLocked([]*pb.RaftEntry{entry})
+	notifyAll(r.replicate)
+	r.advanceCommitLocked()
+	index, _ := r.lastLocked()
+	return index, r.term, nil
+}
+
+// waitApplied waits until the entry at index is applied. It fails if another entry
+// took its place or the replica did not manage to commit it in time.
+func (r *raft) waitApplied(index, term int64) error {
+	deadline := time.After(commitTimeout)
+	for {
+		r.mu.Lock()
+		if r.lastApplied >= index {
+			ok := r.log[index].Term == term
+			r.mu.Unlock()
+			if !ok {
+				return status.Errorf(codes.Unavailable, "the leader changed, the message was not sent")
+			}
+			return nil
+		}
+		applied := r.applied
+		r.mu.Unlock()
+
+		select {
+		case <-applied:
+		case <-deadline:
+			return status.Errorf(codes.Unavailable, "the message was not committed in time")
+		}
+	}
+}
+
+func (r *raft) isLeader() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.role == leader && r.ready
+}
+
+func (r *raft) peerContext() context.Context {
+	if r.secret == "" {
+		return context.Background()
+	}
+	return metadata.AppendToOutgoingContext(context.Background(), peerSecretKey, r.secret)
+}
+
+func (r *raft) RequestVote(ctx context.Context, req *pb.VoteRequest) (*pb.VoteResponse, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if req.Term > r.term {
+		r.becomeFollowerLocked(req.Term)
+	}
+	lastIndex, lastTerm := r.lastLocked()
+	upToDate := req.LastLogTerm > lastTerm || (req.LastLogTerm == lastTerm && req.LastLogIndex >= lastIndex)
+	granted := req.Term == r.term && (r.votedFor == "" || r.votedFor == req.Candidate) && upToDate
+	if granted {
+		r.votedFor = req.Candidate
+		if err := r.persistStateLocked(); err != nil {
+			// a vote we could forget in a crash is not given
+			return nil, status.Errorf(codes.Internal, "could not store the vote: %v", err)
+		}
+		r.heardFrom = time.Now()
+	}
+	return &pb.VoteResponse{Term: r.term, Granted: granted}, nil
+}
+
+func (r *raft) AppendEntries(ctx context.Context, req *pb.AppendRequest) (*pb.AppendResponse, error) {
+	if req.PrevLogIndex < 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "prev_log_index %d is negative", req.PrevLogIndex)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if req.Term < r.term {
+		return &pb.AppendResponse{Term: r.term, Success: false}, nil
+	}
+	if req.Term > r.term || r.role != follower {
+		r.becomeFollowerLocked(req.Term)
+	}
+	if r.leaderID != req.Leader {
+		log.Printf("[Raft] %s follows %s in term %d", r.self, req.Leader, req.Term)
+	}
+	r.leaderID = req.Leader
+	r.heardFrom = time.Now()
+
+	lastIndex, _ := r.lastLocked()
+	if req.PrevLogIndex > lastIndex {
+		return &pb.AppendResponse{Term: r.term, Success: false, ConflictIndex: lastIndex + 1}, nil
+	}
+	if r.log[req.PrevLogIndex].Term != req.PrevLogTerm {
+		// skip back over the whole conflicting term
+		conflictTerm := r.log[req.PrevLogIndex].Term
+		first := req.PrevLogIndex
+		for first > 1 && r.log[first-1].Term == conflictTerm {
+			first--
+		}
+		return &pb.AppendResponse{Term: r.term, Success: false, ConflictIndex: first}, nil
+	}
+
+	for i, entry := range req.Entries {
+		index := req.PrevLogIndex + 1 + int64(i)
+		if index < int64(len(r.log)) {
+			if r.log[index].Term == entry.Term {
+				continue
+			}
+			if err := r.truncateLocked(index); err != nil {
+				return nil, status.Errorf(codes.Internal, "could not truncate the log: %v", err)
+			}
+		}
+		r.log = append(r.log, req.Entries[i:]...)
+		if err := r.persistEntriesLocked(req.Entries[i:]); err != nil {
+			// the leader must not count entries we could lose in a crash, it sends them again
+			r.log = r.log[:index]
+			return nil, status.Errorf(codes.Internal, "could not store the entries: %v", err)
+		}
+		break
+	}
+
+	// only what this request confirmed is committed, and the commit index never goes back
+	if commit := min(req.LeaderCommit, req.PrevLogIndex+int64(len(req.Entries))); commit > r.commitIndex {
+		r.commitIndex = commit
+		notify(r.commit)
+	}
+	return &pb.AppendResponse{Term: r.term, Success: true}, nil
+}
+
+// applyCommitted broadcasts a committed message on this replica. The leader ticked its clocks
+// for it already, the others catch up with it here.
+func (s *server) applyCommitted(msg *pb.BroadcastMessage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.catchUpLocked(msg)
+	s.deliverLocked(msg)
+}
+
+// replicate makes a change every replica has to know about: with replicas it goes through the
+// Raft log and this waits until it is applied here, otherwise it is applied right away
+// (s.mu must not be held).
+func (s *server) replicate(entry *pb.RaftEntry) error {
+	if s.raft == nil {
+		return s.applyEntry(entry)
+	}
+	index, term, err := s.raft.propose(entry)
+	if err != nil {
+		return err
+	}
+	return s.raft.waitApplied(index, term)
+}
+
+// applyEntry makes the change of a log entry that is not a message on this server
+func (s *server) applyEntry(entry *pb.RaftEntry) error {
+	switch {
+	case entry.Account != nil:
+		return s.applyAccount(entry.Account)
+	case entry.Room != nil:
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return s.applyRoomLocked(entry.Room)
+	case entry.Session != nil:
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.applySessionLocked(entry.Session)
+	}
+	return nil
+}
+
+// applyCommittedChange makes a committed change. The leader checked it before it proposed it,
+// so it only fails when two changes raced, and then the first one wins.
+func (s *server) applyCommittedChange(entry *pb.RaftEntry) {
+	if err := s.applyEntry(entry); err != nil && status.Code(err) != codes.AlreadyExists {
+		log.Printf("[Server] Could not apply %v: %v", entry, err)
+	}
+}
+
+// catchUpLocked moves the clocks and the sequence number past a message of the log (s.mu must be held)
+func (s *server) catchUpLocked(msg *pb.BroadcastMessage) {
+	r, ok := s.rooms[msg.Room]
+	if !ok {
+		// rooms are created on the leader, the others learn about them with their first message
+		r = newRoom(msg.Room)
+		s.rooms[msg.Room] = r
+	}
+	r.lamportClock = max(r.lamportClock, msg.LamportTimestamp)
+	mergeVector(r.vector, msg.VectorClock)
+	s.seq = max(s.seq, msg.Seq)
+}
+
+// becameLeader runs when a new leader applied the whole log, before it takes calls
+func (s *server) becameLeader() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// the participants of the old leader can resume their sessions here, like after a broken stream
+	waiting := 0
+	for name := range s.rooms[generalRoom].members {
+		_, online := s.clients[name]
+		if _, detached := s.detached[name]; !online && !detached {
+			s.detachLocked(name)
+			waiting++
+		}
+	}
+	log.Printf("[Server] Leader now, %d participants of the old leader can resume", waiting)
+}
+
+// steppedDown ends the streams of the clients, they look for the new leader
+func (s *server) steppedDown() {
+	log.Printf("[Server] Not the leader any more, sending the clients away")
+	s.closeStreams()
+	// the next leader lets them resume, and makes them leave if they don't
+	s.mu.Lock()
+	for name, timer := range s.detached {
+		timer.Stop()
+		delete(s.detached, name)
+	}
+	s.mu.Unlock()
+}
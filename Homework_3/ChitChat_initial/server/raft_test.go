@@ -0,0 +1,181 @@
+package main
+
+import (
+	pb "ITUserver/grpc"
+	"context"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// newTestRaft is a replica in dir with a log of entries in the given terms. Its peers are
+// never called, they only count for the majority.
+func newTestRaft(t *testing.T, dir string, peers []string, terms ...int64) *raft {
+	t.Helper()
+	r, err := newRaft("self", peers, "", dir, insecure.NewCredentials())
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := entriesOf(terms...)
+	r.log = append(r.log, entries...)
+	if err := r.persistEntriesLocked(entries); err != nil {
+		t.Fatal(err)
+	}
+	if len(terms) > 0 {
+		r.term = terms[len(terms)-1]
+	}
+	return r
+}
+
+func entriesOf(terms ...int64) []*pb.RaftEntry {
+	var entries []*pb.RaftEntry
+	for _, term := range terms {
+		entries = append(entries, &pb.RaftEntry{Term: term})
+	}
+	return entries
+}
+
+// logTerms is the term of every entry in the log, without the placeholder
+func logTerms(r *raft) []int64 {
+	var terms []int64
+	for _, entry := range r.log[1:] {
+		terms = append(terms, entry.Term)
+	}
+	return terms
+}
+
+func TestAppendEntries(t *testing.T) {
+	tests := []struct {
+		name       string
+		log        []int64 // terms of the follower's log
+		commit     int64   // its commit index
+		req        *pb.AppendRequest
+		success    bool
+		conflict   int64
+		want       []int64 // terms of the log afterwards
+		wantCommit int64
+	}{
+		{
+			name: "stale leader",
+			log:  []int64{1, 2}, commit: 1,
+			req:     &pb.AppendRequest{Term: 1, PrevLogIndex: 2, PrevLogTerm: 2, Entries: entriesOf(1), LeaderCommit: 3},
+			success: false,
+			want:    []int64{1, 2}, wantCommit: 1,
+		},
+		{
+			name:    "append to an empty log",
+			req:     &pb.AppendRequest{Term: 1, Entries: entriesOf(1, 1), LeaderCommit: 1},
+			success: true,
+			want:    []int64{1, 1}, wantCommit: 1,
+		},
+		{
+			name: "prev entry missing",
+			log:  []int64{1}, commit: 0,
+			req:     &pb.AppendRequest{Term: 2, PrevLogIndex: 3, PrevLogTerm: 2, Entries: entriesOf(2)},
+			success: false, conflict: 2,
+			want: []int64{1},
+		},
+		{
+			name: "prev entry of another term skips back over the term",
+			log:  []int64{1, 2, 2, 2}, commit: 1,
+			req:     &pb.AppendRequest{Term: 3, PrevLogIndex: 4, PrevLogTerm: 3, Entries: entriesOf(3)},
+			success: false, conflict: 2,
+			want: []int64{1, 2, 2, 2}, wantCommit: 1,
+		},
+		{
+			name: "conflicting entries are replaced",
+			log:  []int64{1, 2, 2}, commit: 1,
+			req:     &pb.AppendRequest{Term: 3, PrevLogIndex: 1, PrevLogTerm: 1, Entries: entriesOf(3, 3, 3), LeaderCommit: 2},
+			success: true,
+			want:    []int64{1, 3, 3, 3}, wantCommit: 2,
+		},
+		{
+			name: "an old request does not cut the log",
+			log:  []int64{1, 1, 1}, commit: 0,
+			req:     &pb.AppendRequest{Term: 1, PrevLogIndex: 0, PrevLogTerm: 0, Entries: entriesOf(1)},
+			success: true,
+			want:    []int64{1, 1, 1},
+		},
+		{
+			name: "commit only what the request confirmed",
+			log:  []int64{1, 1, 1}, commit: 0,
+			req:     &pb.AppendRequest{Term: 1, PrevLogIndex: 1, PrevLogTerm: 1, LeaderCommit: 3},
+			success: true,
+			want:    []int64{1, 1, 1}, wantCommit: 1,
+		},
+		{
+			name: "commit index never goes back",
+			log:  []int64{1, 1, 1}, commit: 3,
+			req:     &pb.AppendRequest{Term: 1, PrevLogIndex: 1, PrevLogTerm: 1, LeaderCommit: 1},
+			success: true,
+			want:    []int64{1, 1, 1}, wantCommit: 3,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			r := newTestRaft(t, dir, nil, test.log...)
+			r.commitIndex = test.commit
+			test.req.Leader = "leader"
+
+			resp, err := r.AppendEntries(context.Background(), test.req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.Success != test.success || resp.ConflictIndex != test.conflict {
+				t.Errorf("success %v, conflict index %d, want %v, %d", resp.Success, resp.ConflictIndex, test.success, test.conflict)
+			}
+			if got := logTerms(r); !slices.Equal(got, test.want) {
+				t.Errorf("log %v, want %v", got, test.want)
+			}
+			if r.commitIndex != test.wantCommit {
+				t.Errorf("commit index %d, want %d", r.commitIndex, test.wantCommit)
+			}
+
+			// what the replica answered with is what it finds on the disk after a restart
+			r.logFile.Close()
+			reloaded := &raft{log: []*pb.RaftEntry{{}}}
+			if err := reloaded.loadLog(filepath.Join(dir, "raft_log.jsonl")); err != nil {
+				t.Fatal(err)
+			}
+			reloaded.logFile.Close()
+			if got := logTerms(reloaded); !slices.Equal(got, test.want) {
+				t.Errorf("log on the disk %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestAdvanceCommit(t *testing.T) {
+	tests := []struct {
+		name   string
+		term   int64
+		log    []int64
+		match  []int64 // match index of the two peers
+		commit int64
+		want   int64
+	}{
+		{"nobody else has it", 1, []int64{1, 1}, []int64{0, 0}, 0, 0},
+		{"a majority has it", 1, []int64{1, 1}, []int64{2, 0}, 0, 2},
+		{"only as far as the majority", 1, []int64{1, 1, 1}, []int64{1, 2}, 0, 2},
+		{"an older term is not counted alone", 3, []int64{1, 2, 3}, []int64{2, 2}, 0, 0},
+		{"an older term is committed with ours", 3, []int64{1, 2, 3}, []int64{3, 0}, 0, 3},
+		{"never goes back", 2, []int64{1, 2}, []int64{0, 0}, 1, 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := newTestRaft(t, t.TempDir(), []string{"a", "b"}, test.log...)
+			r.role = leader
+			r.term = test.term
+			r.commitIndex = test.commit
+			r.matchIndex["a"], r.matchIndex["b"] = test.match[0], test.match[1]
+
+			r.advanceCommitLocked()
+			if r.commitIndex != test.want {
+				t.Errorf("commit index %d, want %d", r.commitIndex, test.want)
+			}
+		})
+	}
+}
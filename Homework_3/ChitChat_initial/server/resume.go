@@ -0,0 +1,91 @@
+package main
+
+import (
+	pb "ITUserver/grpc"
+	"fmt"
+	"log"
+	"time"
+)
+
+// A client whose stream breaks stays in its rooms for this long. If it comes back
+// in time it continues where it was, otherwise it has left the chat.
+const resumeGrace = 30 * time.Second
+
+// The header of the JoinChat stream tells the client if its session was resumed
+const resumedKey = "resumed"
+
+// detach is called when the stream of a client (with the outbox box) is gone
+func (s *server) detach(clientName string, box *outbox) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if current, exists := s.clients[clientName]; !exists || current != box {
+		// the client already left, or resumed with a newer stream
+		return
+	}
+	delete(s.clients, clientName)
+	box.close(nil)
+	s.detachLocked(clientName)
+}
+
+// detachLocked gives a participant without a stream resumeGrace to come back (s.mu must be held)
+func (s *server) detachLocked(clientName string) {
+	s.setStatusLocked(clientName, pb.PresenceStatus_IDLE)
+	log.Printf("[Server] Keeping the session of %s for %v", clientName, resumeGrace)
+	s.detached[clientName] = time.AfterFunc(resumeGrace, func() { s.expire(clientName) })
+}
+
+// expire makes a client that did not come back leave the chat
+func (s *server) expire(clientName string) {
+	s.mu.Lock()
+	if _, detached := s.detached[clientName]; !detached {
+		// it resumed just now
+		s.mu.Unlock()
+		return
+	}
+	delete(s.detached, clientName)
+	delete(s.acked, clientName)
+	s.setStatusLocked(clientName, pb.PresenceStatus_OFFLINE)
+	s.mu.Unlock()
+
+	if err := s.changeRooms(pb.RoomChangeType_LEFT_CHAT, "", clientName); err != nil {
+		log.Printf("[Server] Could not take %s out of its rooms: %v", clientName, err)
+	}
+
+	lamportTime, _ := s.announce(generalRoom, pb.MessageType_LEAVE, nil, func(lamport int64) string {
+		return fmt.Sprintf("Participant %s left Chit Chat at Lamport time %d", clientName, lamport)
+	})
+	log.Printf("[Server] Client %s did not come back, it left (Lamport: %d)", clientName, lamportTime)
+}
+
+// leaveRoomsLocked takes a client out of every room (s.mu must be held)
+func (s *server) leaveRoomsLocked(clientName string) {
+	for _, r := range s.rooms {
+		delete(r.members, clientName)
+	}
+}
+
+// closeStreams ends the streams of every client, they reconnect to the next server that starts
+func (s *server) closeStreams() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for name, box := range s.clients {
+		box.close(nil)
+		delete(s.clients, name)
+	}
+	for events := range s.presence.watchers {
+		close(events)
+		delete(s.presence.watchers, events)
+	}
+}
+
+// memberOfLocked is the set of rooms a client is in (s.mu must be held)
+func (s *server) memberOfLocked(clientName string) map[string]bool {
+	rooms := make(map[string]bool)
+	for name, r := range s.rooms {
+		if r.members[clientName] {
+			rooms[name] = true
+		}
+	}
+	return rooms
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	pb "ITUserver/grpc"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"maps"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Everybody who joins the chat is in this room
+const generalRoom = "#general"
+
+// room has its own members and its own Lamport clock, so messages are
+// ordered per room and a busy room does not move the clocks of the others
+type room struct {
+	name         string
+	lamportClock int64
+	members      map[string]bool
+	// merge of the vector timestamps of every chat message in the room
+	vector map[string]int64
+}
+
+func newRoom(name string) *room {
+	return &room{name: name, members: make(map[string]bool), vector: make(map[string]int64)}
+}
+
+// mergeVector sets every entry of into to the max of both
+func mergeVector(into map[string]int64, other map[string]int64) {
+	for name, n := range other {
+		into[name] = max(into[name], n)
+	}
+}
+
+// loadRooms creates the rooms listed in the file at path, and keeps the list there from now on.
+// The history only knows the rooms that have messages, this file has the empty ones too.
+func (s *server) loadRooms(path string) error {
+	s.roomsPath = path
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var names []string
+	if err := json.Unmarshal(data, &names); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, name := range names {
+		if _, exists := s.rooms[name]; !exists {
+			s.rooms[name] = newRoom(name)
+		}
+	}
+	return nil
+}
+
+// saveRoomsLocked writes the names of all rooms to the file (s.mu must be held)
+func (s *server) saveRoomsLocked() error {
+	if s.roomsPath == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(slices.Sorted(maps.Keys(s.rooms)), "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.roomsPath, data, 0644)
+}
+
+// changeRooms creates a room, or puts a participant in or out of rooms. With replicas it goes
+// through the Raft log, so the next leader knows the rooms and who is in them (s.mu must not be held).
+func (s *server) changeRooms(changeType pb.RoomChangeType, room, participant string) error {
+	return s.replicate(&pb.RaftEntry{Room: &pb.RoomChange{Type: changeType, Room: room, ParticipantName: participant}})
+}
+
+// applyRoomLocked makes a room change on this server (s.mu must be held)
+func (s *server) applyRoomLocked(change *pb.RoomChange) error {
+	name := change.ParticipantName
+	switch change.Type {
+	case pb.RoomChangeType_CREATED:
+		if _, exists := s.rooms[change.Room]; exists {
+			return status.Errorf(codes.AlreadyExists, "room %s already exists", change.Room)
+		}
+		s.rooms[change.Room] = newRoom(change.Room)
+		if err := s.saveRoomsLocked(); err != nil {
+			log.Printf("[Server] Could not save the rooms: %v", err)
+		}
+	case pb.RoomChangeType_JOINED:
+		r, ok := s.rooms[change.Room]
+		if !ok {
+			return status.Errorf(codes.NotFound, "room %s does not exist", change.Room)
+		}
+		r.members[name] = true
+	case pb.RoomChangeType_LEFT:
+		if r, ok := s.rooms[change.Room]; ok {
+			delete(r.members, name)
+		}
+	case pb.RoomChangeType_JOINED_CHAT:
+		s.leaveRoomsLocked(name)
+		s.rooms[generalRoom].members[name] = true
+	case pb.RoomChangeType_LEFT_CHAT:
+		s.leaveRoomsLocked(name)
+	}
+	return nil
+}
+
+// roomName turns "games" or "#games" into "#games", empty means #general
+func roomName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return generalRoom, nil
+	}
+	if !strings.HasPrefix(name, "#") {
+		name = "#" + name
+	}
+	if len(name) < 2 || len(name) > 32 || strings.ContainsAny(name[1:], " #\t\n") {
+		return "", fmt.Errorf("invalid room name %q", name)
+	}
+	return name, nil
+}
+
+// announce ticks the room's Lamport clock (merged with the time of the chat message from,
+// nil for messages of the server) and broadcasts the message made with the new time.
+// The lock is held for both, so the members get the messages of a room in Lamport order.
+func (s *server) announce(roomName string, msgType pb.MessageType, from *pb.ChatMessage, content func(lamport int64) string) (int64, error) {
+	s.mu.Lock()
+	r, ok := s.rooms[roomName]
+	if !ok {
+		s.mu.Unlock()
+		return 0, fmt.Errorf("room %s does not exist", roomName)
+	}
+	if from != nil && from.Lamport > r.lamportClock {
+		r.lamportClock = from.Lamport
+	}
+	r.lamportClock++
+
+	msg := &pb.BroadcastMessage{
+		Content:          content(r.lamportClock),
+		LamportTimestamp: r.lamportClock,
+		Type:             msgType,
+		Room:             roomName,
+	}
+	if from != nil {
+		// the vector is the sender's, the server only passes it on
+		msg.Sender = from.ParticipantName
+		msg.VectorClock = from.VectorClock
+		mergeVector(r.vector, from.VectorClock)
+	} else {
+		// tells a client that joins which chat messages it will never get
+		msg.VectorClock = maps.Clone(r.vector)
+	}
+	wait := s.broadcastLocked(msg)
+	s.mu.Unlock()
+
+	if err := wait(); err != nil {
+		return 0, err
+	}
+	return msg.LamportTimestamp, nil
+}
+
+func (s *server) CreateRoom(ctx context.Context, req *pb.RoomRequest) (*pb.RoomResponse, error) {
+	if err := checkSender(ctx, req.ParticipantName); err != nil {
+		return &pb.RoomResponse{Success: false}, err
+	}
+	name, err := roomName(req.Room)
+	if err != nil {
+		return &pb.RoomResponse{Success: false}, err
+	}
+
+	s.mu.Lock()
+	_, exists := s.rooms[name]
+	s.mu.Unlock()
+	if exists {
+		return &pb.RoomResponse{Success: false, Room: name}, fmt.Errorf("room %s already exists", name)
+	}
+	if err := s.changeRooms(pb.RoomChangeType_CREATED, name, req.ParticipantName); err != nil {
+		return &pb.RoomResponse{Success: false, Room: name}, err
+	}
+
+	log.Printf("[Server] %s created room %s", req.ParticipantName, name)
+	return &pb.RoomResponse{Success: true, Room: name}, nil
+}
+
+func (s *server) ListRooms(ctx context.Context, req *pb.ListRoomsRequest) (*pb.ListRoomsResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	resp := &pb.ListRoomsResponse{}
+	for _, r := range s.rooms {
+		resp.Rooms = append(resp.Rooms, &pb.RoomInfo{
+			Name:             r.name,
+			Members:          int32(len(r.members)),
+			LamportTimestamp: r.lamportClock,
+		})
+	}
+	sort.Slice(resp.Rooms, func(i, j int) bool { return resp.Rooms[i].Name < resp.Rooms[j].Name })
+	return resp, nil
+}
+
+func (s *server) JoinRoom(ctx context.Context, req *pb.RoomRequest) (*pb.RoomResponse, error) {
+	if err := checkSender(ctx, req.ParticipantName); err != nil {
+		return &pb.RoomResponse{Success: false}, err
+	}
+	name, err := roomName(req.Room)
+	if err != nil {
+		return &pb.RoomResponse{Success: false}, err
+	}
+
+	s.mu.Lock()
+	r, ok := s.rooms[name]
+	if !ok {
+		s.mu.Unlock()
+		return &pb.RoomResponse{Success: false, Room: name}, fmt.Errorf("room %s does not exist", name)
+	}
+	if _, online := s.clients[req.ParticipantName]; !online {
+		s.mu.Unlock()
+		return &pb.RoomResponse{Success: false, Room: name}, fmt.Errorf("%s has not joined the chat", req.ParticipantName)
+	}
+	if r.members[req.ParticipantName] {
+		// joining again changes nothing, and the others were told the first time
+		s.mu.Unlock()
+		return &pb.RoomResponse{Success: true, Room: name, LamportTimestamp: r.lamportClock}, nil
+	}
+	s.mu.Unlock()
+	if err := s.changeRooms(pb.RoomChangeType_JOINED, name, req.ParticipantName); err != nil {
+		return &pb.RoomResponse{Success: false, Room: name}, err
+	}
+
+	lamportTime, err := s.announce(name, pb.MessageType_JOIN, nil, func(lamport int64) string {
+		return fmt.Sprintf("Participant %s joined %s at Lamport time %d", req.ParticipantName, name, lamport)
+	})
+	if err != nil {
+		return &pb.RoomResponse{Success: false, Room: name}, err
+	}
+	log.Printf("[Server] Client %s joined room %s (Lamport: %d)", req.ParticipantName, name, lamportTime)
+	return &pb.RoomResponse{Success: true, Room: name, LamportTimestamp: lamportTime}, nil
+}
+
+func (s *server) LeaveRoom(ctx context.Context, req *pb.RoomRequest) (*pb.RoomResponse, error) {
+	if err := checkSender(ctx, req.ParticipantName); err != nil {
+		return &pb.RoomResponse{Success: false}, err
+	}
+	name, err := roomName(req.Room)
+	if err != nil {
+		return &pb.RoomResponse{Success: false}, err
+	}
+	if name == generalRoom {
+		return &pb.RoomResponse{Success: false, Room: name}, fmt.Errorf("can't leave %s, leave the chat instead", generalRoom)
+	}
+
+	s.mu.Lock()
+	r, ok := s.rooms[name]
+	if !ok || !r.members[req.ParticipantName] {
+		s.mu.Unlock()
+		return &pb.RoomResponse{Success: false, Room: name}, fmt.Errorf("%s is not in room %s", req.ParticipantName, name)
+	}
+	s.mu.Unlock()
+
+	// the announcement still goes to the one who leaves
+	lamportTime, err := s.announce(name, pb.MessageType_LEAVE, nil, func(lamport int64) string {
+		return fmt.Sprintf("Participant %s left %s at Lamport time %d", req.ParticipantName, name, lamport)
+	})
+	if err != nil {
+		return &pb.RoomResponse{Success: false, Room: name}, err
+	}
+
+	if err := s.changeRooms(pb.RoomChangeType_LEFT, name, req.ParticipantName); err != nil {
+		return &pb.RoomResponse{Success: false, Room: name}, err
+	}
+
+	log.Printf("[Server] Client %s left room %s (Lamport: %d)", req.ParticipantName, name, lamportTime)
+	return &pb.RoomResponse{Success: true, Room: name, LamportTimestamp: lamportTime}, nil
+}
@@ -3,108 +3,337 @@ package main
 import (
 	pb "ITUserver/grpc"
 	"context"
+	"crypto/tls"
+	"flag"
 	"fmt"
 	"log"
 	"net"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"sync"
 	"syscall"
+	"time"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
 )
 
 type server struct {
 	pb.ITUDatabaseServer
-	mu           sync.Mutex
-	lamportClock int64
-	clients      map[string]chan *pb.BroadcastMessage
+	mu      sync.Mutex
+	clients map[string]*outbox
+	rooms   map[string]*room
+	history *history
+	// file with the names of the rooms ("" keeps them in memory only)
+	roomsPath string
+
+	dispatcher    *dispatcher
+	federation    *federation
+	defaultPolicy pb.SlowConsumerPolicy // for clients that don't choose one
+	blockTimeout  time.Duration
+
+	accounts *accounts
+	sessions map[string]string // SHA-256 of the session token -> participant
+
+	seq      uint64                 // sequence number of the last broadcast message
+	detached map[string]*time.Timer // lost their stream, but may still resume
+	acked    map[string]uint64      // the last message a session client said it got
+
+	// private messages are not in any room, they share this clock
+	privateClock int64
+
+	presence   *presence
+	moderation *moderation
+
+	raft *raft // nil unless the server is one of several replicas
 }
 
-func newServer() *server {
-	return &server{
-		clients: make(map[string]chan *pb.BroadcastMessage),
+// newServer creates the rooms that are in the history, with their clocks where they stopped
+func newServer(h *history, a *accounts, f *federation) *server {
+	s := &server{
+		clients:       make(map[string]*outbox),
+		rooms:         map[string]*room{generalRoom: newRoom(generalRoom)},
+		history:       h,
+		dispatcher:    newDispatcher(),
+		federation:    f,
+		defaultPolicy: pb.SlowConsumerPolicy_DROP_OLDEST,
+		blockTimeout:  2 * time.Second,
+		accounts:      a,
+		sessions:      make(map[string]string),
+		seq:           h.lastSeq(),
+		detached:      make(map[string]*time.Timer),
+		acked:         make(map[string]uint64),
+		presence:      newPresence(),
+		moderation:    newModeration(nil, 0, 1),
+	}
+	for _, name := range h.rooms() {
+		if _, ok := s.rooms[name]; !ok {
+			s.rooms[name] = newRoom(name)
+		}
+		s.rooms[name].lamportClock = h.lastLamport(name)
+		s.rooms[name].vector = h.vector(name)
 	}
+	for _, msg := range h.messages {
+		// so peers that send them again are ignored
+		f.markSeen(msg)
+	}
+	return s
 }
 
-func (s *server) incrementClock() int64 {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	s.lamportClock++
-	return s.lamportClock
-}
+// broadcastLocked numbers the message and broadcasts it. In replicated mode it only goes
+// into the Raft log here, and wait blocks until it is committed and broadcast
+// (s.mu must be held, but not when calling wait).
+func (s *server) broadcastLocked(msg *pb.BroadcastMessage) (wait func() error) {
+	s.seq++
+	msg.Seq = s.seq
+	if s.raft != nil {
+		index, term, err := s.raft.propose(&pb.RaftEntry{Message: msg})
+		if err != nil {
+			return func() error { return err }
+		}
+		return func() error { return s.raft.waitApplied(index, term) }
+	}
 
-func (s *server) broadcast(msg *pb.BroadcastMessage) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+	if msg.Origin == "" {
+		// broadcast here first, the peers get it too
+		msg.Origin = s.federation.self
+		msg.OriginSeq = msg.Seq
+		s.federation.markSeen(msg)
+		s.federation.send(&pb.RelayMessage{Message: msg, Path: []string{s.federation.self}})
+	}
+	s.deliverLocked(msg)
+	return func() error { return nil }
+}
 
-	log.Printf("[Server] Broadcasting: %s (Lamport: %d)", msg.Content, msg.LamportTimestamp)
+// deliverLocked stores the message and hands it to the dispatcher for the members
+// of its room (s.mu must be held)
+func (s *server) deliverLocked(msg *pb.BroadcastMessage) {
+	log.Printf("[Server] Broadcasting in %s: %s (Lamport: %d, seq: %d)", msg.Room, msg.Content, msg.LamportTimestamp, msg.Seq)
+	if err := s.history.append(msg); err != nil {
+		log.Printf("[Server] Could not write history: %v", err)
+	}
 
-	for _, ch := range s.clients {
-		select {
-		case ch <- msg:
-		default:
+	r, ok := s.rooms[msg.Room]
+	if !ok {
+		return
+	}
+	var to []*outbox
+	for name := range r.members {
+		// members that lost their stream get the message when they resume
+		if box, online := s.clients[name]; online {
+			to = append(to, box)
 		}
 	}
+	s.dispatcher.add(msg, to)
 }
 
-func (s *server) JoinChat(req *pb.JoinRequest, stream pb.ITUDatabase_JoinChatServer) error {
-	clientName := req.ParticipantName
-	log.Printf("[Server] Client %s joined", clientName)
+// joined is a client whose outbox is registered, and the messages it has to get first
+type joined struct {
+	box     *outbox
+	missed  []*pb.BroadcastMessage
+	replay  bool // missed is history after a Lamport time, not live messages
+	resumed bool
+}
 
-	msgChan := make(chan *pb.BroadcastMessage, 100)
+// join registers the outbox of a client that joins or resumes its session
+func (s *server) join(req *pb.JoinRequest) (*joined, error) {
+	clientName := req.ParticipantName
+	policy := req.SlowPolicy
+	if policy == pb.SlowConsumerPolicy_BLOCK {
+		// the dispatcher waits for a blocked client, so everybody would wait for it
+		return nil, status.Errorf(codes.InvalidArgument, "only the server can use the block policy")
+	}
+	if policy == pb.SlowConsumerPolicy_SERVER_DEFAULT {
+		policy = s.defaultPolicy
+	}
+	j := &joined{box: newOutbox(clientName, policy, s.blockTimeout)}
 
+	// registering and reading the history under the same lock means every message
+	// is either in the replay or comes through the outbox, never both or neither
 	s.mu.Lock()
-	s.clients[clientName] = msgChan
+	old, online := s.clients[clientName]
+	timer, detached := s.detached[clientName]
+	j.resumed = req.Resume && (online || detached)
+	if online && !j.resumed {
+		s.mu.Unlock()
+		log.Printf("[Server] Rejected a second join of %s", clientName)
+		return nil, status.Errorf(codes.AlreadyExists, "%s is already in the chat", clientName)
+	}
+	sinceSeq := req.SinceSeq
+	if acked, ok := s.acked[clientName]; ok && j.resumed && sinceSeq == nil {
+		// a session client told us what it got
+		sinceSeq = &acked
+	}
+	if j.resumed && sinceSeq == nil && req.SinceLamport != nil && len(s.memberOfLocked(clientName)) > 1 {
+		// every room has its own clock, a Lamport time of #general says nothing about the others
+		s.mu.Unlock()
+		return nil, status.Errorf(codes.InvalidArgument, "%s is in other rooms than %s, resume with since_seq instead of since_lamport", clientName, generalRoom)
+	}
+	if online {
+		// the old stream has not noticed yet that the client is gone
+		old.close(nil)
+	}
+	if detached {
+		timer.Stop()
+		delete(s.detached, clientName)
+	}
+	if !j.resumed {
+		delete(s.acked, clientName)
+		// a new client with the same name starts in #general only
+		s.applyRoomLocked(&pb.RoomChange{Type: pb.RoomChangeType_JOINED_CHAT, ParticipantName: clientName})
+	}
+	s.clients[clientName] = j.box
+	s.setStatusLocked(clientName, pb.PresenceStatus_ONLINE)
+	switch {
+	case sinceSeq != nil:
+		j.missed = s.history.afterSeq(*sinceSeq, s.memberOfLocked(clientName))
+	case req.SinceLamport != nil:
+		j.missed = s.history.since(generalRoom, req.GetSinceLamport())
+		j.replay = true
+	}
 	s.mu.Unlock()
 
-	lamportTime := s.incrementClock()
-	joinMsg := &pb.BroadcastMessage{
-		Content:          fmt.Sprintf("Participant %s joined Chit Chat at Lamport time %d", clientName, lamportTime),
-		LamportTimestamp: lamportTime,
-		Type:             pb.MessageType_JOIN,
+	if !j.resumed && s.raft != nil {
+		// the other replicas learn it joined, so it can resume on the next leader
+		if err := s.changeRooms(pb.RoomChangeType_JOINED_CHAT, "", clientName); err != nil {
+			s.removeClient(clientName)
+			return nil, err
+		}
+	}
+	if j.resumed {
+		log.Printf("[Server] Client %s resumed its session, sending %d missed messages", clientName, len(j.missed))
+	} else {
+		log.Printf("[Server] Client %s joined (slow consumer policy: %v)", clientName, policy)
+	}
+	return j, nil
+}
+
+// catchUp sends a client that joined what it missed, and tells the others it joined
+func (s *server) catchUp(req *pb.JoinRequest, j *joined, send func(*pb.BroadcastMessage) error) error {
+	clientName := req.ParticipantName
+	if j.replay {
+		log.Printf("[Server] Replaying %d messages after Lamport %d to %s", len(j.missed), req.GetSinceLamport(), clientName)
+	}
+	for _, msg := range j.missed {
+		if j.replay {
+			msg = proto.Clone(msg).(*pb.BroadcastMessage)
+			msg.Replayed = true
+		}
+		if err := send(msg); err != nil {
+			s.detach(clientName, j.box)
+			return err
+		}
+	}
+
+	if !j.resumed {
+		s.announce(generalRoom, pb.MessageType_JOIN, nil, func(lamport int64) string {
+			return fmt.Sprintf("Participant %s joined Chit Chat at Lamport time %d", clientName, lamport)
+		})
+	}
+	return nil
+}
+
+// forward sends the messages waiting in the outbox. done is true if the stream has to end, with err.
+func (s *server) forward(clientName string, box *outbox, send func(*pb.BroadcastMessage) error) (done bool, err error) {
+	msgs, missed, done, reason := box.take()
+	if missed > 0 {
+		msgs = append([]*pb.BroadcastMessage{missedNotice(missed)}, msgs...)
+	}
+	for _, msg := range msgs {
+		if err := send(msg); err != nil {
+			s.detach(clientName, box)
+			return true, err
+		}
+	}
+	if done && reason != nil {
+		log.Printf("[Server] Ending the stream of %s: %v", clientName, reason)
+		s.detach(clientName, box)
+	}
+	return done, reason
+}
+
+func (s *server) JoinChat(req *pb.JoinRequest, stream pb.ITUDatabase_JoinChatServer) error {
+	clientName := req.ParticipantName
+	if err := checkSender(stream.Context(), clientName); err != nil {
+		return err
+	}
+
+	j, err := s.join(req)
+	if err != nil {
+		return err
+	}
+	if err := stream.SendHeader(metadata.Pairs(resumedKey, strconv.FormatBool(j.resumed))); err != nil {
+		s.detach(clientName, j.box)
+		return err
+	}
+	if err := s.catchUp(req, j, stream.Send); err != nil {
+		return err
 	}
-	s.broadcast(joinMsg)
 
 	for {
 		select {
-		case msg, ok := <-msgChan:
-			if !ok {
-				return nil
-			}
-			if err := stream.Send(msg); err != nil {
-				s.removeClient(clientName)
+		case <-j.box.ready:
+			if done, err := s.forward(clientName, j.box, stream.Send); done {
 				return err
 			}
 		case <-stream.Context().Done():
 			log.Printf("[Server] Client %s disconnected", clientName)
-			s.removeClient(clientName)
+			s.detach(clientName, j.box)
 			return nil
 		}
 	}
 }
 
 func (s *server) PublishMessage(ctx context.Context, msg *pb.ChatMessage) (*pb.PublishResponse, error) {
+	if err := checkSender(ctx, msg.ParticipantName); err != nil {
+		return &pb.PublishResponse{Success: false}, err
+	}
+	return s.publish(msg)
+}
+
+// publish broadcasts a chat message of a participant whose name was checked
+func (s *server) publish(msg *pb.ChatMessage) (*pb.PublishResponse, error) {
 	if len(msg.Content) > 128 {
 		return &pb.PublishResponse{Success: false}, fmt.Errorf("message exceeds 128 characters")
 	}
 
+	name, err := roomName(msg.Room)
+	if err != nil {
+		return &pb.PublishResponse{Success: false}, err
+	}
 	s.mu.Lock()
-	if msg.Lamport > s.lamportClock {
-		s.lamportClock = msg.Lamport
+	r, ok := s.rooms[name]
+	member := ok && r.members[msg.ParticipantName]
+	refusal := ""
+	if member {
+		refusal = s.refusalLocked(msg.ParticipantName)
 	}
-	s.lamportClock++
-	lamportTime := s.lamportClock
-
 	s.mu.Unlock()
-	log.Printf("[Server] Message from %s (Lamport: %d)", msg.ParticipantName, lamportTime)
+	if !member {
+		return &pb.PublishResponse{Success: false}, fmt.Errorf("%s is not in room %s", msg.ParticipantName, name)
+	}
+	if refusal != "" {
+		log.Printf("[Server] Refused a message from %s: %s", msg.ParticipantName, refusal)
+		return &pb.PublishResponse{Success: false, Reason: refusal}, nil
+	}
 
-	broadcastMsg := &pb.BroadcastMessage{
-		Content:          fmt.Sprintf("%s: %s", msg.ParticipantName, msg.Content),
-		LamportTimestamp: lamportTime,
-		Type:             pb.MessageType_CHAT,
+	lamportTime, err := s.announce(name, pb.MessageType_CHAT, msg, func(lamport int64) string {
+		return fmt.Sprintf("%s: %s", msg.ParticipantName, msg.Content)
+	})
+	if err != nil {
+		return &pb.PublishResponse{Success: false}, err
 	}
-	s.broadcast(broadcastMsg)
+	log.Printf("[Server] Message from %s in %s (Lamport: %d)", msg.ParticipantName, name, lamportTime)
+	s.mu.Lock()
+	s.stopTypingLocked(msg.ParticipantName)
+	s.mu.Unlock()
 
 	return &pb.PublishResponse{
 		Success:          true,
@@ -114,50 +343,181 @@ func (s *server) PublishMessage(ctx context.Context, msg *pb.ChatMessage) (*pb.P
 
 func (s *server) LeaveChat(ctx context.Context, req *pb.LeaveRequest) (*pb.LeaveResponse, error) {
 	clientName := req.ParticipantName
-	lamportTime := s.incrementClock()
+	if err := checkSender(ctx, clientName); err != nil {
+		return &pb.LeaveResponse{Success: false}, err
+	}
+	s.leave(clientName)
+	return &pb.LeaveResponse{Success: true}, nil
+}
 
-	log.Printf("[Server] Client %s left (Lamport: %d)", clientName, lamportTime)
+// leave tells the others the participant left, and ends its session
+func (s *server) leave(clientName string) {
+	lamportTime, _ := s.announce(generalRoom, pb.MessageType_LEAVE, nil, func(lamport int64) string {
+		return fmt.Sprintf("Participant %s left Chit Chat at Lamport time %d", clientName, lamport)
+	})
 
-	leaveMsg := &pb.BroadcastMessage{
-		Content:          fmt.Sprintf("Participant %s left Chit Chat at Lamport time %d", clientName, lamportTime),
-		LamportTimestamp: lamportTime,
-		Type:             pb.MessageType_LEAVE,
-	}
-	s.broadcast(leaveMsg)
+	log.Printf("[Server] Client %s left (Lamport: %d)", clientName, lamportTime)
 	s.removeClient(clientName)
-
-	return &pb.LeaveResponse{Success: true}, nil
+	s.endSession(clientName)
 }
 
+// removeClient takes the participant out of the chat and all its rooms
 func (s *server) removeClient(clientName string) {
 	s.mu.Lock()
-	defer s.mu.Unlock()
 
-	if ch, exists := s.clients[clientName]; exists {
-		close(ch)
+	if box, exists := s.clients[clientName]; exists {
+		box.close(nil)
 		delete(s.clients, clientName)
+		log.Printf("[Server] %d messages for %s were dropped", box.droppedTotal(), clientName)
+	}
+	if timer, detached := s.detached[clientName]; detached {
+		timer.Stop()
+		delete(s.detached, clientName)
+	}
+	delete(s.acked, clientName)
+	s.setStatusLocked(clientName, pb.PresenceStatus_OFFLINE)
+	s.mu.Unlock()
+
+	if err := s.changeRooms(pb.RoomChangeType_LEFT_CHAT, "", clientName); err != nil {
+		log.Printf("[Server] Could not take %s out of its rooms: %v", clientName, err)
 	}
 }
 
 func main() {
-	logFile, err := os.OpenFile("server.log", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+	historyPath := flag.String("history", "chat_history.log", "file where every broadcast message is stored")
+	accountsPath := flag.String("accounts", "accounts.json", "file where the registered participants are stored")
+	roomsPath := flag.String("rooms", "rooms.json", "file where the names of the rooms are stored")
+	slowPolicy := flag.String("slow-policy", "drop-oldest", "what to do when a client can't keep up: drop-oldest, disconnect or block")
+	blockTimeout := flag.Duration("block-timeout", 2*time.Second, "how long the block policy waits for a slow client")
+	port := flag.Int("port", 5000, "port to listen on")
+	name := flag.String("name", "", "address the peers use for this server (default localhost:<port>)")
+	peers := flag.String("peers", "", "comma separated addresses of the other servers, e.g. localhost:5001,localhost:5002")
+	peerSecret := flag.String("peer-secret", "", "if set, peers have to send it to relay messages")
+	replicas := flag.String("replicas", "", "comma separated addresses of all the replicas, e.g. localhost:5001,localhost:5002,localhost:5003")
+	raftDir := flag.String("raft-dir", "", "directory for the files of a replica: the Raft log and state, and the history, accounts and log if not given (default replica_<port>)")
+	admins := flag.String("admins", "", "comma separated names of the participants that may kick, mute and ban")
+	rate := flag.Float64("rate", 5, "messages per second a participant may publish on average, 0 for no limit")
+	burst := flag.Int("burst", 10, "messages a participant may publish at once")
+	logPath := flag.String("log", "server.log", "log file")
+	tlsCert := flag.String("tls-cert", "", "certificate of the server, with -tls-key the server only takes TLS connections")
+	tlsKey := flag.String("tls-key", "", "private key of the certificate")
+	tlsCA := flag.String("tls-ca", "", "CA of the client certificates: a client with one is the participant in its common name, without a password")
+	requireCert := flag.Bool("require-client-cert", false, "refuse clients without a certificate signed by -tls-ca")
+	flag.Parse()
+	if *name == "" {
+		*name = fmt.Sprintf("localhost:%d", *port)
+	}
+	if *replicas != "" {
+		// replicas that share a file overwrite each other's log, so every one has its own directory
+		if *raftDir == "" {
+			*raftDir = fmt.Sprintf("replica_%d", *port)
+		}
+		given := make(map[string]bool)
+		flag.Visit(func(f *flag.Flag) { given[f.Name] = true })
+		for flagName, path := range map[string]*string{"history": historyPath, "accounts": accountsPath, "rooms": roomsPath, "log": logPath} {
+			if !given[flagName] {
+				*path = filepath.Join(*raftDir, *path)
+			}
+		}
+		if err := os.MkdirAll(*raftDir, 0755); err != nil {
+			log.Fatalf("Failed to create %s: %v", *raftDir, err)
+		}
+	}
+
+	logFile, err := os.OpenFile(*logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
 	if err != nil {
 		log.Fatalf("Failed to open log: %v", err)
 	}
 	log.SetOutput(logFile)
 
-	srv := newServer()
+	if *peers != "" && *replicas != "" {
+		log.Fatalf("Use either -peers or -replicas, not both")
+	}
+	if *replicas != "" {
+		// the Raft log is what counts, the history, the accounts and the rooms are built again from it
+		for _, path := range []string{*historyPath, *accountsPath, *roomsPath} {
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				log.Fatalf("Failed to clear %s: %v", path, err)
+			}
+		}
+	}
+
+	h, err := openHistory(*historyPath)
+	if err != nil {
+		log.Fatalf("Failed to open history: %v", err)
+	}
+	defer h.close()
+
+	a, err := openAccounts(*accountsPath)
+	if err != nil {
+		log.Fatalf("Failed to open accounts: %v", err)
+	}
+
+	var tlsConfig *tls.Config
+	if *tlsCert != "" || *tlsKey != "" {
+		if tlsConfig, err = serverTLS(*tlsCert, *tlsKey, *tlsCA, *requireCert); err != nil {
+			log.Fatalf("Failed to load the TLS certificates: %v", err)
+		}
+	} else if *tlsCA != "" || *requireCert {
+		log.Fatalf("Client certificates need TLS, give -tls-cert and -tls-key")
+	}
+
+	if (*peers != "" || *replicas != "") && *peerSecret == "" && *tlsCA == "" {
+		log.Fatalf("Peers and replicas have to prove they are servers: give -peer-secret, or -tls-ca for their certificates")
+	}
+
+	srv := newServer(h, a, newFederation(*name, strings.Split(*peers, ","), *peerSecret, peerCredentials(tlsConfig)))
+	if srv.defaultPolicy, err = parsePolicy(*slowPolicy); err != nil {
+		log.Fatalf("%v", err)
+	}
+	srv.blockTimeout = *blockTimeout
+	if err := srv.loadRooms(*roomsPath); err != nil {
+		log.Fatalf("Failed to open rooms: %v", err)
+	}
+	srv.moderation = newModeration(strings.Split(*admins, ","), *rate, *burst)
 	log.Println("[Server] Starting up")
+	log.Printf("[Server] Loaded %d messages in %d rooms from %s", len(h.messages), len(srv.rooms), *historyPath)
+	if len(srv.federation.peers) > 0 {
+		log.Printf("[Server] %s relays to %d peers: %s", *name, len(srv.federation.peers), *peers)
+	}
 
-	grpcServer := grpc.NewServer()
+	if *replicas != "" {
+		if srv.raft, err = newRaft(*name, strings.Split(*replicas, ","), *peerSecret, *raftDir, peerCredentials(tlsConfig)); err != nil {
+			log.Fatalf("Failed to start Raft: %v", err)
+		}
+		srv.raft.apply = srv.applyCommitted
+		srv.raft.applyChange = srv.applyCommittedChange
+		srv.raft.onLeader = srv.becameLeader
+		srv.raft.onFollower = srv.steppedDown
+		log.Printf("[Server] %s is one of the replicas %s", *name, *replicas)
+	}
+
+	go srv.watchPresence()
+
+	options := []grpc.ServerOption{
+		grpc.UnaryInterceptor(srv.authUnary),
+		grpc.StreamInterceptor(srv.authStream),
+	}
+	if tlsConfig != nil {
+		options = append(options, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+	grpcServer := grpc.NewServer(options...)
 	pb.RegisterITUDatabaseServer(grpcServer, srv)
+	if srv.raft != nil {
+		pb.RegisterRaftServer(grpcServer, srv.raft)
+		srv.raft.start()
+	}
 
-	lis, err := net.Listen("tcp", ":5000")
+	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", *port))
 	if err != nil {
 		log.Fatalf("Failed to listen: %v", err)
 	}
 
-	log.Println("[Server] Listening on port 5000")
+	if tlsConfig != nil {
+		log.Printf("[Server] Listening on port %d with TLS (client certificates: %v)", *port, tlsConfig.ClientAuth)
+	} else {
+		log.Printf("[Server] Listening on port %d", *port)
+	}
 
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
@@ -165,6 +525,8 @@ func main() {
 	go func() {
 		<-sigChan
 		log.Println("[Server] Shutting down")
+		// GracefulStop waits for every stream to end, so end them first
+		srv.closeStreams()
 		grpcServer.GracefulStop()
 	}()
 
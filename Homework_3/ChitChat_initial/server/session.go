@@ -0,0 +1,130 @@
+package main
+
+import (
+	pb "ITUserver/grpc"
+	"fmt"
+	"io"
+	"log"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Session is JoinChat, PublishMessage and LeaveChat on one stream. It ends when the client
+// leaves or closes its side of the stream. A stream that breaks is resumed like a JoinChat stream.
+func (s *server) Session(stream pb.ITUDatabase_SessionServer) error {
+	first, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	req := first.GetJoin()
+	if req == nil {
+		return status.Errorf(codes.FailedPrecondition, "join the chat first")
+	}
+	clientName := req.ParticipantName
+	if err := checkSender(stream.Context(), clientName); err != nil {
+		return err
+	}
+
+	j, err := s.join(req)
+	if err != nil {
+		return err
+	}
+	if err := stream.Send(ackFrame(first.Id, &pb.SessionAck{Success: true, Resumed: j.resumed})); err != nil {
+		s.detach(clientName, j.box)
+		return err
+	}
+	send := func(msg *pb.BroadcastMessage) error {
+		return stream.Send(&pb.SessionFrame{Frame: &pb.SessionFrame_Message{Message: msg}})
+	}
+	if err := s.catchUp(req, j, send); err != nil {
+		return err
+	}
+
+	// only this goroutine sends on the stream, the frames of the client come here
+	frames := make(chan *pb.SessionFrame)
+	ended := make(chan error, 1)
+	go func() {
+		for {
+			frame, err := stream.Recv()
+			if err != nil {
+				ended <- err
+				return
+			}
+			select {
+			case frames <- frame:
+			case <-stream.Context().Done():
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case <-j.box.ready:
+			if done, err := s.forward(clientName, j.box, send); done {
+				return err
+			}
+
+		case frame := <-frames:
+			var ack *pb.SessionAck
+			switch f := frame.Frame.(type) {
+			case *pb.SessionFrame_Chat:
+				ack = s.sessionChat(clientName, f.Chat)
+			case *pb.SessionFrame_Ack:
+				s.mu.Lock()
+				s.acked[clientName] = max(s.acked[clientName], f.Ack.Seq)
+				s.mu.Unlock()
+				continue
+			case *pb.SessionFrame_Leave:
+				s.leave(clientName)
+				// the session is over, whether the client gets this or not
+				stream.Send(ackFrame(frame.Id, &pb.SessionAck{Success: true}))
+				return nil
+			case *pb.SessionFrame_Join:
+				ack = &pb.SessionAck{Success: false, Reason: fmt.Sprintf("%s already joined", clientName)}
+			default:
+				ack = &pb.SessionAck{Success: false, Reason: "a client only sends join, chat, leave and ack frames"}
+			}
+			if err := stream.Send(ackFrame(frame.Id, ack)); err != nil {
+				s.detach(clientName, j.box)
+				return err
+			}
+
+		case err := <-ended:
+			if err == io.EOF {
+				// the client closed the stream without leaving, the session is over anyway
+				s.leave(clientName)
+				return nil
+			}
+			log.Printf("[Server] Client %s disconnected", clientName)
+			s.detach(clientName, j.box)
+			return nil
+
+		case <-stream.Context().Done():
+			log.Printf("[Server] Client %s disconnected", clientName)
+			s.detach(clientName, j.box)
+			return nil
+		}
+	}
+}
+
+// sessionChat publishes a chat frame, it is always from the participant of the session
+func (s *server) sessionChat(clientName string, msg *pb.ChatMessage) *pb.SessionAck {
+	if msg.ParticipantName == "" {
+		msg.ParticipantName = clientName
+	}
+	if msg.ParticipantName != clientName {
+		return &pb.SessionAck{Success: false, Reason: fmt.Sprintf("this is the session of %s, not %s", clientName, msg.ParticipantName)}
+	}
+	resp, err := s.publish(msg)
+	if err != nil {
+		return &pb.SessionAck{Success: false, Reason: status.Convert(err).Message()}
+	}
+	return &pb.SessionAck{Success: resp.Success, LamportTimestamp: resp.LamportTimestamp, Reason: resp.Reason}
+}
+
+func ackFrame(id uint64, ack *pb.SessionAck) *pb.SessionFrame {
+	ack.Id = id
+	return &pb.SessionFrame{Id: id, Frame: &pb.SessionFrame_Ack{Ack: ack}}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"slices"
+
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	grpcpeer "google.golang.org/grpc/peer"
+)
+
+// serverTLS loads the certificate of the server. With a CA a client can show a certificate
+// signed by it, and is then the participant named in it (with requireCert it has to).
+func serverTLS(certFile, keyFile, caFile string, requireCert bool) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	if caFile == "" {
+		if requireCert {
+			return nil, fmt.Errorf("-require-client-cert needs the CA of the client certificates (-tls-ca)")
+		}
+		return cfg, nil
+	}
+	if cfg.ClientCAs, err = loadCA(caFile); err != nil {
+		return nil, err
+	}
+	cfg.ClientAuth = tls.VerifyClientCertIfGiven
+	if requireCert {
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return cfg, nil
+}
+
+func loadCA(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificates in %s", path)
+	}
+	return pool, nil
+}
+
+// peerCredentials are used to call the other servers. With TLS they are checked against the CA
+// (or the system's CAs without one), and get our certificate in case they want one.
+func peerCredentials(cfg *tls.Config) credentials.TransportCredentials {
+	if cfg == nil {
+		return insecure.NewCredentials()
+	}
+	return credentials.NewTLS(&tls.Config{
+		Certificates: cfg.Certificates,
+		RootCAs:      cfg.ClientCAs,
+		MinVersion:   tls.VersionTLS12,
+	})
+}
+
+// verifiedCert is the client certificate of a call, if it was signed by our CA
+func verifiedCert(ctx context.Context) *x509.Certificate {
+	p, ok := grpcpeer.FromContext(ctx)
+	if !ok {
+		return nil
+	}
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(info.State.VerifiedChains) == 0 {
+		return nil
+	}
+	return info.State.VerifiedChains[0][0]
+}
+
+// certName is the common name of the verified client certificate of a call, "" without one
+func certName(ctx context.Context) string {
+	if cert := verifiedCert(ctx); cert != nil {
+		return cert.Subject.CommonName
+	}
+	return ""
+}
+
+// peerCertified is true for a call from another server: its certificate is for servers,
+// the ones of the participants are only for clients
+func peerCertified(ctx context.Context) bool {
+	cert := verifiedCert(ctx)
+	return cert != nil && slices.Contains(cert.ExtKeyUsage, x509.ExtKeyUsageServerAuth)
+}